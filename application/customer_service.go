@@ -3,6 +3,7 @@ package application
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/macadrich/go-task-challenge/domain"
 )
@@ -18,29 +19,94 @@ type CustomerRepository interface {
 
 type CustomerService struct {
 	kycService         domain.KYCService
+	tenantKYCServices  map[string]domain.KYCService
 	customerRepository CustomerRepository
+	idempotency        *idempotency
+	documentStore      DocumentStore
 }
 
-func NewCustomerService(kycService domain.KYCService, customerRepository CustomerRepository) *CustomerService {
-	return &CustomerService{
+// Option configures optional behaviour of CustomerService.
+type Option func(*CustomerService)
+
+// WithIdempotency makes RegisterCustomer and VerifyRegisteredCustomer honour
+// idempotency keys carried in the context. The first outcome for a key is kept
+// in store for window and replayed to later calls with the same key.
+func WithIdempotency(store IdempotencyStore, window time.Duration) Option {
+	return func(s *CustomerService) {
+		s.idempotency = newIdempotency(store, window)
+	}
+}
+
+// WithTenantKYCService makes customers of tenantID use kycService instead of
+// the default KYC service.
+func WithTenantKYCService(tenantID string, kycService domain.KYCService) Option {
+	return func(s *CustomerService) {
+		s.tenantKYCServices[tenantID] = kycService
+	}
+}
+
+func NewCustomerService(kycService domain.KYCService, customerRepository CustomerRepository, opts ...Option) *CustomerService {
+	s := &CustomerService{
 		kycService:         kycService,
+		tenantKYCServices:  make(map[string]domain.KYCService),
 		customerRepository: customerRepository,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// kycServiceFor returns the KYC service configured for the tenant of ctx.
+func (s *CustomerService) kycServiceFor(ctx context.Context) domain.KYCService {
+	if kycService, ok := s.tenantKYCServices[domain.TenantFromContext(ctx)]; ok {
+		return kycService
+	}
+	return s.kycService
+}
+
+// providerCounter is implemented by KYC services that may ask a different
+// number of providers than requested.
+type providerCounter interface {
+	Providers(requested int) int
+}
+
+// providersFor returns the number of providers the KYC service of the
+// tenant of ctx asks when numRequest are requested.
+func (s *CustomerService) providersFor(ctx context.Context, numRequest int) int {
+	if counter, ok := s.kycServiceFor(ctx).(providerCounter); ok {
+		return counter.Providers(numRequest)
+	}
+	return numRequest
 }
 
 func (s *CustomerService) RegisterCustomer(ctx context.Context, customer *domain.Customer) error {
+	if err := domain.CheckTenant(ctx, customer); err != nil {
+		return err
+	}
+
+	return s.idempotency.do(ctx, "register", customer, func() error {
+		return s.registerCustomer(ctx, customer)
+	})
+}
+
+func (s *CustomerService) registerCustomer(ctx context.Context, customer *domain.Customer) error {
 	existingCustomer, _ := s.customerRepository.FindByEmail(ctx, customer.Email)
 	if existingCustomer != nil {
 		return ErrCustomerExists
 	}
 
+	if customer.ID == "" {
+		customer.ID = domain.NewCustomerID()
+	}
 	customer.KYCStatus = "pending"
+	customer.CreatedAt = time.Now()
 
-	if err := s.kycService.ValidateKYC(ctx, customer); err != nil {
+	if err := s.kycServiceFor(ctx).ValidateKYC(ctx, customer); err != nil {
 		return err
 	}
 
-	if err := s.customerRepository.Save(ctx, customer); err != nil {
+	if err := s.save(ctx, customer, domain.CustomerRegistered); err != nil {
 		return err
 	}
 
@@ -48,10 +114,18 @@ func (s *CustomerService) RegisterCustomer(ctx context.Context, customer *domain
 }
 
 func (s *CustomerService) VerifyRegisteredCustomer(ctx context.Context, numRequest int, customer *domain.Customer) error {
-	// Validate multiple customer KYC
-	if err := s.kycService.VerifyCustomerKYC(ctx, numRequest, customer); err != nil {
+	if err := domain.CheckTenant(ctx, customer); err != nil {
 		return err
 	}
 
-	return nil
+	return s.idempotency.do(ctx, "verify", customer, func() error {
+		// Validate multiple customer KYC
+		if err := s.kycServiceFor(ctx).VerifyCustomerKYC(ctx, numRequest, customer); err != nil {
+			return err
+		}
+
+		// Persist the new status; a concurrent verification that saved first
+		// makes this fail with domain.ErrVersionConflict.
+		return s.save(ctx, customer, domain.CustomerVerified)
+	})
 }
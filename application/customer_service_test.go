@@ -2,14 +2,22 @@ package application
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/macadrich/go-task-challenge/constants"
 	"github.com/macadrich/go-task-challenge/domain"
 	"github.com/macadrich/go-task-challenge/infra"
 	"github.com/macadrich/go-task-challenge/mocks"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRegisterCustomer(t *testing.T) {
@@ -24,7 +32,7 @@ func TestRegisterCustomer(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "john.doe@example.com",
 		Phone:     "1234567890",
-		Address:   "123 Main St",
+		Address:   domain.Address{Street: "123 Main St"},
 	}
 
 	ctx := context.Background()
@@ -47,7 +55,7 @@ func TestVerifyCustomer(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "john.doe@example.com",
 		Phone:     "1234567890",
-		Address:   "123 Main St",
+		Address:   domain.Address{Street: "123 Main St"},
 	}
 
 	ctx := context.Background()
@@ -57,3 +65,254 @@ func TestVerifyCustomer(t *testing.T) {
 	assert.Equal(t, "approved", customer.KYCStatus)
 	mockKYC.AssertCalled(t, "VerifyCustomerKYC", mock.Anything, customer)
 }
+
+func TestRegisterCustomerIdempotencyKey(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	customerRepository := infra.NewCustomerRepository()
+	customerService := NewCustomerService(mockKYC, customerRepository,
+		WithIdempotency(rediscache.NewRedisCache(2), time.Minute))
+
+	ctx := WithIdempotencyKey(context.Background(), "register-1")
+	for i := 0; i < 3; i++ {
+		customer := &domain.Customer{
+			FirstName: "John",
+			LastName:  "Doe",
+			Email:     "john.doe@example.com",
+		}
+		err := customerService.RegisterCustomer(ctx, customer)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "pending", customer.KYCStatus)
+	}
+	mockKYC.AssertNumberOfCalls(t, "ValidateKYC", 1)
+
+	// Reusing the key for another customer is a conflict, not a replay.
+	err := customerService.RegisterCustomer(ctx, &domain.Customer{Email: "jane.doe@example.com"})
+	assert.ErrorIs(t, err, ErrIdempotencyKeyConflict)
+	_, err = customerRepository.FindByEmail(ctx, "jane.doe@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	mockKYC.AssertNumberOfCalls(t, "ValidateKYC", 1)
+
+	// Without a key the duplicate registration is rejected as before.
+	err = customerService.RegisterCustomer(context.Background(), &domain.Customer{Email: "john.doe@example.com"})
+	assert.ErrorIs(t, err, ErrCustomerExists)
+}
+
+func TestIdempotencyKeyRejectsInvalidTenants(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	customerService := NewCustomerService(mockKYC, infra.NewCustomerRepository(),
+		WithIdempotency(rediscache.NewRedisCache(2), time.Minute))
+
+	ctx := WithIdempotencyKey(domain.WithTenant(context.Background(), "acme:register"), "k")
+	err := customerService.RegisterCustomer(ctx, &domain.Customer{Email: "john.doe@example.com"})
+	assert.ErrorIs(t, err, domain.ErrInvalidTenant)
+	mockKYC.AssertNotCalled(t, "ValidateKYC", mock.Anything, mock.Anything)
+}
+
+func TestRegisterCustomerReportsUnrecordedIdempotencyKey(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	// A cache that is always full refuses to store the outcome.
+	full := rediscache.NewRedisCache(2, rediscache.WithMaxMemory(1))
+	full.Set("filler", "value", 0)
+
+	customerRepository := infra.NewCustomerRepository()
+	customerService := NewCustomerService(mockKYC, customerRepository, WithIdempotency(full, time.Minute))
+
+	ctx := WithIdempotencyKey(context.Background(), "register-1")
+	customer := &domain.Customer{Email: "john.doe@example.com"}
+	err := customerService.RegisterCustomer(ctx, customer)
+
+	assert.ErrorIs(t, err, ErrIdempotencyNotRecorded)
+	_, err = customerRepository.FindByEmail(ctx, "john.doe@example.com")
+	assert.NoError(t, err, "the registration itself is kept")
+}
+
+func TestVerifyCustomerIdempotencyKeyReplaysError(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("VerifyCustomerKYC", mock.Anything, mock.Anything).Return(domain.ErrKYCFailed)
+
+	customerRepository := infra.NewCustomerRepository()
+	customerService := NewCustomerService(mockKYC, customerRepository,
+		WithIdempotency(rediscache.NewRedisCache(2), time.Minute))
+
+	ctx := WithIdempotencyKey(context.Background(), "verify-1")
+	for i := 0; i < 3; i++ {
+		customer := &domain.Customer{Email: "john.doe@example.com"}
+		err := customerService.VerifyRegisteredCustomer(ctx, constants.NumberOfRoutines, customer)
+
+		assert.ErrorIs(t, err, domain.ErrKYCFailed)
+	}
+	mockKYC.AssertNumberOfCalls(t, "VerifyCustomerKYC", 1)
+
+	// A different key reaches the provider again.
+	ctx = WithIdempotencyKey(context.Background(), "verify-2")
+	customerService.VerifyRegisteredCustomer(ctx, constants.NumberOfRoutines, &domain.Customer{})
+	mockKYC.AssertNumberOfCalls(t, "VerifyCustomerKYC", 2)
+}
+
+func TestVerifyCustomerSavesStatusAndRejectsStaleVerification(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+	mockKYC.On("VerifyCustomerKYC", mock.Anything, mock.Anything).Return(nil)
+
+	customerRepository := infra.NewCustomerRepository()
+	customerService := NewCustomerService(mockKYC, customerRepository)
+
+	ctx := context.Background()
+	assert.NoError(t, customerService.RegisterCustomer(ctx, &domain.Customer{Email: "john.doe@example.com"}))
+
+	first, _ := customerRepository.FindByEmail(ctx, "john.doe@example.com")
+	second, _ := customerRepository.FindByEmail(ctx, "john.doe@example.com")
+
+	assert.NoError(t, customerService.VerifyRegisteredCustomer(ctx, constants.NumberOfRoutines, first))
+	err := customerService.VerifyRegisteredCustomer(ctx, constants.NumberOfRoutines, second)
+	assert.ErrorIs(t, err, domain.ErrVersionConflict)
+
+	stored, _ := customerRepository.FindByEmail(ctx, "john.doe@example.com")
+	assert.Equal(t, "approved", stored.KYCStatus)
+	assert.Equal(t, 2, stored.Version)
+}
+
+func TestCustomerServiceTenants(t *testing.T) {
+	defaultKYC := new(mocks.MockKYCService)
+	acmeKYC := new(mocks.MockKYCService)
+	acmeKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	customerRepository := infra.NewCustomerRepository()
+	customerService := NewCustomerService(defaultKYC, customerRepository, WithTenantKYCService("acme", acmeKYC))
+
+	acme := domain.WithTenant(context.Background(), "acme")
+	globex := domain.WithTenant(context.Background(), "globex")
+
+	customer := &domain.Customer{Email: "john.doe@example.com"}
+	assert.NoError(t, customerService.RegisterCustomer(acme, customer))
+	assert.Equal(t, "acme", customer.TenantID)
+	acmeKYC.AssertNumberOfCalls(t, "ValidateKYC", 1)
+	defaultKYC.AssertNotCalled(t, "ValidateKYC", mock.Anything, mock.Anything)
+
+	err := customerService.VerifyRegisteredCustomer(globex, constants.NumberOfRoutines, customer)
+	assert.ErrorIs(t, err, domain.ErrTenantMismatch)
+	err = customerService.RegisterCustomer(globex, customer)
+	assert.ErrorIs(t, err, domain.ErrTenantMismatch)
+}
+
+func TestAttachDocument(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	customerRepository := infra.NewCustomerRepository()
+	blobDir := t.TempDir()
+	customerService := NewCustomerService(mockKYC, customerRepository,
+		WithDocumentStore(infra.NewLocalBlobStore(blobDir)))
+
+	ctx := context.Background()
+	assert.NoError(t, customerService.RegisterCustomer(ctx, &domain.Customer{Email: "jane.doe@example.com"}))
+
+	// A rejected file is never written to the store.
+	_, err := customerService.AttachDocument(ctx, "jane.doe@example.com", domain.Document{
+		Type:           domain.Passport,
+		Number:         "P1234567",
+		IssuingCountry: "PH",
+		Expiry:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, strings.NewReader("plain text"))
+	assert.ErrorIs(t, err, domain.ErrInvalidDocument)
+	entries, err := os.ReadDir(blobDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.NoError(t, customerService.RegisterCustomer(ctx, &domain.Customer{Email: "john.doe@example.com"}))
+
+	document := domain.Document{
+		Type:           domain.Passport,
+		Number:         "P1234567",
+		IssuingCountry: "PH",
+		Expiry:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	png := "\x89PNG\r\n\x1a\n0000"
+
+	customer, err := customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader(png))
+	assert.NoError(t, err)
+	assert.Len(t, customer.Documents, 1)
+	assert.Equal(t, "image/png", customer.Documents[0].MIMEType)
+
+	// A second passport replaces the first one.
+	document.Number = "P7654321"
+	_, err = customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader(png))
+	assert.NoError(t, err)
+
+	stored, _ := customerRepository.FindByEmail(ctx, "john.doe@example.com")
+	assert.Len(t, stored.Documents, 1)
+	assert.Equal(t, "P7654321", stored.Documents[0].Number)
+
+	_, err = customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader("plain text"))
+	assert.ErrorIs(t, err, domain.ErrInvalidDocument)
+	_, err = customerService.AttachDocument(ctx, "john.doe@example.com", domain.Document{Type: "selfie"}, strings.NewReader(png))
+	assert.ErrorIs(t, err, domain.ErrInvalidDocument)
+}
+
+// failingSaveRepository fails every save after the first failAfter ones.
+type failingSaveRepository struct {
+	CustomerRepository
+	saves     int
+	failAfter int
+}
+
+func (r *failingSaveRepository) Save(ctx context.Context, customer *domain.Customer) error {
+	r.saves++
+	if r.saves > r.failAfter {
+		return errors.New("store unavailable")
+	}
+	return r.CustomerRepository.Save(ctx, customer)
+}
+
+func TestAttachDocumentRemovesUnlinkedFiles(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	blobStore := infra.NewLocalBlobStore(t.TempDir())
+	customerRepository := &failingSaveRepository{CustomerRepository: infra.NewCustomerRepository(), failAfter: 2}
+	customerService := NewCustomerService(mockKYC, customerRepository, WithDocumentStore(blobStore))
+
+	ctx := context.Background()
+	require.NoError(t, customerService.RegisterCustomer(ctx, &domain.Customer{Email: "john.doe@example.com"}))
+	document := domain.Document{
+		Type:           domain.Passport,
+		Number:         "P1234567",
+		IssuingCountry: "PH",
+		Expiry:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	first, err := customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader("%PDF-1.4 first"))
+	require.NoError(t, err)
+	owner := documentOwner(first)
+	firstBlob := first.Documents[0].BlobID
+
+	// The file of a document that could not be saved is removed.
+	_, err = customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader("%PDF-1.4 unsaved"))
+	require.Error(t, err)
+	unsaved := sha256.Sum256([]byte("%PDF-1.4 unsaved"))
+	_, err = blobStore.Open(ctx, owner, hex.EncodeToString(unsaved[:]))
+	assert.ErrorIs(t, err, infra.ErrBlobNotFound)
+	stored, err := customerRepository.FindByEmail(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	require.Len(t, stored.Documents, 1)
+	reader, err := blobStore.Open(ctx, owner, firstBlob)
+	require.NoError(t, err, "the linked file is kept")
+	reader.Close()
+
+	// The file of a replaced document is removed once the new one is saved.
+	customerRepository.failAfter = 4
+	second, err := customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader("%PDF-1.4 second"))
+	require.NoError(t, err)
+	_, err = blobStore.Open(ctx, owner, firstBlob)
+	assert.ErrorIs(t, err, infra.ErrBlobNotFound)
+	reader, err = blobStore.Open(ctx, owner, second.Documents[0].BlobID)
+	require.NoError(t, err)
+	reader.Close()
+}
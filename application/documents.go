@@ -0,0 +1,135 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+var ErrNoDocumentStore = errors.New("no document store configured")
+
+// DocumentStore keeps the files of identity documents. Files are kept per
+// owner, so deleting the file of one customer leaves an identical file of
+// another customer in place. DeleteOwner removes every file of an owner,
+// including files no document links to any more.
+type DocumentStore interface {
+	Put(ctx context.Context, owner string, content io.Reader) (domain.Blob, error)
+	Delete(ctx context.Context, owner, id string) error
+	DeleteOwner(ctx context.Context, owner string) error
+}
+
+// sniffLen is the number of leading bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// documentMIMETypes are the file types accepted for identity documents.
+var documentMIMETypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// WithDocumentStore enables AttachDocument, keeping document files in store.
+func WithDocumentStore(store DocumentStore) Option {
+	return func(s *CustomerService) {
+		s.documentStore = store
+	}
+}
+
+// AttachDocument stores content and links document to the customer with the
+// given email, replacing an earlier document of the same type. The type of
+// content is checked before anything is stored, so rejected files never
+// reach the document store.
+func (s *CustomerService) AttachDocument(ctx context.Context, email string, document domain.Document, content io.Reader) (*domain.Customer, error) {
+	if s.documentStore == nil {
+		return nil, ErrNoDocumentStore
+	}
+	if err := document.Validate(); err != nil {
+		return nil, err
+	}
+
+	customer, err := s.customerRepository.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	if !documentMIMETypes[http.DetectContentType(head)] {
+		return nil, domain.ErrInvalidDocument
+	}
+
+	owner := documentOwner(customer)
+	blob, err := s.documentStore.Put(ctx, owner, io.MultiReader(bytes.NewReader(head), content))
+	if err != nil {
+		return nil, err
+	}
+	if !documentMIMETypes[blob.MIMEType] {
+		// The store sniffed a different type; do not keep the file.
+		if err := s.documentStore.Delete(ctx, owner, blob.ID); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrInvalidDocument
+	}
+
+	document.BlobID = blob.ID
+	document.MIMEType = blob.MIMEType
+	document.Size = blob.Size
+	document.AttachedAt = time.Now()
+
+	previous := customer.Documents
+	var replaced []string
+	documents := make([]domain.Document, 0, len(previous)+1)
+	for _, existing := range previous {
+		if existing.Type == document.Type {
+			replaced = append(replaced, existing.BlobID)
+			continue
+		}
+		documents = append(documents, existing)
+	}
+	customer.Documents = append(documents, document)
+
+	if err := s.save(ctx, customer, domain.CustomerDocumentAttached); err != nil {
+		// The new file is not linked to the customer; drop it unless it was
+		// already stored for one of their documents.
+		if !referencesBlob(previous, blob.ID) {
+			s.documentStore.Delete(ctx, owner, blob.ID)
+		}
+		return nil, err
+	}
+
+	// Files of replaced documents are no longer linked and are removed once
+	// the record is saved. A failed delete is not reported, as the document
+	// itself was attached.
+	for _, id := range replaced {
+		if !referencesBlob(customer.Documents, id) {
+			s.documentStore.Delete(ctx, owner, id)
+		}
+	}
+
+	return customer, nil
+}
+
+// referencesBlob reports whether one of documents keeps its file under id.
+func referencesBlob(documents []domain.Document, id string) bool {
+	for _, document := range documents {
+		if document.BlobID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// documentOwner names the customer that document files belong to in the
+// document store.
+func documentOwner(customer *domain.Customer) string {
+	return customer.TenantID + "/" + customer.ID
+}
@@ -0,0 +1,43 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// EraseCustomer irreversibly erases the personal data of the customer with
+// the given email and deletes the files of their documents. The pseudonymized
+// record is kept for audit and a customer.erased event is committed with it.
+func (s *CustomerService) EraseCustomer(ctx context.Context, email string) (*domain.Customer, error) {
+	customer, err := s.customerRepository.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.erase(ctx, customer); err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+// erase deletes all of the customer's document files before the record is
+// saved, so no personal files are left behind if the save fails; erasing
+// again completes the job. This includes files of replaced documents that
+// could not be removed at the time. Files are kept per customer, so an
+// identical file attached by another customer stays in place.
+func (s *CustomerService) erase(ctx context.Context, customer *domain.Customer) error {
+	if err := domain.CheckTenant(ctx, customer); err != nil {
+		return err
+	}
+
+	if s.documentStore != nil {
+		if err := s.documentStore.DeleteOwner(ctx, documentOwner(customer)); err != nil {
+			return fmt.Errorf("failed to delete document files: %w", err)
+		}
+	}
+
+	customer.Erase(time.Now())
+	return s.save(ctx, customer, domain.CustomerErased)
+}
@@ -0,0 +1,143 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/constants"
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// IdempotencyStore keeps the outcome of idempotent operations for a limited
+// time. rediscache.Cache satisfies it.
+type IdempotencyStore interface {
+	Get(key string) (interface{}, error)
+	Set(key string, value interface{}, ttl time.Duration) error
+}
+
+// ErrIdempotencyNotRecorded is returned when an operation succeeded but its
+// outcome could not be stored, so a retry with the same key would run it
+// again.
+var ErrIdempotencyNotRecorded = errors.New("outcome could not be recorded for the idempotency key")
+
+// ErrIdempotencyKeyConflict is returned when an idempotency key is reused
+// for a request that differs from the one it was first used with.
+var ErrIdempotencyKeyConflict = errors.New("idempotency key was used for a different request")
+
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey returns a copy of ctx carrying the idempotency key of the
+// caller. An empty key leaves ctx untouched.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key carried by ctx, if any.
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyContext{}).(string)
+	return key, ok && key != ""
+}
+
+// idempotentResult is the stored outcome of the first call made with a key,
+// with the fingerprint of the request it answers.
+type idempotentResult struct {
+	Fingerprint string
+	KYCStatus   string
+	Err         error
+}
+
+func (r idempotentResult) apply(customer *domain.Customer) error {
+	customer.KYCStatus = r.KYCStatus
+	return r.Err
+}
+
+type idempotentCall struct {
+	fingerprint string
+	done        chan struct{}
+	result      idempotentResult
+}
+
+// requestFingerprint identifies the request an idempotency key is used for:
+// the operation, the tenant and the customer it applies to.
+func requestFingerprint(tenantID, operation string, customer *domain.Customer) string {
+	return strings.Join([]string{operation, tenantID, customer.Email, customer.ID}, "\x00")
+}
+
+// idempotency replays stored outcomes for repeated keys and makes concurrent
+// callers with the same key wait for the first one instead of running twice.
+type idempotency struct {
+	store  IdempotencyStore
+	window time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*idempotentCall
+}
+
+func newIdempotency(store IdempotencyStore, window time.Duration) *idempotency {
+	return &idempotency{
+		store:    store,
+		window:   window,
+		inflight: make(map[string]*idempotentCall),
+	}
+}
+
+func (i *idempotency) do(ctx context.Context, operation string, customer *domain.Customer, fn func() error) error {
+	key, ok := IdempotencyKeyFromContext(ctx)
+	if i == nil || !ok {
+		return fn()
+	}
+	// Tenant IDs hold no ':', so the parts of the key cannot run into each
+	// other.
+	tenantID := domain.TenantFromContext(ctx)
+	if err := domain.ValidateTenantID(tenantID); err != nil {
+		return err
+	}
+	storeKey := constants.ReservedKeyPrefix + "idempotency:" + tenantID + ":" + operation + ":" + key
+	fingerprint := requestFingerprint(tenantID, operation, customer)
+
+	i.mu.Lock()
+	if call, found := i.inflight[storeKey]; found {
+		i.mu.Unlock()
+		if call.fingerprint != fingerprint {
+			return ErrIdempotencyKeyConflict
+		}
+		<-call.done
+		return call.result.apply(customer)
+	}
+	stored, err := i.store.Get(storeKey)
+	if err != nil {
+		i.mu.Unlock()
+		return err
+	}
+	if result, found := stored.(idempotentResult); found {
+		i.mu.Unlock()
+		if result.Fingerprint != fingerprint {
+			return ErrIdempotencyKeyConflict
+		}
+		return result.apply(customer)
+	}
+	call := &idempotentCall{fingerprint: fingerprint, done: make(chan struct{})}
+	i.inflight[storeKey] = call
+	i.mu.Unlock()
+
+	err = fn()
+	call.result = idempotentResult{Fingerprint: fingerprint, KYCStatus: customer.KYCStatus, Err: err}
+	storeErr := i.store.Set(storeKey, call.result, i.window)
+
+	i.mu.Lock()
+	delete(i.inflight, storeKey)
+	i.mu.Unlock()
+	close(call.done)
+
+	if err == nil && storeErr != nil {
+		return fmt.Errorf("%w: %v", ErrIdempotencyNotRecorded, storeErr)
+	}
+	return err
+}
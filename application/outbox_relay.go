@@ -0,0 +1,81 @@
+package application
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// EventPublisher delivers events to downstream systems.
+type EventPublisher interface {
+	Publish(context.Context, domain.Event) error
+}
+
+// OutboxRelay moves committed events from the outbox to a publisher. Events
+// are marked delivered only after Publish succeeds, so a crash in between
+// delivers them again: delivery is at least once.
+type OutboxRelay struct {
+	repository OutboxRepository
+	publisher  EventPublisher
+	interval   time.Duration
+	batchSize  int
+}
+
+func NewOutboxRelay(repository OutboxRepository, publisher EventPublisher, interval time.Duration, batchSize int) *OutboxRelay {
+	return &OutboxRelay{
+		repository: repository,
+		publisher:  publisher,
+		interval:   interval,
+		batchSize:  batchSize,
+	}
+}
+
+// Run relays events every interval until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Flush(ctx); err != nil {
+				log.Println("outbox relay:", err)
+			}
+		}
+	}
+}
+
+// Flush publishes pending events in commit order until the outbox is empty or
+// a publish fails, and returns the number of events delivered.
+func (r *OutboxRelay) Flush(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		events, err := r.repository.PendingEvents(ctx, r.batchSize)
+		if err != nil || len(events) == 0 {
+			return delivered, err
+		}
+
+		ids := make([]string, 0, len(events))
+		var publishErr error
+		for _, event := range events {
+			if publishErr = r.publisher.Publish(ctx, event); publishErr != nil {
+				break
+			}
+			ids = append(ids, event.ID)
+		}
+
+		if len(ids) > 0 {
+			if err := r.repository.MarkDelivered(ctx, ids); err != nil {
+				return delivered, err
+			}
+			delivered += len(ids)
+		}
+		if publishErr != nil {
+			return delivered, publishErr
+		}
+	}
+}
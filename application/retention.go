@@ -0,0 +1,140 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+var ErrRetentionUnsupported = errors.New("customer repository does not support retention")
+
+// RetentionRepository is a CustomerRepository that can list customers for
+// the retention policy.
+type RetentionRepository interface {
+	CustomerRepository
+	FindByStatus(ctx context.Context, status string) ([]*domain.Customer, error)
+	Tenants(ctx context.Context) ([]string, error)
+}
+
+// RetentionRule erases customers with the given KYC status once their
+// registration is older than MaxAge.
+type RetentionRule struct {
+	Status string
+	MaxAge time.Duration
+}
+
+// RetentionCandidate is a customer a retention rule applies to.
+type RetentionCandidate struct {
+	CustomerID string
+	Status     string
+	Age        time.Duration
+}
+
+// RetentionReport lists the customers of a tenant that were erased, or would
+// be in a dry run.
+type RetentionReport struct {
+	TenantID   string
+	DryRun     bool
+	Candidates []RetentionCandidate
+	Erased     int
+}
+
+// RetentionEngine applies retention rules to the customers of a
+// CustomerService. Customers registered before creation times were recorded
+// have no age and are left alone.
+type RetentionEngine struct {
+	service *CustomerService
+	rules   []RetentionRule
+	now     func() time.Time
+}
+
+func NewRetentionEngine(service *CustomerService, rules []RetentionRule) *RetentionEngine {
+	return &RetentionEngine{service: service, rules: rules, now: time.Now}
+}
+
+// Apply erases the customers of the context's tenant matched by the rules.
+// With dryRun it only reports them. A failed erasure does not stop the
+// others; the errors are returned together with the report.
+func (e *RetentionEngine) Apply(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+	repository, ok := e.service.customerRepository.(RetentionRepository)
+	if !ok {
+		return nil, ErrRetentionUnsupported
+	}
+
+	report := &RetentionReport{TenantID: domain.TenantFromContext(ctx), DryRun: dryRun}
+	now := e.now()
+
+	var errs []error
+	for _, rule := range e.rules {
+		customers, err := repository.FindByStatus(ctx, rule.Status)
+		if err != nil {
+			return report, err
+		}
+
+		for _, customer := range customers {
+			if customer.Erased() || customer.CreatedAt.IsZero() {
+				continue
+			}
+			age := now.Sub(customer.CreatedAt)
+			if age < rule.MaxAge {
+				continue
+			}
+
+			report.Candidates = append(report.Candidates, RetentionCandidate{
+				CustomerID: customer.ID,
+				Status:     customer.KYCStatus,
+				Age:        age,
+			})
+			if dryRun {
+				continue
+			}
+			if err := e.service.erase(ctx, customer); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			report.Erased++
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+// Run applies the rules to every tenant each interval until ctx is
+// cancelled.
+func (e *RetentionEngine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.applyAll(ctx)
+		}
+	}
+}
+
+func (e *RetentionEngine) applyAll(ctx context.Context) {
+	repository, ok := e.service.customerRepository.(RetentionRepository)
+	if !ok {
+		return
+	}
+
+	tenants, err := repository.Tenants(ctx)
+	if err != nil {
+		log.Println("retention:", err)
+		return
+	}
+	for _, tenantID := range tenants {
+		report, err := e.Apply(domain.WithTenant(ctx, tenantID), false)
+		if err != nil {
+			log.Printf("retention for tenant %s: %v", tenantID, err)
+		}
+		if report != nil && report.Erased > 0 {
+			log.Printf("retention erased %d customers of tenant %s", report.Erased, tenantID)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/macadrich/go-task-challenge/infra"
+	"github.com/macadrich/go-task-challenge/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEraseCustomer(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	blobStore := infra.NewLocalBlobStore(t.TempDir())
+	customerRepository := infra.NewCustomerRepository()
+	customerService := NewCustomerService(mockKYC, customerRepository,
+		WithDocumentStore(blobStore))
+
+	ctx := context.Background()
+	customer := &domain.Customer{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com", Phone: "1234567890"}
+	require.NoError(t, customerService.RegisterCustomer(ctx, customer))
+
+	document := domain.Document{
+		Type:           domain.Passport,
+		Number:         "P1234567",
+		IssuingCountry: "PH",
+		Expiry:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	attached, err := customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader("%PDF-1.4"))
+	require.NoError(t, err)
+	blobID := attached.Documents[0].BlobID
+
+	// Another customer attaches the same file.
+	other := &domain.Customer{Email: "jane.doe@example.com", Phone: "1234567891"}
+	require.NoError(t, customerService.RegisterCustomer(ctx, other))
+	_, err = customerService.AttachDocument(ctx, "jane.doe@example.com", document, strings.NewReader("%PDF-1.4"))
+	require.NoError(t, err)
+
+	erased, err := customerService.EraseCustomer(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	assert.True(t, erased.Erased())
+
+	_, err = blobStore.Open(ctx, documentOwner(customer), blobID)
+	assert.ErrorIs(t, err, infra.ErrBlobNotFound)
+	reader, err := blobStore.Open(ctx, documentOwner(other), blobID)
+	require.NoError(t, err)
+	reader.Close()
+
+	_, err = customerRepository.FindByEmail(ctx, "john.doe@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	_, err = customerRepository.FindByPhone(ctx, "1234567890")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+
+	// The audit record keeps the ID, status and timestamps only.
+	audit, err := customerRepository.FindByEmail(ctx, "erased:"+customer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, customer.ID, audit.ID)
+	assert.Equal(t, "pending", audit.KYCStatus)
+	assert.False(t, audit.CreatedAt.IsZero())
+	assert.Empty(t, audit.FirstName+audit.LastName+audit.Phone+audit.Address.String())
+	assert.Empty(t, audit.Documents)
+
+	pending, err := customerRepository.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, domain.CustomerErased, pending[len(pending)-1].Type)
+
+	// The email is free to be registered again.
+	assert.NoError(t, customerService.RegisterCustomer(ctx, &domain.Customer{Email: "john.doe@example.com", Phone: "1234567890"}))
+}
+
+// keepingBlobStore never deletes single files, like a store whose deletes
+// fail, so replaced documents leave their files behind.
+type keepingBlobStore struct {
+	*infra.LocalBlobStore
+}
+
+func (s keepingBlobStore) Delete(ctx context.Context, owner, id string) error {
+	return errors.New("delete failed")
+}
+
+func TestEraseCustomerRemovesFilesOfReplacedDocuments(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+
+	blobStore := infra.NewLocalBlobStore(t.TempDir())
+	customerService := NewCustomerService(mockKYC, infra.NewCustomerRepository(),
+		WithDocumentStore(keepingBlobStore{blobStore}))
+
+	ctx := context.Background()
+	customer := &domain.Customer{Email: "john.doe@example.com"}
+	require.NoError(t, customerService.RegisterCustomer(ctx, customer))
+	document := domain.Document{
+		Type:           domain.Passport,
+		Number:         "P1234567",
+		IssuingCountry: "PH",
+		Expiry:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var blobIDs []string
+	for _, content := range []string{"%PDF-1.4 first", "%PDF-1.4 replacement"} {
+		attached, err := customerService.AttachDocument(ctx, "john.doe@example.com", document, strings.NewReader(content))
+		require.NoError(t, err)
+		blobIDs = append(blobIDs, attached.Documents[0].BlobID)
+	}
+	reader, err := blobStore.Open(ctx, documentOwner(customer), blobIDs[0])
+	require.NoError(t, err, "the replaced file is left behind")
+	reader.Close()
+
+	_, err = customerService.EraseCustomer(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	for _, id := range blobIDs {
+		_, err := blobStore.Open(ctx, documentOwner(customer), id)
+		assert.ErrorIs(t, err, infra.ErrBlobNotFound, id)
+	}
+}
+
+func TestRetentionEngineErasesRejectedRegistrations(t *testing.T) {
+	mockKYC := new(mocks.MockKYCService)
+	mockKYC.On("ValidateKYC", mock.Anything, mock.Anything).Return(nil)
+	mockKYC.On("VerifyCustomerKYC", mock.Anything, mock.Anything).Return(domain.ErrKYCFailed)
+
+	repository := infra.NewCustomerRepository()
+	service := NewCustomerService(mockKYC, repository)
+	ctx := context.Background()
+
+	customer := &domain.Customer{Email: "john.doe@example.com"}
+	require.NoError(t, service.RegisterCustomer(ctx, customer))
+	assert.ErrorIs(t, service.VerifyRegisteredCustomer(ctx, 1, customer), domain.ErrKYCFailed)
+
+	// The rejection is not saved, so the pending rule applies to it.
+	stored, err := repository.FindByEmail(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", stored.KYCStatus)
+
+	engine := NewRetentionEngine(service, []RetentionRule{{Status: "pending", MaxAge: 30 * 24 * time.Hour}})
+	engine.now = func() time.Time { return time.Now().Add(31 * 24 * time.Hour) }
+	report, err := engine.Apply(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Erased)
+}
+
+func TestRetentionEngine(t *testing.T) {
+	for name, repository := range outboxRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+
+			customers := []*domain.Customer{
+				{ID: "abandoned", Email: "abandoned@example.com", KYCStatus: "pending", CreatedAt: now.Add(-40 * 24 * time.Hour)},
+				{ID: "recent", Email: "recent@example.com", KYCStatus: "pending", CreatedAt: now.Add(-time.Hour)},
+				{ID: "approved", Email: "approved@example.com", KYCStatus: "approved", CreatedAt: now.Add(-40 * 24 * time.Hour)},
+				{ID: "legacy", Email: "legacy@example.com", KYCStatus: "pending"},
+			}
+			for _, customer := range customers {
+				require.NoError(t, repository.Save(ctx, customer))
+			}
+
+			engine := NewRetentionEngine(NewCustomerService(nil, repository), []RetentionRule{
+				{Status: "pending", MaxAge: 30 * 24 * time.Hour},
+			})
+
+			report, err := engine.Apply(ctx, true)
+			require.NoError(t, err)
+			require.Len(t, report.Candidates, 1)
+			assert.Equal(t, "abandoned", report.Candidates[0].CustomerID)
+			assert.Zero(t, report.Erased)
+			_, err = repository.FindByEmail(ctx, "abandoned@example.com")
+			assert.NoError(t, err)
+
+			report, err = engine.Apply(ctx, false)
+			require.NoError(t, err)
+			assert.Equal(t, 1, report.Erased)
+			_, err = repository.FindByEmail(ctx, "abandoned@example.com")
+			assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+			for _, email := range []string{"recent@example.com", "approved@example.com", "legacy@example.com"} {
+				_, err = repository.FindByEmail(ctx, email)
+				assert.NoError(t, err, email)
+			}
+
+			// Erased customers are not reported again.
+			report, err = engine.Apply(ctx, true)
+			require.NoError(t, err)
+			assert.Empty(t, report.Candidates)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package application
+
+import (
+	"context"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// OutboxRepository is a CustomerRepository that can commit customer changes
+// together with the events describing them and keeps those events until
+// they are delivered.
+type OutboxRepository interface {
+	CustomerRepository
+	Commit(ctx context.Context, customers []*domain.Customer, events []domain.Event) error
+	PendingEvents(ctx context.Context, limit int) ([]domain.Event, error)
+	MarkDelivered(ctx context.Context, ids []string) error
+}
+
+// UnitOfWork collects customer changes and outgoing events and commits them
+// in one step, so an event is never lost for a change that was stored nor
+// published for one that was not.
+type UnitOfWork struct {
+	repository OutboxRepository
+	customers  []*domain.Customer
+	events     []domain.Event
+}
+
+func NewUnitOfWork(repository OutboxRepository) *UnitOfWork {
+	return &UnitOfWork{repository: repository}
+}
+
+// Save registers customer to be stored on Commit.
+func (u *UnitOfWork) Save(customer *domain.Customer) {
+	u.customers = append(u.customers, customer)
+}
+
+// Publish registers event to be added to the outbox on Commit.
+func (u *UnitOfWork) Publish(event domain.Event) {
+	u.events = append(u.events, event)
+}
+
+// Commit stores the registered changes and events atomically and resets the
+// unit of work.
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	customers, events := u.customers, u.events
+	u.customers, u.events = nil, nil
+
+	return u.repository.Commit(ctx, customers, events)
+}
+
+// save stores customer and, when the repository has an outbox, the event of
+// the given type in the same commit.
+func (s *CustomerService) save(ctx context.Context, customer *domain.Customer, eventType string) error {
+	repository, ok := s.customerRepository.(OutboxRepository)
+	if !ok {
+		return s.customerRepository.Save(ctx, customer)
+	}
+
+	uow := NewUnitOfWork(repository)
+	uow.Save(customer)
+	uow.Publish(domain.NewCustomerEvent(eventType, customer))
+	return uow.Commit(ctx)
+}
@@ -0,0 +1,111 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/macadrich/go-task-challenge/infra"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher keeps published events and fails once failAfter events
+// were published, if set.
+type recordingPublisher struct {
+	published []domain.Event
+	failAfter int
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event domain.Event) error {
+	if p.failAfter > 0 && len(p.published) >= p.failAfter {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func outboxRepositories(t *testing.T) map[string]OutboxRepository {
+	file, err := infra.OpenFileCustomerRepository(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+
+	return map[string]OutboxRepository{
+		"memory": infra.NewCustomerRepository(),
+		"file":   file,
+		"cache":  infra.NewCacheCustomerRepository(rediscache.NewRedisCache(4)),
+	}
+}
+
+func TestUnitOfWorkCommitsAtomically(t *testing.T) {
+	for name, repository := range outboxRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			existing := &domain.Customer{Email: "taken@example.com"}
+			require.NoError(t, repository.Save(ctx, existing))
+
+			// The stale second customer fails the whole unit of work.
+			uow := NewUnitOfWork(repository)
+			fresh := &domain.Customer{Email: "fresh@example.com"}
+			uow.Save(fresh)
+			uow.Publish(domain.NewCustomerEvent(domain.CustomerRegistered, fresh))
+			uow.Save(&domain.Customer{Email: "taken@example.com"})
+			assert.ErrorIs(t, uow.Commit(ctx), domain.ErrVersionConflict)
+
+			_, err := repository.FindByEmail(ctx, "fresh@example.com")
+			assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+			pending, err := repository.PendingEvents(ctx, 10)
+			require.NoError(t, err)
+			assert.Empty(t, pending)
+
+			uow.Save(fresh)
+			uow.Publish(domain.NewCustomerEvent(domain.CustomerRegistered, fresh))
+			require.NoError(t, uow.Commit(ctx))
+
+			_, err = repository.FindByEmail(ctx, "fresh@example.com")
+			assert.NoError(t, err)
+			pending, err = repository.PendingEvents(ctx, 10)
+			require.NoError(t, err)
+			assert.Len(t, pending, 1)
+		})
+	}
+}
+
+func TestOutboxRelayDeliversAtLeastOnce(t *testing.T) {
+	for name, repository := range outboxRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			service := NewCustomerService(&blockingKYCService{}, repository)
+			for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+				require.NoError(t, service.RegisterCustomer(ctx, &domain.Customer{Email: email}))
+			}
+
+			publisher := &recordingPublisher{failAfter: 2}
+			relay := NewOutboxRelay(repository, publisher, 0, 2)
+
+			delivered, err := relay.Flush(ctx)
+			assert.Error(t, err)
+			assert.Equal(t, 2, delivered)
+
+			third, err := repository.FindByEmail(ctx, "c@example.com")
+			require.NoError(t, err)
+			pending, err := repository.PendingEvents(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, pending, 1)
+			assert.Equal(t, third.ID, pending[0].CustomerID)
+
+			publisher.failAfter = 0
+			delivered, err = relay.Flush(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, delivered)
+			require.Len(t, publisher.published, 3)
+			assert.Equal(t, domain.CustomerRegistered, publisher.published[2].Type)
+
+			pending, err = repository.PendingEvents(ctx, 10)
+			require.NoError(t, err)
+			assert.Empty(t, pending)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/constants"
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobFinished  = errors.New("job already finished")
+	ErrJobQueueFull = errors.New("job queue is full")
+)
+
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Job is a point-in-time view of a background verification.
+type Job struct {
+	ID         string
+	TenantID   string
+	Email      string
+	State      JobState
+	Providers  int
+	Results    []domain.ProviderResult
+	Report     *domain.KYCReport
+	Err        error
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Finished reports whether the job reached a final state.
+func (j Job) Finished() bool {
+	return j.State == JobSucceeded || j.State == JobFailed || j.State == JobCancelled
+}
+
+type verificationJob struct {
+	Job
+	customer *domain.Customer
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// VerificationJobs runs customer verifications in the background on a fixed
+// pool of workers and keeps their progress. Finished jobs are dropped after
+// constants.FinishedJobTTL, and beyond constants.MaxFinishedJobs the oldest
+// finished ones go first; unfinished jobs are always kept.
+type VerificationJobs struct {
+	service *CustomerService
+	queue   chan *verificationJob
+
+	finishedTTL time.Duration
+	maxFinished int
+	now         func() time.Time
+
+	mu     sync.Mutex
+	jobs   map[string]*verificationJob
+	order  []string
+	nextID int
+}
+
+func NewVerificationJobs(service *CustomerService, workers, queueSize int) *VerificationJobs {
+	j := &VerificationJobs{
+		service:     service,
+		queue:       make(chan *verificationJob, queueSize),
+		finishedTTL: constants.FinishedJobTTL,
+		maxFinished: constants.MaxFinishedJobs,
+		now:         time.Now,
+		jobs:        make(map[string]*verificationJob),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range j.queue {
+				j.run(job)
+			}
+		}()
+	}
+	return j
+}
+
+// Submit queues a verification of customer against numRequest providers, or
+// as many as the tenant's KYC service is configured with, and returns the job
+// ID. Values carried by ctx are kept, but its cancellation is
+// not: use Cancel to stop the job.
+func (j *VerificationJobs) Submit(ctx context.Context, numRequest int, customer *domain.Customer) (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune()
+	j.nextID++
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	job := &verificationJob{
+		Job: Job{
+			ID:        fmt.Sprintf("job-%d", j.nextID),
+			TenantID:  domain.TenantFromContext(ctx),
+			Email:     customer.Email,
+			State:     JobQueued,
+			Providers: j.service.providersFor(ctx, numRequest),
+			CreatedAt: j.now(),
+		},
+		customer: customer,
+		ctx:      jobCtx,
+		cancel:   cancel,
+	}
+
+	select {
+	case j.queue <- job:
+	default:
+		cancel()
+		return "", ErrJobQueueFull
+	}
+
+	j.jobs[job.ID] = job
+	j.order = append(j.order, job.ID)
+	return job.ID, nil
+}
+
+// Status returns a snapshot of the job with the given ID. Jobs submitted by
+// another tenant fail with domain.ErrTenantMismatch.
+func (j *VerificationJobs) Status(ctx context.Context, id string) (Job, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune()
+	job, err := j.find(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return job.snapshot(), nil
+}
+
+// List returns snapshots of the tenant's jobs in submission order.
+func (j *VerificationJobs) List(ctx context.Context) []Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.prune()
+	tenantID := domain.TenantFromContext(ctx)
+	jobs := make([]Job, 0, len(j.order))
+	for _, id := range j.order {
+		if job := j.jobs[id]; job.TenantID == tenantID {
+			jobs = append(jobs, job.snapshot())
+		}
+	}
+	return jobs
+}
+
+// Cancel stops a queued or running job.
+func (j *VerificationJobs) Cancel(ctx context.Context, id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Finished() {
+		return ErrJobFinished
+	}
+
+	job.cancel()
+	if job.State == JobQueued {
+		job.State = JobCancelled
+		job.Err = context.Canceled
+		job.FinishedAt = j.now()
+	}
+	return nil
+}
+
+// Close stops the workers once the queued jobs are drained.
+func (j *VerificationJobs) Close() {
+	close(j.queue)
+}
+
+// prune drops finished jobs older than the TTL and then the oldest finished
+// jobs beyond the cap. It must be called with j.mu held.
+func (j *VerificationJobs) prune() {
+	expired := j.now().Add(-j.finishedTTL)
+	finished := 0
+	for _, id := range j.order {
+		if j.jobs[id].Finished() {
+			finished++
+		}
+	}
+
+	order := j.order[:0]
+	for _, id := range j.order {
+		job := j.jobs[id]
+		if job.Finished() && (job.FinishedAt.Before(expired) || finished > j.maxFinished) {
+			delete(j.jobs, id)
+			finished--
+			continue
+		}
+		order = append(order, id)
+	}
+	j.order = order
+}
+
+func (j *VerificationJobs) find(ctx context.Context, id string) (*verificationJob, error) {
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	if job.TenantID != domain.TenantFromContext(ctx) {
+		return nil, domain.ErrTenantMismatch
+	}
+	return job, nil
+}
+
+func (j *VerificationJobs) run(job *verificationJob) {
+	j.mu.Lock()
+	if job.State != JobQueued {
+		j.mu.Unlock()
+		return
+	}
+	job.State = JobRunning
+	j.mu.Unlock()
+
+	startedAt := j.now()
+	ctx := domain.WithKYCProgress(job.ctx, func(result domain.ProviderResult) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		// Providers abandoned by a cancelled job may still report late.
+		if !job.Finished() {
+			job.Results = append(job.Results, result)
+		}
+	})
+
+	err := j.service.VerifyRegisteredCustomer(ctx, job.Providers, job.customer)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job.Err = err
+	switch {
+	case err == nil:
+		job.State = JobSucceeded
+	case job.ctx.Err() != nil:
+		job.State = JobCancelled
+	default:
+		job.State = JobFailed
+	}
+	job.Report = &domain.KYCReport{
+		Email:      job.customer.Email,
+		Status:     job.customer.KYCStatus,
+		Providers:  append([]domain.ProviderResult(nil), job.Results...),
+		StartedAt:  startedAt,
+		FinishedAt: j.now(),
+	}
+	job.FinishedAt = job.Report.FinishedAt
+	job.cancel()
+}
+
+func (job *verificationJob) snapshot() Job {
+	snapshot := job.Job
+	snapshot.Results = append([]domain.ProviderResult(nil), job.Results...)
+	return snapshot
+}
@@ -0,0 +1,166 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/macadrich/go-task-challenge/infra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingKYCService reports every provider as approved and then waits until
+// release is closed or the context is cancelled.
+type blockingKYCService struct {
+	release chan struct{}
+}
+
+func (s *blockingKYCService) ValidateKYC(ctx context.Context, customer *domain.Customer) error {
+	return nil
+}
+
+func (s *blockingKYCService) VerifyCustomerKYC(ctx context.Context, numRequest int, customer *domain.Customer) error {
+	for i := 1; i <= numRequest; i++ {
+		domain.ReportKYCProgress(ctx, domain.ProviderResult{Provider: i, Status: "approved"})
+	}
+	select {
+	case <-s.release:
+		customer.KYCStatus = "approved"
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func waitForState(t *testing.T, jobs *VerificationJobs, id string, state JobState) Job {
+	t.Helper()
+	var job Job
+	require.Eventually(t, func() bool {
+		job, _ = jobs.Status(context.Background(), id)
+		return job.State == state
+	}, time.Second, time.Millisecond)
+	return job
+}
+
+func TestVerificationJobReport(t *testing.T) {
+	kyc := &blockingKYCService{release: make(chan struct{})}
+	service := NewCustomerService(kyc, infra.NewCustomerRepository())
+	jobs := NewVerificationJobs(service, 1, 10)
+	defer jobs.Close()
+
+	id, err := jobs.Submit(context.Background(), 3, &domain.Customer{Email: "john.doe@example.com"})
+	require.NoError(t, err)
+
+	job := waitForState(t, jobs, id, JobRunning)
+	assert.Nil(t, job.Report)
+
+	close(kyc.release)
+	job = waitForState(t, jobs, id, JobSucceeded)
+
+	assert.NoError(t, job.Err)
+	assert.Len(t, job.Results, 3)
+	require.NotNil(t, job.Report)
+	assert.Equal(t, "approved", job.Report.Status)
+	assert.Len(t, job.Report.Providers, 3)
+	assert.ErrorIs(t, jobs.Cancel(context.Background(), id), ErrJobFinished)
+}
+
+func TestVerificationJobCancel(t *testing.T) {
+	kyc := &blockingKYCService{release: make(chan struct{})}
+	service := NewCustomerService(kyc, infra.NewCustomerRepository())
+	jobs := NewVerificationJobs(service, 1, 10)
+	defer jobs.Close()
+
+	running, err := jobs.Submit(context.Background(), 1, &domain.Customer{Email: "a@example.com"})
+	require.NoError(t, err)
+	queued, err := jobs.Submit(context.Background(), 1, &domain.Customer{Email: "b@example.com"})
+	require.NoError(t, err)
+	waitForState(t, jobs, running, JobRunning)
+
+	require.NoError(t, jobs.Cancel(context.Background(), queued))
+	assert.Equal(t, JobCancelled, waitForState(t, jobs, queued, JobCancelled).State)
+
+	require.NoError(t, jobs.Cancel(context.Background(), running))
+	job := waitForState(t, jobs, running, JobCancelled)
+	assert.ErrorIs(t, job.Err, context.Canceled)
+
+	assert.Len(t, jobs.List(context.Background()), 2)
+	_, err = jobs.Status(context.Background(), "job-404")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestVerificationJobsDropFinishedJobs(t *testing.T) {
+	kyc := &blockingKYCService{release: make(chan struct{})}
+	service := NewCustomerService(kyc, infra.NewCustomerRepository())
+	jobs := NewVerificationJobs(service, 1, 10)
+	defer jobs.Close()
+
+	var mu sync.Mutex
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	jobs.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	jobs.maxFinished = 2
+	close(kyc.release)
+
+	// Beyond the cap the oldest finished jobs go first.
+	var ids []string
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		id, err := jobs.Submit(context.Background(), 1, &domain.Customer{Email: email})
+		require.NoError(t, err)
+		waitForState(t, jobs, id, JobSucceeded)
+		ids = append(ids, id)
+	}
+	_, err := jobs.Status(context.Background(), ids[0])
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.Len(t, jobs.List(context.Background()), 2)
+
+	// Finished jobs expire after the TTL.
+	mu.Lock()
+	now = now.Add(jobs.finishedTTL + time.Minute)
+	mu.Unlock()
+	assert.Empty(t, jobs.List(context.Background()))
+	_, err = jobs.Status(context.Background(), ids[2])
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+// fixedProvidersKYCService asks a fixed number of providers, whatever number
+// is requested.
+type fixedProvidersKYCService struct {
+	*blockingKYCService
+	providers int
+}
+
+func (s fixedProvidersKYCService) Providers(requested int) int {
+	return s.providers
+}
+
+func (s fixedProvidersKYCService) VerifyCustomerKYC(ctx context.Context, numRequest int, customer *domain.Customer) error {
+	return s.blockingKYCService.VerifyCustomerKYC(ctx, s.providers, customer)
+}
+
+func TestVerificationJobRecordsProvidersOfTenant(t *testing.T) {
+	kyc := &blockingKYCService{release: make(chan struct{})}
+	close(kyc.release)
+	service := NewCustomerService(kyc, infra.NewCustomerRepository(),
+		WithTenantKYCService("acme", fixedProvidersKYCService{kyc, 3}))
+	jobs := NewVerificationJobs(service, 1, 10)
+	defer jobs.Close()
+
+	ctx := domain.WithTenant(context.Background(), "acme")
+	id, err := jobs.Submit(ctx, 100, &domain.Customer{Email: "john.doe@example.com"})
+	require.NoError(t, err)
+
+	var job Job
+	require.Eventually(t, func() bool {
+		job, _ = jobs.Status(ctx, id)
+		return job.State == JobSucceeded
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 3, job.Providers)
+	assert.Len(t, job.Results, 3)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	documentEmail   string
+	documentType    string
+	documentFile    string
+	documentNumber  string
+	documentCountry string
+	documentExpiry  string
+)
+
+var attachDocumentCmd = &cobra.Command{
+	Use:   "attach-document",
+	Short: "Attach an identity document to a customer",
+	Long:  "This command stores a scanned identity document in the blob store and links it to a registered customer for KYC verification.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		expiry, err := time.Parse(time.DateOnly, documentExpiry)
+		if err != nil {
+			return fmt.Errorf("invalid expiry date, expected YYYY-MM-DD: %w", err)
+		}
+
+		file, err := os.Open(documentFile)
+		if err != nil {
+			return fmt.Errorf("failed to open document: %w", err)
+		}
+		defer file.Close()
+
+		document := domain.Document{
+			Type:           domain.DocumentType(documentType),
+			Number:         documentNumber,
+			IssuingCountry: documentCountry,
+			Expiry:         expiry,
+		}
+
+		customerService := newCustomerService()
+		customer, err := customerService.AttachDocument(commandContext(), documentEmail, document, file)
+		if err != nil {
+			return fmt.Errorf("failed to attach document: %w", err)
+		}
+
+		attached := customer.Documents[len(customer.Documents)-1]
+		cmd.Printf("Document %s attached to %s: sha256 %s, %s, %d bytes\n",
+			attached.Type, customer.Email, attached.BlobID, attached.MIMEType, attached.Size)
+		return nil
+	},
+}
+
+func init() {
+	attachDocumentCmd.Flags().StringVar(&documentEmail, "email", "", "Customer email")
+	attachDocumentCmd.Flags().StringVar(&documentType, "type", "", "Document type: passport, id_card or driving_license")
+	attachDocumentCmd.Flags().StringVar(&documentFile, "file", "", "Path to the scanned document")
+	attachDocumentCmd.Flags().StringVar(&documentNumber, "number", "", "Document number")
+	attachDocumentCmd.Flags().StringVar(&documentCountry, "country", "", "Issuing country")
+	attachDocumentCmd.Flags().StringVar(&documentExpiry, "expiry", "", "Expiry date (YYYY-MM-DD)")
+
+	attachDocumentCmd.MarkFlagRequired("email")
+	attachDocumentCmd.MarkFlagRequired("type")
+	attachDocumentCmd.MarkFlagRequired("file")
+	attachDocumentCmd.MarkFlagRequired("number")
+	attachDocumentCmd.MarkFlagRequired("country")
+	attachDocumentCmd.MarkFlagRequired("expiry")
+
+	rootCmd.AddCommand(attachDocumentCmd)
+}
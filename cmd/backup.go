@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/macadrich/go-task-challenge/infra"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupOut   string
+	restoreIn   string
+	restoreMode string
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up customers and cache keys to an archive",
+	Long:  "This command writes all customers of every tenant, whichever tenant is selected, and the cache keys that have not expired, with their types and remaining TTLs, to a compressed archive with a checksum manifest.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backup, err := newBackup()
+		if err != nil {
+			return err
+		}
+
+		// Write next to the destination and rename, so a failed backup does
+		// not leave a truncated archive behind.
+		file, err := os.CreateTemp(filepath.Dir(backupOut), ".backup-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+
+		manifest, err := backup.Write(commandContext(), file)
+		if err == nil {
+			err = file.Sync()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		if err := os.Rename(file.Name(), backupOut); err != nil {
+			return err
+		}
+
+		cmd.Printf("Backed up %d customers and %d keys to %s\n", manifest.Customers, manifest.Keys, backupOut)
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore customers and cache keys from an archive",
+	Long:  "This command validates a backup archive and restores its customers and cache keys. In merge mode existing entries win over archived ones, in replace mode archived entries win. Entries that are not in the archive are kept.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backup, err := newBackup()
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(restoreIn)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		report, err := backup.Restore(commandContext(), file, infra.RestoreMode(restoreMode))
+		if report != nil {
+			for _, conflict := range report.Conflicts {
+				cmd.Printf("Conflict on %s %s: %s\n", conflict.Kind, conflict.Name, conflict.Resolution)
+			}
+			cmd.Printf("Restored %d customers and %d keys, %d unchanged, %d conflicts\n",
+				report.Customers, report.Keys, report.Unchanged, len(report.Conflicts))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+		return nil
+	},
+}
+
+// newBackup returns a Backup of the session's customer store and cache.
+func newBackup() (*infra.Backup, error) {
+	repository, ok := customerRepository.(infra.BackupRepository)
+	if !ok {
+		return nil, fmt.Errorf("the customer store does not support backups")
+	}
+	return infra.NewBackup(repository, c, storeOptions()...), nil
+}
+
+func init() {
+	backupCmd.Flags().StringVar(&backupOut, "out", "", "Archive file to write")
+	backupCmd.MarkFlagRequired("out")
+
+	restoreCmd.Flags().StringVar(&restoreIn, "in", "", "Archive file to restore")
+	restoreCmd.Flags().StringVar(&restoreMode, "mode", string(infra.RestoreMerge), "Conflict handling: merge keeps existing entries, replace overwrites them")
+	restoreCmd.MarkFlagRequired("in")
+
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
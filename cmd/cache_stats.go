@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/macadrich/go-task-challenge/infra"
+	"github.com/spf13/cobra"
+)
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "cache-stats",
+	Short: "Show the hits and misses of the customer read cache",
+	Long:  "This command shows how many customer lookups by email were answered from the read cache enabled with --read-cache-ttl.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repository, ok := customerRepository.(interface{ Stats() infra.CacheStats })
+		if !ok {
+			return fmt.Errorf("the read cache is disabled, start with --read-cache-ttl")
+		}
+
+		stats := repository.Stats()
+		cmd.Printf("Read cache: %d hits, %d misses\n", stats.Hits, stats.Misses)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheStatsCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var eraseEmail string
+
+var eraseCmd = &cobra.Command{
+	Use:   "erase",
+	Short: "Erase the personal data of a customer",
+	Long:  "This command irreversibly erases the personal data and document files of a customer on request. A pseudonymized record with the customer ID, KYC status and timestamps is kept for audit.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		customerService := newCustomerService()
+		customer, err := customerService.EraseCustomer(commandContext(), eraseEmail)
+		if err != nil {
+			return err
+		}
+
+		cmd.Printf("Customer %s erased at %s\n", customer.ID, customer.ErasedAt.Format("2006-01-02 15:04:05"))
+		return nil
+	},
+}
+
+func init() {
+	eraseCmd.Flags().StringVar(&eraseEmail, "email", "", "Customer's email")
+	eraseCmd.MarkFlagRequired("email")
+
+	rootCmd.AddCommand(eraseCmd)
+}
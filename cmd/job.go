@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/macadrich/go-task-challenge/application"
+	"github.com/spf13/cobra"
+)
+
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Inspect background verification jobs",
+	Long:  "This command allows you to follow, list and cancel verifications submitted with 'verify --async'.",
+}
+
+var jobStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the progress of a verification job",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("'job status' requires a job ID")
+		}
+
+		job, err := verificationJobs.Status(commandContext(), args[0])
+		if err != nil {
+			return err
+		}
+
+		printJob(cmd, job)
+		for _, result := range job.Results {
+			if result.Err != nil {
+				cmd.Printf("  provider %d: error: %v (%s)\n", result.Provider, result.Err, result.Duration)
+			} else {
+				cmd.Printf("  provider %d: %s (%s)\n", result.Provider, result.Status, result.Duration)
+			}
+		}
+		if job.Report != nil {
+			cmd.Printf("Report: %s status %s, %d provider results in %s\n", job.Report.Email, job.Report.Status,
+				len(job.Report.Providers), job.Report.FinishedAt.Sub(job.Report.StartedAt))
+		}
+		return nil
+	},
+}
+
+var jobListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List verification jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		jobs := verificationJobs.List(commandContext())
+		if len(jobs) == 0 {
+			cmd.Println("No verification jobs")
+			return
+		}
+		for _, job := range jobs {
+			printJob(cmd, job)
+		}
+	},
+}
+
+var jobCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel a queued or running verification job",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("'job cancel' requires a job ID")
+		}
+
+		if err := verificationJobs.Cancel(commandContext(), args[0]); err != nil {
+			return err
+		}
+
+		cmd.Printf("Job %s cancelled\n", args[0])
+		return nil
+	},
+}
+
+func printJob(cmd *cobra.Command, job application.Job) {
+	cmd.Printf("%s %s: %s, %d/%d providers done", job.ID, job.Email, job.State, len(job.Results), job.Providers)
+	if job.Err != nil {
+		cmd.Printf(" (%v)", job.Err)
+	}
+	cmd.Println()
+}
+
+func init() {
+	jobCmd.AddCommand(jobStatusCmd)
+	jobCmd.AddCommand(jobListCmd)
+	jobCmd.AddCommand(jobCancelCmd)
+	rootCmd.AddCommand(jobCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/spf13/cobra"
+)
+
+var memoryStatsCmd = &cobra.Command{
+	Use:   "memory-stats",
+	Short: "Show the memory used by the cache and the keys it evicted",
+	Long:  "This command shows the approximate memory used by the cache, the limit set with --maxmemory, the eviction policy and how many keys were evicted or writes refused.",
+	Run: func(cmd *cobra.Command, args []string) {
+		stats := c.MemoryStats()
+		limit := "unlimited"
+		if stats.MaxMemory > 0 {
+			limit = fmt.Sprintf("%d bytes", stats.MaxMemory)
+		}
+		fmt.Printf("Used memory: %d bytes\n", stats.UsedMemory)
+		fmt.Printf("Max memory: %s\n", limit)
+		fmt.Printf("Policy: %s\n", stats.Policy)
+		fmt.Printf("Keys: %d\n", stats.Keys)
+		fmt.Printf("Evicted keys: %d\n", stats.EvictedKeys)
+		fmt.Printf("Rejected writes: %d\n", stats.RejectedWrites)
+	},
+}
+
+// memoryUnits are the suffixes accepted by parseMemorySize, like in the Redis
+// configuration file.
+var memoryUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"gb", 1 << 30},
+	{"mb", 1 << 20},
+	{"kb", 1 << 10},
+	{"g", 1000 * 1000 * 1000},
+	{"m", 1000 * 1000},
+	{"k", 1000},
+	{"b", 1},
+}
+
+// parseMemorySize parses a size such as 64mb or 1gb into bytes.
+func parseMemorySize(size string) (int64, error) {
+	number, unit := strings.ToLower(strings.TrimSpace(size)), int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSuffix(number, u.suffix), u.bytes
+			break
+		}
+	}
+	value, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid memory size %q, expected e.g. 64mb", size)
+	}
+	return value * unit, nil
+}
+
+// configureCache applies the memory limit and eviction policy given on the
+// command line to the cache.
+func configureCache() error {
+	limit, err := parseMemorySize(maxMemory)
+	if err != nil {
+		return err
+	}
+	policy, err := rediscache.ParseEvictionPolicy(maxMemoryPolicy)
+	if err != nil {
+		return err
+	}
+	c.SetMaxMemory(limit)
+	c.SetEvictionPolicy(policy)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(memoryStatsCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// migrator is implemented by customer stores that keep versioned records.
+type migrator interface {
+	Migrate(context.Context) (int, error)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade stored customer records to the current schema",
+	Long:  "This command rewrites customer records stored with an older schema version. Old records are also upgraded when they are read, so running it is only needed to update the stored data.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repository, ok := customerRepository.(migrator)
+		if !ok {
+			return fmt.Errorf("the customer store does not keep versioned records")
+		}
+
+		count, err := repository.Migrate(context.Background())
+		if err != nil {
+			return fmt.Errorf("migration stopped after %d records: %w", count, err)
+		}
+		cmd.Printf("Rewrote %d customer records at the current schema version\n", count)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
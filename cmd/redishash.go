@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var hsetCmd = &cobra.Command{
+	Use:   "hset",
+	Short: "Set fields of a hash",
+	Long:  "This command allows you to set one or more fields of the hash stored under a key, given as field value field value and so on.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 || len(args)%2 != 1 {
+			fmt.Println("Error: 'hset' requires a key and pairs of fields and values")
+			return
+		}
+
+		fields := make(map[string]interface{}, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			fields[args[i]] = args[i+1]
+		}
+
+		added, err := c.HSet(args[0], fields)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Hash '%s' updated, %d new fields\n", args[0], added)
+	},
+}
+
+var hgetCmd = &cobra.Command{
+	Use:   "hget",
+	Short: "Get the value of a hash field",
+	Long:  "This command allows you to get the value of a field of the hash stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'hget' requires a key and a field")
+			return
+		}
+
+		value, err := c.HGet(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printField(args[0], args[1], value)
+	},
+}
+
+var hmgetCmd = &cobra.Command{
+	Use:   "hmget",
+	Short: "Get the values of several hash fields",
+	Long:  "This command allows you to get the values of several fields of the hash stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'hmget' requires a key and at least one field")
+			return
+		}
+
+		values, err := c.HMGet(args[0], args[1:]...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		for i, value := range values {
+			printField(args[0], args[i+1], value)
+		}
+	},
+}
+
+var hgetallCmd = &cobra.Command{
+	Use:   "hgetall",
+	Short: "Get all fields and values of a hash",
+	Long:  "This command allows you to get every field and value of the hash stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'hgetall' requires a key")
+			return
+		}
+
+		fields, err := c.HGetAll(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(fields) == 0 {
+			fmt.Printf("Hash '%s' not found or expired\n", args[0])
+			return
+		}
+
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s: %v\n", name, fields[name])
+		}
+	},
+}
+
+var hdelCmd = &cobra.Command{
+	Use:   "hdel",
+	Short: "Delete fields of a hash",
+	Long:  "This command allows you to delete one or more fields of the hash stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'hdel' requires a key and at least one field")
+			return
+		}
+
+		removed, err := c.HDel(args[0], args[1:]...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Deleted %d of %d fields\n", removed, len(args)-1)
+	},
+}
+
+var hexistsCmd = &cobra.Command{
+	Use:   "hexists",
+	Short: "Check whether a hash field exists",
+	Long:  "This command allows you to check whether the hash stored under a key has a field.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'hexists' requires a key and a field")
+			return
+		}
+
+		found, err := c.HExists(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if found {
+			fmt.Printf("Field '%s' exists in hash '%s'\n", args[1], args[0])
+		} else {
+			fmt.Printf("Field '%s' not found in hash '%s'\n", args[1], args[0])
+		}
+	},
+}
+
+var hlenCmd = &cobra.Command{
+	Use:   "hlen",
+	Short: "Get the number of fields of a hash",
+	Long:  "This command allows you to get the number of fields of the hash stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'hlen' requires a key")
+			return
+		}
+
+		length, err := c.HLen(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Hash '%s' has %d fields\n", args[0], length)
+	},
+}
+
+var hincrByCmd = &cobra.Command{
+	Use:   "hincrby",
+	Short: "Increment the integer value of a hash field",
+	Long:  "This command allows you to atomically add an amount to the integer stored in a field of a hash. A missing field starts at zero.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 {
+			fmt.Println("Error: 'hincrby' requires a key, a field and an amount")
+			return
+		}
+
+		amount, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			fmt.Printf("Error: invalid amount '%s'\n", args[2])
+			return
+		}
+
+		result, err := c.HIncrBy(args[0], args[1], amount)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printField(args[0], args[1], result)
+	},
+}
+
+var hkeysCmd = &cobra.Command{
+	Use:   "hkeys",
+	Short: "Get the fields of a hash",
+	Long:  "This command allows you to get the names of the fields of the hash stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'hkeys' requires a key")
+			return
+		}
+
+		fields, err := c.HKeys(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(fields) == 0 {
+			fmt.Printf("Hash '%s' not found or expired\n", args[0])
+			return
+		}
+		for i, field := range fields {
+			fmt.Printf("%d) %s\n", i+1, field)
+		}
+	},
+}
+
+var hvalsCmd = &cobra.Command{
+	Use:   "hvals",
+	Short: "Get the values of a hash",
+	Long:  "This command allows you to get the values of the fields of the hash stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'hvals' requires a key")
+			return
+		}
+
+		values, err := c.HVals(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(values) == 0 {
+			fmt.Printf("Hash '%s' not found or expired\n", args[0])
+			return
+		}
+		for i, value := range values {
+			fmt.Printf("%d) %v\n", i+1, value)
+		}
+	},
+}
+
+func printField(key, field string, value interface{}) {
+	if value != nil {
+		fmt.Printf("Value for field '%s' of hash '%s': %v\n", field, key, value)
+	} else {
+		fmt.Printf("Field '%s' not found in hash '%s'\n", field, key)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(hsetCmd)
+	rootCmd.AddCommand(hgetCmd)
+	rootCmd.AddCommand(hmgetCmd)
+	rootCmd.AddCommand(hgetallCmd)
+	rootCmd.AddCommand(hdelCmd)
+	rootCmd.AddCommand(hexistsCmd)
+	rootCmd.AddCommand(hlenCmd)
+	rootCmd.AddCommand(hincrByCmd)
+	rootCmd.AddCommand(hkeysCmd)
+	rootCmd.AddCommand(hvalsCmd)
+}
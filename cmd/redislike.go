@@ -2,16 +2,33 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
 	"github.com/spf13/cobra"
 )
 
-var ttl int
+var (
+	ttl     int
+	keepTTL bool
+	setNX   bool
+	setXX   bool
+	setGet  bool
+
+	getExTTL     int
+	getExPTTL    int
+	getExAt      int64
+	getExPersist bool
+)
 
 var c = rediscache.NewRedisCache(5)
 
+// storeCache holds the customers of --store cache and the idempotency keys.
+// It is kept apart from the cache of the REPL so that --maxmemory never
+// evicts them.
+var storeCache = rediscache.NewRedisCache(5)
+
 var setCmd = &cobra.Command{
 	Use:   "set",
 	Short: "Set a key-value pair in the cache",
@@ -32,7 +49,24 @@ var setCmd = &cobra.Command{
 			ttlDuration = 0
 		}
 
-		c.Set(key, value, ttlDuration)
+		previous, stored, err := c.SetWithOptions(key, value, rediscache.SetOptions{
+			TTL:     ttlDuration,
+			KeepTTL: keepTTL,
+			NX:      setNX,
+			XX:      setXX,
+			Get:     setGet,
+		})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if setGet {
+			printPrevious(key, previous)
+		}
+		if !stored {
+			fmt.Printf("Key '%s' not set\n", key)
+			return
+		}
 		fmt.Printf("Key '%s' set successfully with value '%s'\n", key, value)
 	},
 }
@@ -48,19 +82,486 @@ var getCmd = &cobra.Command{
 		}
 
 		key := args[0]
-		value := c.Get(key)
+		value, err := c.Get(key)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printValue(key, value)
+	},
+}
+
+var delCmd = &cobra.Command{
+	Use:   "del",
+	Short: "Delete one or more keys from the cache",
+	Long:  "This command allows you to delete keys from the cache and shows how many of them existed.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'del' requires at least one key")
+			return
+		}
+
+		removed := c.Del(args...)
+		fmt.Printf("Deleted %d of %d keys\n", removed, len(args))
+	},
+}
+
+var existsCmd = &cobra.Command{
+	Use:   "exists",
+	Short: "Check whether keys exist in the cache",
+	Long:  "This command allows you to count how many of the given keys exist in the cache. A key given several times is counted every time.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'exists' requires at least one key")
+			return
+		}
+
+		count := c.Exists(args...)
+		fmt.Printf("%d of %d keys exist\n", count, len(args))
+	},
+}
+
+var expireCmd = &cobra.Command{
+	Use:   "expire",
+	Short: "Set the TTL of a key in seconds",
+	Long:  "This command allows you to change the TTL (Time-To-Live) of an existing key in seconds. A TTL that is not positive deletes the key.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		runExpire("expire", args, time.Second)
+	},
+}
+
+var pexpireCmd = &cobra.Command{
+	Use:   "pexpire",
+	Short: "Set the TTL of a key in milliseconds",
+	Long:  "This command allows you to change the TTL (Time-To-Live) of an existing key in milliseconds. A TTL that is not positive deletes the key.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		runExpire("pexpire", args, time.Millisecond)
+	},
+}
+
+func runExpire(name string, args []string, unit time.Duration) {
+	if len(args) < 2 {
+		fmt.Printf("Error: '%s' requires a key and a TTL\n", name)
+		return
+	}
+
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		fmt.Printf("Error: invalid TTL '%s'\n", args[1])
+		return
+	}
+
+	printExpiry(args[0], c.Expire(args[0], time.Duration(amount)*unit))
+}
+
+var expireAtCmd = &cobra.Command{
+	Use:   "expireat",
+	Short: "Set the expiry of a key as a Unix timestamp in seconds",
+	Long:  "This command allows you to make an existing key expire at a Unix timestamp in seconds. A timestamp in the past deletes the key.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'expireat' requires a key and a Unix timestamp")
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fmt.Printf("Error: invalid timestamp '%s'\n", args[1])
+			return
+		}
+
+		printExpiry(args[0], c.ExpireAt(args[0], time.Unix(timestamp, 0)))
+	},
+}
+
+func printExpiry(key string, found bool) {
+	if found {
+		fmt.Printf("Expiry of key '%s' updated\n", key)
+	} else {
+		fmt.Printf("Key '%s' not found or expired\n", key)
+	}
+}
+
+var ttlCmd = &cobra.Command{
+	Use:   "ttl",
+	Short: "Get the remaining TTL of a key in seconds",
+	Long:  "This command allows you to get the remaining TTL (Time-To-Live) of a key in seconds. It prints -1 for a key without expiry and -2 for a missing key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runTTL("ttl", args, time.Second)
+	},
+}
+
+var pttlCmd = &cobra.Command{
+	Use:   "pttl",
+	Short: "Get the remaining TTL of a key in milliseconds",
+	Long:  "This command allows you to get the remaining TTL (Time-To-Live) of a key in milliseconds. It prints -1 for a key without expiry and -2 for a missing key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runTTL("pttl", args, time.Millisecond)
+	},
+}
+
+func runTTL(name string, args []string, unit time.Duration) {
+	if len(args) < 1 {
+		fmt.Printf("Error: '%s' requires a key\n", name)
+		return
+	}
+
+	remaining := c.TTL(args[0])
+	switch remaining {
+	case rediscache.NoExpiry:
+		fmt.Println(-1)
+	case rediscache.KeyMissing:
+		fmt.Println(-2)
+	default:
+		// Round to the nearest unit like Redis does.
+		fmt.Println(int64((remaining + unit/2) / unit))
+	}
+}
+
+var persistCmd = &cobra.Command{
+	Use:   "persist",
+	Short: "Remove the TTL of a key",
+	Long:  "This command allows you to remove the TTL (Time-To-Live) of a key so that it no longer expires.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'persist' requires a key")
+			return
+		}
+
+		if c.Persist(args[0]) {
+			fmt.Printf("Key '%s' no longer expires\n", args[0])
+		} else {
+			fmt.Printf("Key '%s' not found or has no TTL\n", args[0])
+		}
+	},
+}
+
+var incrCmd = &cobra.Command{
+	Use:   "incr",
+	Short: "Increment the integer value of a key by one",
+	Long:  "This command allows you to atomically add one to the integer stored under a key. A missing key starts at zero.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'incr' requires a key")
+			return
+		}
+
+		printCounter(args[0])(c.Incr(args[0]))
+	},
+}
+
+var decrCmd = &cobra.Command{
+	Use:   "decr",
+	Short: "Decrement the integer value of a key by one",
+	Long:  "This command allows you to atomically subtract one from the integer stored under a key. A missing key starts at zero.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'decr' requires a key")
+			return
+		}
+
+		printCounter(args[0])(c.Decr(args[0]))
+	},
+}
+
+var incrByCmd = &cobra.Command{
+	Use:   "incrby",
+	Short: "Increment the integer value of a key by a given amount",
+	Long:  "This command allows you to atomically add an amount to the integer stored under a key. A missing key starts at zero.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		runIncrBy("incrby", args, c.IncrBy)
+	},
+}
+
+var decrByCmd = &cobra.Command{
+	Use:   "decrby",
+	Short: "Decrement the integer value of a key by a given amount",
+	Long:  "This command allows you to atomically subtract an amount from the integer stored under a key. A missing key starts at zero.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		runIncrBy("decrby", args, c.DecrBy)
+	},
+}
+
+func runIncrBy(name string, args []string, apply func(string, int64) (int64, error)) {
+	if len(args) < 2 {
+		fmt.Printf("Error: '%s' requires a key and an amount\n", name)
+		return
+	}
 
-		if value != nil {
-			fmt.Printf("Value for key '%s': %v\n", key, value)
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		fmt.Printf("Error: invalid amount '%s'\n", args[1])
+		return
+	}
+
+	printCounter(args[0])(apply(args[0], amount))
+}
+
+var incrByFloatCmd = &cobra.Command{
+	Use:   "incrbyfloat",
+	Short: "Increment the numeric value of a key by a floating point amount",
+	Long:  "This command allows you to atomically add a floating point amount to the number stored under a key. A missing key starts at zero.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'incrbyfloat' requires a key and an amount")
+			return
+		}
+
+		amount, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			fmt.Printf("Error: invalid amount '%s'\n", args[1])
+			return
+		}
+
+		result, err := c.IncrByFloat(args[0], amount)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Value for key '%s': %s\n", args[0], strconv.FormatFloat(result, 'f', -1, 64))
+	},
+}
+
+// printCounter returns a function printing the result of an integer counter
+// command on key.
+func printCounter(key string) func(int64, error) {
+	return func(result int64, err error) {
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Value for key '%s': %d\n", key, result)
+	}
+}
+
+var setNXCmd = &cobra.Command{
+	Use:   "setnx",
+	Short: "Set a key only if it does not exist",
+	Long:  "This command allows you to set a key-value pair in the cache only when the key does not exist yet.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'setnx' requires a key and a value")
+			return
+		}
+
+		stored, err := c.SetNX(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if stored {
+			fmt.Printf("Key '%s' set successfully with value '%s'\n", args[0], args[1])
 		} else {
-			fmt.Printf("Key '%s' not found or expired\n", key)
+			fmt.Printf("Key '%s' already exists\n", args[0])
 		}
 	},
 }
 
+var getSetCmd = &cobra.Command{
+	Use:   "getset",
+	Short: "Set a key and return its previous value",
+	Long:  "This command allows you to set a key-value pair in the cache and shows the value the key had before.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'getset' requires a key and a value")
+			return
+		}
+
+		previous, err := c.GetSet(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printPrevious(args[0], previous)
+	},
+}
+
+var getDelCmd = &cobra.Command{
+	Use:   "getdel",
+	Short: "Get the value of a key and delete it",
+	Long:  "This command allows you to get the value of a key and delete the key in one step.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'getdel' requires a key")
+			return
+		}
+
+		value, err := c.GetDel(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printValue(args[0], value)
+	},
+}
+
+var getExCmd = &cobra.Command{
+	Use:   "getex",
+	Short: "Get the value of a key and change its TTL",
+	Long:  "This command allows you to get the value of a key and set its TTL in seconds or milliseconds, make it expire at a Unix timestamp, or remove its TTL.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'getex' requires a key")
+			return
+		}
+
+		var opts rediscache.GetExOptions
+		switch {
+		case getExTTL > 0 && getExPTTL > 0:
+			fmt.Println("Error: '--ex' and '--px' cannot be used together")
+			return
+		case getExTTL > 0:
+			opts.TTL = time.Duration(getExTTL) * time.Second
+		case getExPTTL > 0:
+			opts.TTL = time.Duration(getExPTTL) * time.Millisecond
+		}
+		if getExAt > 0 {
+			opts.At = time.Unix(getExAt, 0)
+		}
+		opts.Persist = getExPersist
+
+		value, err := c.GetEx(args[0], opts)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printValue(args[0], value)
+	},
+}
+
+var msetCmd = &cobra.Command{
+	Use:   "mset",
+	Short: "Set several key-value pairs at once",
+	Long:  "This command allows you to set several key-value pairs in the cache at once, given as key value key value and so on.",
+	Run: func(cmd *cobra.Command, args []string) {
+		values, ok := keyValuePairs("mset", args)
+		if !ok {
+			return
+		}
+
+		if err := c.MSet(values); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("%d keys set successfully\n", len(values))
+	},
+}
+
+var msetNXCmd = &cobra.Command{
+	Use:   "msetnx",
+	Short: "Set several key-value pairs only if none of the keys exists",
+	Long:  "This command allows you to set several key-value pairs in the cache at once. Nothing is set when any of the keys already exists.",
+	Run: func(cmd *cobra.Command, args []string) {
+		values, ok := keyValuePairs("msetnx", args)
+		if !ok {
+			return
+		}
+
+		stored, err := c.MSetNX(values)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if stored {
+			fmt.Printf("%d keys set successfully\n", len(values))
+		} else {
+			fmt.Println("No keys set: at least one key already exists")
+		}
+	},
+}
+
+// keyValuePairs reads the alternating keys and values given to name. Later
+// values win when a key is given twice.
+func keyValuePairs(name string, args []string) (map[string]interface{}, bool) {
+	if len(args) < 2 || len(args)%2 != 0 {
+		fmt.Printf("Error: '%s' requires pairs of keys and values\n", name)
+		return nil, false
+	}
+
+	values := make(map[string]interface{}, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		values[args[i]] = args[i+1]
+	}
+	return values, true
+}
+
+var mgetCmd = &cobra.Command{
+	Use:   "mget",
+	Short: "Get the values of several keys",
+	Long:  "This command allows you to get the values of several keys stored in the cache at once.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'mget' requires at least one key")
+			return
+		}
+
+		for i, value := range c.MGet(args...) {
+			printValue(args[i], value)
+		}
+	},
+}
+
+func printValue(key string, value interface{}) {
+	if value != nil {
+		fmt.Printf("Value for key '%s': %v\n", key, value)
+	} else {
+		fmt.Printf("Key '%s' not found or expired\n", key)
+	}
+}
+
+func printPrevious(key string, previous interface{}) {
+	if previous != nil {
+		fmt.Printf("Previous value for key '%s': %v\n", key, previous)
+	} else {
+		fmt.Printf("Key '%s' had no previous value\n", key)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(setCmd)
 	setCmd.Flags().IntVarP(&ttl, "ttl", "t", 0, "Time-to-live for the key in seconds")
+	setCmd.Flags().BoolVar(&keepTTL, "keepttl", false, "Keep the time-to-live the key already has")
+	setCmd.Flags().BoolVar(&setNX, "nx", false, "Only set the key if it does not exist")
+	setCmd.Flags().BoolVar(&setXX, "xx", false, "Only set the key if it already exists")
+	setCmd.Flags().BoolVar(&setGet, "get", false, "Show the previous value of the key")
 
 	rootCmd.AddCommand(getCmd)
+	rootCmd.AddCommand(delCmd)
+	rootCmd.AddCommand(existsCmd)
+	rootCmd.AddCommand(expireCmd)
+	rootCmd.AddCommand(pexpireCmd)
+	rootCmd.AddCommand(expireAtCmd)
+	rootCmd.AddCommand(ttlCmd)
+	rootCmd.AddCommand(pttlCmd)
+	rootCmd.AddCommand(persistCmd)
+	rootCmd.AddCommand(incrCmd)
+	rootCmd.AddCommand(decrCmd)
+	rootCmd.AddCommand(incrByCmd)
+	rootCmd.AddCommand(decrByCmd)
+	rootCmd.AddCommand(incrByFloatCmd)
+	rootCmd.AddCommand(setNXCmd)
+	rootCmd.AddCommand(getSetCmd)
+	rootCmd.AddCommand(getDelCmd)
+
+	rootCmd.AddCommand(getExCmd)
+	getExCmd.Flags().IntVar(&getExTTL, "ex", 0, "New time-to-live for the key in seconds")
+	getExCmd.Flags().IntVar(&getExPTTL, "px", 0, "New time-to-live for the key in milliseconds")
+	getExCmd.Flags().Int64Var(&getExAt, "exat", 0, "Unix timestamp in seconds at which the key expires")
+	getExCmd.Flags().BoolVar(&getExPersist, "persist", false, "Remove the time-to-live of the key")
+
+	rootCmd.AddCommand(msetCmd)
+	rootCmd.AddCommand(msetNXCmd)
+	rootCmd.AddCommand(mgetCmd)
 }
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var lpushCmd = &cobra.Command{
+	Use:   "lpush",
+	Short: "Insert values at the head of a list",
+	Long:  "This command allows you to insert one or more values at the head of the list stored under a key. The last value ends up first.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runPush("lpush", args, c.LPush)
+	},
+}
+
+var rpushCmd = &cobra.Command{
+	Use:   "rpush",
+	Short: "Append values to a list",
+	Long:  "This command allows you to append one or more values to the list stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runPush("rpush", args, c.RPush)
+	},
+}
+
+func runPush(name string, args []string, push func(string, ...interface{}) (int, error)) {
+	if len(args) < 2 {
+		fmt.Printf("Error: '%s' requires a key and at least one value\n", name)
+		return
+	}
+
+	values := make([]interface{}, len(args)-1)
+	for i, value := range args[1:] {
+		values[i] = value
+	}
+
+	length, err := push(args[0], values...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("List '%s' now has %d items\n", args[0], length)
+}
+
+var lpopCmd = &cobra.Command{
+	Use:   "lpop",
+	Short: "Remove and get the first item of a list",
+	Long:  "This command allows you to remove and get the first item of the list stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runPop("lpop", args, c.LPop)
+	},
+}
+
+var rpopCmd = &cobra.Command{
+	Use:   "rpop",
+	Short: "Remove and get the last item of a list",
+	Long:  "This command allows you to remove and get the last item of the list stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runPop("rpop", args, c.RPop)
+	},
+}
+
+func runPop(name string, args []string, pop func(string) (interface{}, error)) {
+	if len(args) < 1 {
+		fmt.Printf("Error: '%s' requires a key\n", name)
+		return
+	}
+
+	value, err := pop(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	printItem(args[0], value)
+}
+
+var llenCmd = &cobra.Command{
+	Use:   "llen",
+	Short: "Get the length of a list",
+	Long:  "This command allows you to get the number of items in the list stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'llen' requires a key")
+			return
+		}
+
+		length, err := c.LLen(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("List '%s' has %d items\n", args[0], length)
+	},
+}
+
+var lrangeCmd = &cobra.Command{
+	Use:   "lrange",
+	Short: "Get a range of items from a list",
+	Long:  "This command allows you to get the items of a list from a start to a stop index, both included. Negative indexes count from the end of the list.",
+	// Indexes may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 {
+			fmt.Println("Error: 'lrange' requires a key, a start and a stop index")
+			return
+		}
+
+		start, stop, ok := parseIndexes(args[1], args[2])
+		if !ok {
+			return
+		}
+
+		items, err := c.LRange(args[0], start, stop)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(items) == 0 {
+			fmt.Printf("List '%s' has no items in that range\n", args[0])
+			return
+		}
+		for i, item := range items {
+			fmt.Printf("%d) %v\n", i+1, item)
+		}
+	},
+}
+
+var lindexCmd = &cobra.Command{
+	Use:   "lindex",
+	Short: "Get an item of a list by its index",
+	Long:  "This command allows you to get the item at an index of the list stored under a key. Negative indexes count from the end of the list.",
+	// Indexes may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'lindex' requires a key and an index")
+			return
+		}
+
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Printf("Error: invalid index '%s'\n", args[1])
+			return
+		}
+
+		value, err := c.LIndex(args[0], index)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printItem(args[0], value)
+	},
+}
+
+var lremCmd = &cobra.Command{
+	Use:   "lrem",
+	Short: "Remove items equal to a value from a list",
+	Long:  "This command allows you to remove items equal to a value from a list. A positive count removes that many from the head, a negative count from the tail and zero removes all of them.",
+	// The count may be negative, so it must not be parsed as a flag.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 {
+			fmt.Println("Error: 'lrem' requires a key, a count and a value")
+			return
+		}
+
+		count, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Printf("Error: invalid count '%s'\n", args[1])
+			return
+		}
+
+		removed, err := c.LRem(args[0], count, args[2])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Removed %d items from list '%s'\n", removed, args[0])
+	},
+}
+
+var ltrimCmd = &cobra.Command{
+	Use:   "ltrim",
+	Short: "Trim a list to a range of items",
+	Long:  "This command allows you to keep only the items of a list from a start to a stop index, both included. Negative indexes count from the end of the list.",
+	// Indexes may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 {
+			fmt.Println("Error: 'ltrim' requires a key, a start and a stop index")
+			return
+		}
+
+		start, stop, ok := parseIndexes(args[1], args[2])
+		if !ok {
+			return
+		}
+
+		if err := c.LTrim(args[0], start, stop); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("List '%s' trimmed successfully\n", args[0])
+	},
+}
+
+var blpopCmd = &cobra.Command{
+	Use:   "blpop",
+	Short: "Remove and get the first item of a list, waiting for one if needed",
+	Long:  "This command allows you to remove and get the first item of the first non-empty list among the given keys. When all lists are empty it waits up to the timeout in seconds given last, or forever when it is 0.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runBlockingPop("blpop", args, c.BLPop)
+	},
+}
+
+var brpopCmd = &cobra.Command{
+	Use:   "brpop",
+	Short: "Remove and get the last item of a list, waiting for one if needed",
+	Long:  "This command allows you to remove and get the last item of the first non-empty list among the given keys. When all lists are empty it waits up to the timeout in seconds given last, or forever when it is 0.",
+	Run: func(cmd *cobra.Command, args []string) {
+		runBlockingPop("brpop", args, c.BRPop)
+	},
+}
+
+func runBlockingPop(name string, args []string, pop func(time.Duration, ...string) (string, interface{}, error)) {
+	if len(args) < 2 {
+		fmt.Printf("Error: '%s' requires at least one key and a timeout\n", name)
+		return
+	}
+
+	seconds, err := strconv.ParseFloat(args[len(args)-1], 64)
+	if err != nil {
+		fmt.Printf("Error: invalid timeout '%s'\n", args[len(args)-1])
+		return
+	}
+
+	key, value, err := pop(time.Duration(seconds*float64(time.Second)), args[:len(args)-1]...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if key == "" {
+		fmt.Println("Timed out waiting for an item")
+		return
+	}
+	fmt.Printf("Item from list '%s': %v\n", key, value)
+}
+
+func parseIndexes(startArg, stopArg string) (int, int, bool) {
+	start, err := strconv.Atoi(startArg)
+	if err != nil {
+		fmt.Printf("Error: invalid index '%s'\n", startArg)
+		return 0, 0, false
+	}
+	stop, err := strconv.Atoi(stopArg)
+	if err != nil {
+		fmt.Printf("Error: invalid index '%s'\n", stopArg)
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+func printItem(key string, value interface{}) {
+	if value != nil {
+		fmt.Printf("Item from list '%s': %v\n", key, value)
+	} else {
+		fmt.Printf("List '%s' is empty or has no such item\n", key)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(lpushCmd)
+	rootCmd.AddCommand(rpushCmd)
+	rootCmd.AddCommand(lpopCmd)
+	rootCmd.AddCommand(rpopCmd)
+	rootCmd.AddCommand(llenCmd)
+	rootCmd.AddCommand(lrangeCmd)
+	rootCmd.AddCommand(lindexCmd)
+	rootCmd.AddCommand(lremCmd)
+	rootCmd.AddCommand(ltrimCmd)
+	rootCmd.AddCommand(blpopCmd)
+	rootCmd.AddCommand(brpopCmd)
+}
@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/spf13/cobra"
+)
+
+var (
+	zaddNX bool
+	zaddXX bool
+	zaddGT bool
+	zaddLT bool
+)
+
+var saddCmd = &cobra.Command{
+	Use:   "sadd",
+	Short: "Add members to a set",
+	Long:  "This command allows you to add one or more members to the set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'sadd' requires a key and at least one member")
+			return
+		}
+
+		added, err := c.SAdd(args[0], args[1:]...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Added %d of %d members to set '%s'\n", added, len(args)-1, args[0])
+	},
+}
+
+var sremCmd = &cobra.Command{
+	Use:   "srem",
+	Short: "Remove members from a set",
+	Long:  "This command allows you to remove one or more members from the set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'srem' requires a key and at least one member")
+			return
+		}
+
+		removed, err := c.SRem(args[0], args[1:]...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Removed %d of %d members from set '%s'\n", removed, len(args)-1, args[0])
+	},
+}
+
+var smembersCmd = &cobra.Command{
+	Use:   "smembers",
+	Short: "Get the members of a set",
+	Long:  "This command allows you to get the members of the set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'smembers' requires a key")
+			return
+		}
+
+		printMembers(c.SMembers(args[0]))
+	},
+}
+
+var sismemberCmd = &cobra.Command{
+	Use:   "sismember",
+	Short: "Check whether a value is a member of a set",
+	Long:  "This command allows you to check whether a value is a member of the set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'sismember' requires a key and a member")
+			return
+		}
+
+		found, err := c.SIsMember(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if found {
+			fmt.Printf("'%s' is a member of set '%s'\n", args[1], args[0])
+		} else {
+			fmt.Printf("'%s' is not a member of set '%s'\n", args[1], args[0])
+		}
+	},
+}
+
+var scardCmd = &cobra.Command{
+	Use:   "scard",
+	Short: "Get the number of members of a set",
+	Long:  "This command allows you to get the number of members of the set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'scard' requires a key")
+			return
+		}
+
+		length, err := c.SCard(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Set '%s' has %d members\n", args[0], length)
+	},
+}
+
+var sinterCmd = &cobra.Command{
+	Use:   "sinter",
+	Short: "Get the members that are in every given set",
+	Long:  "This command allows you to get the intersection of the sets stored under the given keys.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'sinter' requires at least one key")
+			return
+		}
+
+		printMembers(c.SInter(args...))
+	},
+}
+
+var sunionCmd = &cobra.Command{
+	Use:   "sunion",
+	Short: "Get the members that are in any given set",
+	Long:  "This command allows you to get the union of the sets stored under the given keys.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'sunion' requires at least one key")
+			return
+		}
+
+		printMembers(c.SUnion(args...))
+	},
+}
+
+var sdiffCmd = &cobra.Command{
+	Use:   "sdiff",
+	Short: "Get the members of the first set that are in none of the others",
+	Long:  "This command allows you to get the members of the set stored under the first key that are not in the sets stored under the other keys.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'sdiff' requires at least one key")
+			return
+		}
+
+		printMembers(c.SDiff(args...))
+	},
+}
+
+func printMembers(members []string, err error) {
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(members) == 0 {
+		fmt.Println("No members")
+		return
+	}
+	for i, member := range members {
+		fmt.Printf("%d) %s\n", i+1, member)
+	}
+}
+
+var zaddCmd = &cobra.Command{
+	Use:   "zadd",
+	Short: "Add members with scores to a sorted set",
+	Long:  "This command allows you to add members to the sorted set stored under a key or update their scores, given as score member score member and so on.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 || len(args)%2 != 1 {
+			fmt.Println("Error: 'zadd' requires a key and pairs of scores and members")
+			return
+		}
+
+		members := make([]rediscache.ZMember, 0, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			score, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				fmt.Printf("Error: invalid score '%s'\n", args[i])
+				return
+			}
+			members = append(members, rediscache.ZMember{Member: args[i+1], Score: score})
+		}
+
+		added, err := c.ZAdd(args[0], rediscache.ZAddOptions{NX: zaddNX, XX: zaddXX, GT: zaddGT, LT: zaddLT}, members...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Sorted set '%s' updated, %d new members\n", args[0], added)
+	},
+}
+
+var zremCmd = &cobra.Command{
+	Use:   "zrem",
+	Short: "Remove members from a sorted set",
+	Long:  "This command allows you to remove one or more members from the sorted set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'zrem' requires a key and at least one member")
+			return
+		}
+
+		removed, err := c.ZRem(args[0], args[1:]...)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Removed %d of %d members from sorted set '%s'\n", removed, len(args)-1, args[0])
+	},
+}
+
+var zscoreCmd = &cobra.Command{
+	Use:   "zscore",
+	Short: "Get the score of a member of a sorted set",
+	Long:  "This command allows you to get the score of a member of the sorted set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'zscore' requires a key and a member")
+			return
+		}
+
+		score, found, err := c.ZScore(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if !found {
+			fmt.Printf("'%s' is not a member of sorted set '%s'\n", args[1], args[0])
+			return
+		}
+		fmt.Printf("Score of '%s' in sorted set '%s': %s\n", args[1], args[0], formatScore(score))
+	},
+}
+
+var zincrByCmd = &cobra.Command{
+	Use:   "zincrby",
+	Short: "Increment the score of a member of a sorted set",
+	Long:  "This command allows you to add an amount to the score of a member of the sorted set stored under a key. A missing member starts at zero.",
+	// Amounts may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 {
+			fmt.Println("Error: 'zincrby' requires a key, an amount and a member")
+			return
+		}
+
+		amount, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			fmt.Printf("Error: invalid amount '%s'\n", args[1])
+			return
+		}
+
+		score, err := c.ZIncrBy(args[0], amount, args[2])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Score of '%s' in sorted set '%s': %s\n", args[2], args[0], formatScore(score))
+	},
+}
+
+var zrangeCmd = &cobra.Command{
+	Use:   "zrange",
+	Short: "Get members of a sorted set by rank",
+	Long:  "This command allows you to get the members of a sorted set from a start to a stop rank, both included, lowest score first. Negative ranks count from the end.",
+	// Ranks may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 {
+			fmt.Println("Error: 'zrange' requires a key, a start and a stop rank")
+			return
+		}
+
+		start, stop, ok := parseIndexes(args[1], args[2])
+		if !ok {
+			return
+		}
+
+		printZMembers(c.ZRange(args[0], start, stop))
+	},
+}
+
+var zrangeByScoreCmd = &cobra.Command{
+	Use:   "zrangebyscore",
+	Short: "Get members of a sorted set by score",
+	Long:  "This command allows you to get the members of a sorted set with a score between a minimum and a maximum, lowest score first. Use -inf and +inf for open ends and a leading '(' to exclude a bound.",
+	// Scores may be negative, so they must not be parsed as flags.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 3 {
+			fmt.Println("Error: 'zrangebyscore' requires a key, a minimum and a maximum score")
+			return
+		}
+
+		min, err := parseScoreBound(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		max, err := parseScoreBound(args[2])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+
+		printZMembers(c.ZRangeByScore(args[0], min, max))
+	},
+}
+
+var zrankCmd = &cobra.Command{
+	Use:   "zrank",
+	Short: "Get the rank of a member of a sorted set",
+	Long:  "This command allows you to get the rank of a member of a sorted set, starting at 0 for the lowest score.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Error: 'zrank' requires a key and a member")
+			return
+		}
+
+		rank, found, err := c.ZRank(args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if !found {
+			fmt.Printf("'%s' is not a member of sorted set '%s'\n", args[1], args[0])
+			return
+		}
+		fmt.Printf("Rank of '%s' in sorted set '%s': %d\n", args[1], args[0], rank)
+	},
+}
+
+var zcardCmd = &cobra.Command{
+	Use:   "zcard",
+	Short: "Get the number of members of a sorted set",
+	Long:  "This command allows you to get the number of members of the sorted set stored under a key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Error: 'zcard' requires a key")
+			return
+		}
+
+		length, err := c.ZCard(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Sorted set '%s' has %d members\n", args[0], length)
+	},
+}
+
+// parseScoreBound reads a score bound such as 1.5, (1.5, -inf or +inf.
+func parseScoreBound(arg string) (rediscache.ScoreBound, error) {
+	var bound rediscache.ScoreBound
+	if strings.HasPrefix(arg, "(") {
+		bound.Exclusive = true
+		arg = arg[1:]
+	}
+	switch arg {
+	case "-inf":
+		bound.Score = math.Inf(-1)
+	case "+inf", "inf":
+		bound.Score = math.Inf(1)
+	default:
+		score, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return bound, fmt.Errorf("invalid score '%s'", arg)
+		}
+		bound.Score = score
+	}
+	return bound, nil
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func printZMembers(members []rediscache.ZMember, err error) {
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(members) == 0 {
+		fmt.Println("No members")
+		return
+	}
+	for i, m := range members {
+		fmt.Printf("%d) %s (%s)\n", i+1, m.Member, formatScore(m.Score))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(saddCmd)
+	rootCmd.AddCommand(sremCmd)
+	rootCmd.AddCommand(smembersCmd)
+	rootCmd.AddCommand(sismemberCmd)
+	rootCmd.AddCommand(scardCmd)
+	rootCmd.AddCommand(sinterCmd)
+	rootCmd.AddCommand(sunionCmd)
+	rootCmd.AddCommand(sdiffCmd)
+
+	rootCmd.AddCommand(zaddCmd)
+	zaddCmd.Flags().BoolVar(&zaddNX, "nx", false, "Only add new members")
+	zaddCmd.Flags().BoolVar(&zaddXX, "xx", false, "Only update existing members")
+	zaddCmd.Flags().BoolVar(&zaddGT, "gt", false, "Only update a member when the new score is greater")
+	zaddCmd.Flags().BoolVar(&zaddLT, "lt", false, "Only update a member when the new score is less")
+
+	rootCmd.AddCommand(zremCmd)
+	rootCmd.AddCommand(zscoreCmd)
+	rootCmd.AddCommand(zincrByCmd)
+	rootCmd.AddCommand(zrangeCmd)
+	rootCmd.AddCommand(zrangeByScoreCmd)
+	rootCmd.AddCommand(zrankCmd)
+	rootCmd.AddCommand(zcardCmd)
+}
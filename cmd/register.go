@@ -1,21 +1,22 @@
 package cmd
 
 import (
-	"context"
-
 	"github.com/macadrich/go-task-challenge/application"
 	"github.com/macadrich/go-task-challenge/domain"
-	external "github.com/macadrich/go-task-challenge/external"
-	"github.com/macadrich/go-task-challenge/infra"
 	"github.com/spf13/cobra"
 )
 
 var (
-	firstName string
-	lastName  string
-	email     string
-	phone     string
-	address   string
+	firstName  string
+	lastName   string
+	email      string
+	phone      string
+	address    string
+	city       string
+	postalCode string
+	country    string
+
+	registerIdempotencyKey string
 )
 
 var registerCmd = &cobra.Command{
@@ -23,20 +24,22 @@ var registerCmd = &cobra.Command{
 	Short: "Task1 register a new customer",
 	Long:  "Task1 register a new customer and validate their KYC information using an external service",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		externalService := &external.ExternalKYCService{}
-		kycAdapter := infra.NewKYCAdapter(externalService)
-
-		customerService := application.NewCustomerService(kycAdapter, customerRepository)
+		customerService := newCustomerService()
 
 		customer := &domain.Customer{
 			FirstName: firstName,
 			LastName:  lastName,
 			Email:     email,
 			Phone:     phone,
-			Address:   address,
+			Address: domain.Address{
+				Street:     address,
+				City:       city,
+				PostalCode: postalCode,
+				Country:    country,
+			},
 		}
 
-		ctx := context.Background()
+		ctx := application.WithIdempotencyKey(commandContext(), registerIdempotencyKey)
 		if err := customerService.RegisterCustomer(ctx, customer); err != nil {
 			return err
 		}
@@ -51,7 +54,11 @@ func init() {
 	registerCmd.Flags().StringVar(&lastName, "last-name", "", "Customer's last name")
 	registerCmd.Flags().StringVar(&email, "email", "", "Customer's email")
 	registerCmd.Flags().StringVar(&phone, "phone", "", "Customer's phone number")
-	registerCmd.Flags().StringVar(&address, "address", "", "Customer's address")
+	registerCmd.Flags().StringVar(&address, "address", "", "Customer's street address")
+	registerCmd.Flags().StringVar(&city, "city", "", "Customer's city")
+	registerCmd.Flags().StringVar(&postalCode, "postal-code", "", "Customer's postal code")
+	registerCmd.Flags().StringVar(&country, "country", "", "Customer's country")
+	registerCmd.Flags().StringVar(&registerIdempotencyKey, "idempotency-key", "", "Key to deduplicate retried registrations")
 
 	registerCmd.MarkFlagRequired("first-name")
 	registerCmd.MarkFlagRequired("last-name")
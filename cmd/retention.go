@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/macadrich/go-task-challenge/application"
+	"github.com/macadrich/go-task-challenge/constants"
+	"github.com/spf13/cobra"
+)
+
+var retentionDryRun bool
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Apply the customer data retention policy",
+	Long:  "This command erases the personal data of customers still pending verification after the retention period. That covers abandoned registrations and rejected ones, since a rejected verification leaves the customer pending. Use --dry-run to list them without erasing. The policy also runs every hour in the background.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := newRetentionEngine().Apply(commandContext(), retentionDryRun)
+		if report != nil {
+			for _, candidate := range report.Candidates {
+				cmd.Printf("%s %s, %d days old\n", candidate.CustomerID, candidate.Status,
+					int(candidate.Age/(24*time.Hour)))
+			}
+			if report.DryRun {
+				cmd.Printf("Dry run: %d customers of tenant %s would be erased\n", len(report.Candidates), report.TenantID)
+			} else {
+				cmd.Printf("Erased %d of %d customers of tenant %s\n", report.Erased, len(report.Candidates), report.TenantID)
+			}
+		}
+		return err
+	},
+}
+
+// newRetentionEngine returns the engine applying the retention policy. A
+// rejected verification leaves the customer pending, so the pending rule
+// covers rejected registrations too.
+func newRetentionEngine() *application.RetentionEngine {
+	return application.NewRetentionEngine(newCustomerService(), []application.RetentionRule{
+		{Status: "pending", MaxAge: constants.PendingRetention},
+	})
+}
+
+func init() {
+	retentionCmd.Flags().BoolVar(&retentionDryRun, "dry-run", false, "List the customers that would be erased without erasing them")
+
+	rootCmd.AddCommand(retentionCmd)
+}
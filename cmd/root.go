@@ -2,29 +2,129 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/macadrich/go-task-challenge/application"
+	"github.com/macadrich/go-task-challenge/constants"
+	"github.com/macadrich/go-task-challenge/domain"
+	external "github.com/macadrich/go-task-challenge/external"
 	"github.com/macadrich/go-task-challenge/infra"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	customerRepository *infra.CustomerRepository
+	customerRepository application.CustomerRepository
+	verificationJobs   *application.VerificationJobs
+
+	// currentTenant is the tenant chosen with 'use tenant'; the --tenant flag
+	// overrides it for a single command.
+	currentTenant = domain.DefaultTenant
+	tenantFlag    string
+
+	// blobDir holds the files of attached identity documents. It is set with
+	// --blob-dir, or placed in the data directory or the user cache directory.
+	blobDir string
+
+	// dataDir and store are set on the command line when starting the REPL.
+	// Customers are kept in memory unless a data directory is given or the
+	// cache store is chosen.
+	dataDir string
+	store   string
+
+	// keyFile enables encryption of customer PII at rest in the file and
+	// cache stores.
+	keyFile string
+	keyring *infra.Keyring
+
+	// tenantKYCFile names the JSON file with the KYC provider settings of
+	// tenants, see loadTenantKYCConfig.
+	tenantKYCFile string
+	tenantKYC     map[string]tenantKYCSettings
+
+	// logEvents makes the outbox relay write delivered events to the log
+	// instead of discarding them.
+	logEvents bool
+
+	// readCacheTTL enables reading customers through the cache when set.
+	readCacheTTL time.Duration
+
+	// maxMemory and maxMemoryPolicy limit the memory used by the cache.
+	maxMemory       string
+	maxMemoryPolicy string
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "go-challege",
 	Short: "go-challege CLI",
 	Long:  "go-challege CLI example of using DDD pattern and concurrent programming to solve a problem",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if tenantFlag != "" {
+			if err := domain.ValidateTenantID(tenantFlag); err != nil {
+				return err
+			}
+		}
 		if customerRepository == nil {
 			customerRepository = infra.NewCustomerRepository()
 		}
+		if verificationJobs == nil {
+			verificationJobs = application.NewVerificationJobs(newCustomerService(),
+				constants.VerificationWorkers, constants.VerificationQueueSize)
+		}
+		return nil
 	},
 }
 
+// commandContext returns the context for the running command, scoped to the
+// selected tenant.
+func commandContext() context.Context {
+	tenantID := currentTenant
+	if tenantFlag != "" {
+		tenantID = tenantFlag
+	}
+	return domain.WithTenant(context.Background(), tenantID)
+}
+
+// newCustomerService wires the customer service used by the REPL commands.
+func newCustomerService() *application.CustomerService {
+	externalService := &external.ExternalKYCService{}
+	kycAdapter := infra.NewKYCAdapter(externalService)
+
+	opts := []application.Option{
+		application.WithIdempotency(storeCache, constants.IdempotencyWindow),
+		application.WithDocumentStore(infra.NewLocalBlobStore(blobDir)),
+	}
+	for tenantID, settings := range tenantKYC {
+		opts = append(opts, application.WithTenantKYCService(tenantID,
+			infra.NewKYCAdapter(externalService, infra.WithProviders(settings.Providers))))
+	}
+	return application.NewCustomerService(kycAdapter, customerRepository, opts...)
+}
+
+// resetFlags restores every flag to its default value so that flags given to
+// one REPL command do not leak into the next one.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		f.Value.Set(f.DefValue)
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, child := range cmd.Commands() {
+		resetFlags(child)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&tenantFlag, "tenant", "", "Tenant to run the command for")
+}
+
 func commandLoop() {
 	reader := bufio.NewReader(os.Stdin)
 	for {
@@ -38,6 +138,11 @@ func commandLoop() {
 		}
 
 		cmdArgs := strings.Split(input, " ")
+		if key, found := reservedKey(cmdArgs); found {
+			fmt.Printf("Error: key %q is reserved for the application\n", key)
+			continue
+		}
+		resetFlags(rootCmd)
 		rootCmd.SetArgs(cmdArgs)
 		if err := rootCmd.Execute(); err != nil {
 			fmt.Println(err)
@@ -45,6 +150,211 @@ func commandLoop() {
 	}
 }
 
+// reservedKey returns the first key of a cache command that starts with
+// constants.ReservedKeyPrefix. Keys with that prefix belong to the customer
+// stores and the idempotency keys, so the REPL never reads or writes them.
+// Values, fields and members may hold any text and are not checked.
+func reservedKey(args []string) (string, bool) {
+	command, rest, err := rootCmd.Find(args)
+	if err != nil || command == rootCmd {
+		return "", false
+	}
+	defer resetFlags(rootCmd)
+	if err := command.ParseFlags(rest); err != nil {
+		return "", false
+	}
+	for _, key := range keyArgs(command.Name(), command.Flags().Args()) {
+		if strings.HasPrefix(key, constants.ReservedKeyPrefix) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// keyArgs returns the arguments of a cache command that name keys.
+func keyArgs(name string, args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	switch name {
+	case "del", "exists", "mget", "sinter", "sunion", "sdiff":
+		return args
+	case "mset", "msetnx":
+		keys := make([]string, 0, (len(args)+1)/2)
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	case "blpop", "brpop":
+		// The last argument is the timeout.
+		return args[:len(args)-1]
+	}
+	if singleKeyCommands[name] {
+		return args[:1]
+	}
+	return nil
+}
+
+// singleKeyCommands are the cache commands whose first argument is their only
+// key.
+var singleKeyCommands = map[string]bool{
+	"set": true, "get": true, "expire": true, "pexpire": true, "expireat": true,
+	"ttl": true, "pttl": true, "persist": true, "incr": true, "decr": true,
+	"incrby": true, "decrby": true, "incrbyfloat": true, "setnx": true,
+	"getset": true, "getdel": true, "getex": true,
+	"lpush": true, "rpush": true, "lpop": true, "rpop": true, "llen": true,
+	"lrange": true, "lindex": true, "lrem": true, "ltrim": true,
+	"hset": true, "hget": true, "hmget": true, "hgetall": true, "hdel": true,
+	"hexists": true, "hlen": true, "hincrby": true, "hkeys": true, "hvals": true,
+	"sadd": true, "srem": true, "smembers": true, "sismember": true, "scard": true,
+	"zadd": true, "zrem": true, "zscore": true, "zincrby": true, "zrange": true,
+	"zrangebyscore": true, "zrank": true, "zcard": true,
+}
+
+// openCustomerRepository selects the customer store for the session.
+func openCustomerRepository() (func() error, error) {
+	noop := func() error { return nil }
+
+	if keyFile != "" {
+		var err error
+		if keyring, err = infra.LoadKeyring(keyFile); err != nil {
+			return nil, fmt.Errorf("failed to load key file: %w", err)
+		}
+	}
+	opts := storeOptions()
+
+	switch store {
+	case "cache":
+		customerRepository = infra.NewCacheCustomerRepository(storeCache, opts...)
+		return noop, nil
+	case "memory":
+		customerRepository = infra.NewCustomerRepository()
+		return noop, nil
+	case "", "file":
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected memory, file or cache", store)
+	}
+
+	if dataDir == "" {
+		if store == "file" {
+			return nil, fmt.Errorf("the file store requires --data-dir")
+		}
+		customerRepository = infra.NewCustomerRepository()
+		return noop, nil
+	}
+
+	repository, err := infra.OpenFileCustomerRepository(filepath.Join(dataDir, "customers"), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open customer store: %w", err)
+	}
+	customerRepository = repository
+	return repository.Close, nil
+}
+
+// configureBlobDir picks the directory for document files when --blob-dir is
+// not given: the data directory, or else a directory of the current user
+// only, never one shared with other users.
+func configureBlobDir() error {
+	switch {
+	case blobDir != "":
+	case dataDir != "":
+		blobDir = filepath.Join(dataDir, "blobs")
+	default:
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("no directory for document files, set --blob-dir: %w", err)
+		}
+		blobDir = filepath.Join(cacheDir, "go-task-challenge", "blobs")
+	}
+	return nil
+}
+
+// storeOptions configures the customer stores and the data derived from
+// them for the session.
+func storeOptions() []infra.StoreOption {
+	if keyring == nil {
+		return nil
+	}
+	return []infra.StoreOption{infra.WithKeyring(keyring)}
+}
+
+// enableReadCache puts the read-through cache in front of the customer
+// store.
+func enableReadCache() error {
+	store, ok := customerRepository.(infra.CachedCustomerStore)
+	if !ok {
+		return fmt.Errorf("the customer store does not support a read cache")
+	}
+	// Wrap stores with secondary indexes so that lookups by phone and last
+	// name stay available behind the cache.
+	if indexed, ok := store.(infra.IndexedCustomerStore); ok {
+		customerRepository = infra.NewIndexedCachingCustomerRepository(indexed, c,
+			readCacheTTL, constants.ReadCacheNegativeTTL, storeOptions()...)
+		return nil
+	}
+	customerRepository = infra.NewCachingCustomerRepository(store, c,
+		readCacheTTL, constants.ReadCacheNegativeTTL, storeOptions()...)
+	return nil
+}
+
 func Execute() {
+	flags := pflag.NewFlagSet(rootCmd.Use, pflag.ExitOnError)
+	flags.StringVar(&dataDir, "data-dir", "", "Directory for durable customer storage; customers are kept in memory when empty")
+	flags.StringVar(&blobDir, "blob-dir", "", "Directory for document files (default <data-dir>/blobs, or the user cache directory)")
+	flags.StringVar(&tenantKYCFile, "tenant-kyc-config", "", "JSON file with KYC provider settings per tenant")
+	flags.BoolVar(&logEvents, "log-events", false, "Write delivered customer events to the log")
+	flags.StringVar(&keyFile, "key-file", "", "Key file used to encrypt customer PII at rest; created if missing")
+	flags.StringVar(&store, "store", "", "Customer store: memory, file or cache (default file with --data-dir, memory otherwise)")
+	flags.DurationVar(&readCacheTTL, "read-cache-ttl", 0, "Cache customers read by email for this long, e.g. 5m; disabled when zero")
+	flags.StringVar(&maxMemory, "maxmemory", "0", "Approximate memory limit of the cache, e.g. 64mb; unlimited when zero")
+	flags.StringVar(&maxMemoryPolicy, "maxmemory-policy", string(rediscache.NoEviction),
+		"Keys evicted at the memory limit: noeviction, allkeys-lru, volatile-lru, allkeys-lfu, volatile-ttl or allkeys-random")
+	flags.Parse(os.Args[1:])
+
+	if err := configureCache(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	closeRepository, err := openCustomerRepository()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if tenantKYCFile != "" {
+		if tenantKYC, err = loadTenantKYCConfig(tenantKYCFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if err := configureBlobDir(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if readCacheTTL > 0 {
+		if err := enableReadCache(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	ctx, stopRelay := context.WithCancel(context.Background())
+	if repository, ok := customerRepository.(application.OutboxRepository); ok {
+		var publisher application.EventPublisher = &infra.DiscardEventPublisher{}
+		if logEvents {
+			publisher = &infra.LogEventPublisher{}
+		}
+		relay := application.NewOutboxRelay(repository, publisher,
+			constants.OutboxRelayInterval, constants.OutboxRelayBatchSize)
+		go relay.Run(ctx)
+	}
+	go newRetentionEngine().Run(ctx, constants.RetentionInterval)
+
 	commandLoop()
+
+	stopRelay()
+	if err := closeRepository(); err != nil {
+		fmt.Println(err)
+	}
+	c.Close()
+	storeCache.Close()
 }
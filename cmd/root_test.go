@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedKeyChecksOnlyKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		key      string
+		reserved bool
+	}{
+		{input: "set __kyc:x v", key: "__kyc:x", reserved: true},
+		{input: "set foo __kyc:bar"},
+		{input: "set --ttl 10 __kyc:x v", key: "__kyc:x", reserved: true},
+		{input: "get __kyc:customer:c1", key: "__kyc:customer:c1", reserved: true},
+		{input: "mset a __kyc:1 __kyc:b 2", key: "__kyc:b", reserved: true},
+		{input: "mset a __kyc:1 b __kyc:2"},
+		{input: "del a __kyc:b", key: "__kyc:b", reserved: true},
+		{input: "blpop a __kyc:b 0", key: "__kyc:b", reserved: true},
+		{input: "lpush list __kyc:item"},
+		{input: "hset h __kyc:field __kyc:value"},
+		{input: "sadd s __kyc:member"},
+		{input: "zadd z 1 __kyc:member"},
+		{input: "register __kyc:name"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			key, reserved := reservedKey(strings.Split(test.input, " "))
+			assert.Equal(t, test.reserved, reserved)
+			assert.Equal(t, test.key, key)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// reencrypter is implemented by customer stores that encrypt records at rest.
+type reencrypter interface {
+	Reencrypt(context.Context) (int, error)
+}
+
+var rotateKeysCmd = &cobra.Command{
+	Use:   "rotate-keys",
+	Short: "Rotate the encryption key for customer data",
+	Long:  "This command adds a new encryption key, uses it for all new writes and re-encrypts the stored customers in the background.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if keyring == nil {
+			return fmt.Errorf("encryption is not enabled, start with --key-file")
+		}
+		repository, ok := customerRepository.(reencrypter)
+		if !ok {
+			return fmt.Errorf("the customer store does not support encryption")
+		}
+
+		keyID, err := keyring.Rotate()
+		if err != nil {
+			return fmt.Errorf("failed to rotate keys: %w", err)
+		}
+
+		go func() {
+			count, err := repository.Reencrypt(context.Background())
+			if err != nil {
+				log.Printf("re-encryption with key %s stopped after %d records: %v", keyID, count, err)
+				return
+			}
+			log.Printf("re-encrypted %d customer records with key %s", count, keyID)
+		}()
+
+		cmd.Printf("Rotated to key %s, re-encrypting customers in the background\n", keyID)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotateKeysCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/spf13/cobra"
+)
+
+var useCmd = &cobra.Command{
+	Use:   "use",
+	Short: "Change REPL session settings",
+}
+
+var useTenantCmd = &cobra.Command{
+	Use:   "tenant",
+	Short: "Switch the tenant used by the following commands",
+	Long:  "This command selects the tenant that register, verify and job commands run for until another tenant is selected.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("'use tenant' requires a tenant ID")
+		}
+
+		if err := domain.ValidateTenantID(args[0]); err != nil {
+			return err
+		}
+		currentTenant = args[0]
+		cmd.Printf("Using tenant '%s'\n", currentTenant)
+		return nil
+	},
+}
+
+func init() {
+	useCmd.AddCommand(useTenantCmd)
+	rootCmd.AddCommand(useCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// tenantKYCSettings configures the KYC providers of a tenant. Tenants not
+// listed use the default settings.
+type tenantKYCSettings struct {
+	// Providers is the number of providers asked to verify each customer.
+	Providers int `json:"providers"`
+}
+
+// loadTenantKYCConfig reads the KYC provider settings of tenants from a JSON
+// file keyed by tenant ID, e.g. {"acme": {"providers": 10}}.
+func loadTenantKYCConfig(path string) (map[string]tenantKYCSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant KYC config: %w", err)
+	}
+
+	var config map[string]tenantKYCSettings
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to read tenant KYC config: %w", err)
+	}
+	for tenantID, settings := range config {
+		if err := domain.ValidateTenantID(tenantID); err != nil {
+			return nil, fmt.Errorf("tenant KYC config: %q: %w", tenantID, err)
+		}
+		if settings.Providers < 1 {
+			return nil, fmt.Errorf("tenant %q needs at least one KYC provider", tenantID)
+		}
+	}
+	return config, nil
+}
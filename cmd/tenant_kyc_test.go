@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTenantKYCConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"acme": {"providers": 10}, "globex": {"providers": 3}}`), 0o600))
+
+	config, err := loadTenantKYCConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]tenantKYCSettings{"acme": {Providers: 10}, "globex": {Providers: 3}}, config)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"acme": {"providers": 0}}`), 0o600))
+	_, err = loadTenantKYCConfig(path)
+	assert.Error(t, err)
+}
@@ -1,18 +1,17 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/macadrich/go-task-challenge/application"
 	"github.com/macadrich/go-task-challenge/constants"
-	external "github.com/macadrich/go-task-challenge/external"
-	"github.com/macadrich/go-task-challenge/infra"
 	"github.com/spf13/cobra"
 )
 
 var (
-	verifyEmail string
+	verifyEmail          string
+	verifyIdempotencyKey string
+	verifyAsync          bool
 )
 
 var verifyCmd = &cobra.Command{
@@ -20,18 +19,25 @@ var verifyCmd = &cobra.Command{
 	Short: "Task2 verify a customer",
 	Long:  "Task2 verify a customer information using an external service.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		externalService := &external.ExternalKYCService{}
-		kycAdapter := infra.NewKYCAdapter(externalService)
+		customerService := newCustomerService()
 
-		customerService := application.NewCustomerService(kycAdapter, customerRepository)
-
-		ctx := context.Background()
+		ctx := application.WithIdempotencyKey(commandContext(), verifyIdempotencyKey)
 
 		customer, err := customerRepository.FindByEmail(ctx, verifyEmail)
 		if err != nil {
 			return fmt.Errorf("failed to find customer: %w", err)
 		}
 
+		if verifyAsync {
+			id, err := verificationJobs.Submit(ctx, constants.NumberOfRoutines, customer)
+			if err != nil {
+				return fmt.Errorf("failed to submit verification: %w", err)
+			}
+
+			cmd.Printf("Verification job submitted: %s\n", id)
+			return nil
+		}
+
 		if err := customerService.VerifyRegisteredCustomer(ctx, constants.NumberOfRoutines, customer); err != nil {
 			return fmt.Errorf("failed to verify customer: %w", err)
 		}
@@ -44,6 +50,8 @@ var verifyCmd = &cobra.Command{
 
 func init() {
 	verifyCmd.Flags().StringVar(&verifyEmail, "email", "", "Customer email")
+	verifyCmd.Flags().StringVar(&verifyIdempotencyKey, "idempotency-key", "", "Key to deduplicate retried verifications")
+	verifyCmd.Flags().BoolVar(&verifyAsync, "async", false, "Run the verification as a background job")
 	verifyCmd.MarkFlagRequired("email")
 	rootCmd.AddCommand(verifyCmd)
 }
@@ -31,7 +31,7 @@ func TestVerifyCommand(t *testing.T) {
 				LastName:  lastName,
 				Email:     email,
 				Phone:     phone,
-				Address:   address,
+				Address:   domain.Address{Street: address},
 			}
 			ctx := context.Background()
 
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/spf13/cobra"
+)
+
+// watcher is implemented by customer stores with a change feed.
+type watcher interface {
+	Watch(ctx context.Context, fromSeq uint64) (<-chan domain.Change, error)
+}
+
+var watchFromSeq uint64
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Follow changes as they happen",
+}
+
+var watchCustomersCmd = &cobra.Command{
+	Use:   "customers",
+	Short: "Tail the customer change feed",
+	Long:  "This command prints every customer change of the tenant as it is stored, until interrupted with Ctrl+C. Use --from with the last sequence number seen to resume.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repository, ok := customerRepository.(watcher)
+		if !ok {
+			return fmt.Errorf("the customer store does not support watching")
+		}
+
+		ctx, stop := signal.NotifyContext(commandContext(), os.Interrupt)
+		defer stop()
+
+		changes, err := repository.Watch(ctx, watchFromSeq)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println("Watching customer changes, press Ctrl+C to stop")
+		for change := range changes {
+			printChange(cmd, change)
+		}
+		if ctx.Err() == nil {
+			return fmt.Errorf("fell behind the change feed, resume with --from")
+		}
+		return nil
+	},
+}
+
+func printChange(cmd *cobra.Command, change domain.Change) {
+	after := change.After
+	switch {
+	case change.Before == nil:
+		cmd.Printf("#%d created %s %s status %s\n", change.Seq, after.ID, after.Email, after.KYCStatus)
+	case after.Erased() && !change.Before.Erased():
+		cmd.Printf("#%d erased %s\n", change.Seq, after.ID)
+	default:
+		cmd.Printf("#%d updated %s %s version %d status %s -> %s\n", change.Seq, after.ID, after.Email,
+			after.Version, change.Before.KYCStatus, after.KYCStatus)
+	}
+}
+
+func init() {
+	watchCustomersCmd.Flags().Uint64Var(&watchFromSeq, "from", 0, "Print changes after this sequence number")
+
+	watchCmd.AddCommand(watchCustomersCmd)
+	rootCmd.AddCommand(watchCmd)
+}
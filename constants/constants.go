@@ -1,3 +1,48 @@
 package constants
 
+import "time"
+
 const NumberOfRoutines = 100
+
+// ReservedKeyPrefix starts the cache keys of the customer stores and of the
+// idempotency keys. The REPL refuses keys with this prefix so that they can
+// only be changed through the application.
+const ReservedKeyPrefix = "__kyc:"
+
+// IdempotencyWindow is how long the outcome of an idempotent request is kept.
+const IdempotencyWindow = 24 * time.Hour
+
+// VerificationWorkers and VerificationQueueSize size the background
+// verification job pool.
+const (
+	VerificationWorkers   = 4
+	VerificationQueueSize = 100
+)
+
+// FinishedJobTTL is how long a finished verification job can still be
+// looked up; MaxFinishedJobs caps how many are kept, dropping the oldest.
+const (
+	FinishedJobTTL  = time.Hour
+	MaxFinishedJobs = 1000
+)
+
+// OutboxRelayInterval and OutboxRelayBatchSize control how often and in
+// which batches committed events are delivered.
+const (
+	OutboxRelayInterval  = time.Second
+	OutboxRelayBatchSize = 100
+)
+
+// PendingRetention is how long customers that never passed verification are
+// kept before their personal data is erased. A rejected verification is not
+// saved and the customer stays pending, so there is no separate rejected
+// status: this single rule covers abandoned and rejected registrations.
+// RetentionInterval is how often the retention policy runs.
+const (
+	PendingRetention  = 30 * 24 * time.Hour
+	RetentionInterval = time.Hour
+)
+
+// ReadCacheNegativeTTL is how long the read cache remembers that an email
+// does not belong to a customer.
+const ReadCacheNegativeTTL = 30 * time.Second
@@ -0,0 +1,23 @@
+package domain
+
+import "strings"
+
+// Address is the postal address of a customer. Addresses stored before it
+// was structured have everything in Street.
+type Address struct {
+	Street     string
+	City       string
+	PostalCode string
+	Country    string
+}
+
+// String returns the address on a single line.
+func (a Address) String() string {
+	parts := make([]string, 0, 4)
+	for _, part := range []string{a.Street, a.City, a.PostalCode, a.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrChangesTruncated = errors.New("changes after the requested sequence are no longer retained")
+
+// Change is an entry of the customer change feed. Before is nil for a new
+// customer. Seq increases by one for every stored customer version.
+type Change struct {
+	Seq      uint64
+	TenantID string
+	Before   *Customer
+	After    *Customer
+	At       time.Time
+}
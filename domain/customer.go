@@ -2,19 +2,70 @@ package domain
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"time"
 )
 
-var ErrKYCFailed = errors.New("KYC validation failed")
+var (
+	ErrKYCFailed        = errors.New("KYC validation failed")
+	ErrCustomerNotFound = errors.New("customer not found")
+	ErrVersionConflict  = errors.New("customer was modified concurrently")
+	ErrDuplicatePhone   = errors.New("phone number is used by another customer")
+)
 
 type Customer struct {
 	ID        string
+	TenantID  string
 	FirstName string
 	LastName  string
 	Email     string
 	Phone     string
-	Address   string
+	Address   Address
 	KYCStatus string
+	Documents []Document
+	// Version is incremented by the repository on every successful save and
+	// is used to reject writes based on a stale read.
+	Version   int
+	CreatedAt time.Time
+	// ErasedAt is set when the customer's personal data was erased.
+	ErasedAt time.Time
+}
+
+// Erase irreversibly removes the personal data of c. What is left is the
+// audit record: ID, tenant, KYC status and timestamps. The email is replaced
+// by a placeholder derived from the ID, so the record stays addressable and
+// the original email can be registered again.
+func (c *Customer) Erase(at time.Time) {
+	c.FirstName = ""
+	c.LastName = ""
+	c.Email = "erased:" + c.ID
+	c.Phone = ""
+	c.Address = Address{}
+	c.Documents = nil
+	c.ErasedAt = at
+}
+
+// Erased reports whether the customer's personal data was erased.
+func (c *Customer) Erased() bool {
+	return !c.ErasedAt.IsZero()
+}
+
+// Clone returns a copy of the customer that shares no state with c.
+func (c *Customer) Clone() *Customer {
+	clone := *c
+	clone.Documents = append([]Document(nil), c.Documents...)
+	return &clone
+}
+
+// NewCustomerID returns a random customer identifier.
+func NewCustomerID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
 }
 
 type KYCService interface {
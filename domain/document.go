@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidDocument = errors.New("invalid identity document")
+
+type DocumentType string
+
+const (
+	Passport       DocumentType = "passport"
+	IDCard         DocumentType = "id_card"
+	DrivingLicense DocumentType = "driving_license"
+)
+
+// Document is an identity document attached to a customer. The scanned file
+// itself lives in a blob store under BlobID, the SHA-256 of its content.
+type Document struct {
+	Type           DocumentType
+	Number         string
+	IssuingCountry string
+	Expiry         time.Time
+	BlobID         string
+	MIMEType       string
+	Size           int64
+	AttachedAt     time.Time
+}
+
+// Validate checks the document metadata supplied by the customer.
+func (d Document) Validate() error {
+	switch d.Type {
+	case Passport, IDCard, DrivingLicense:
+	default:
+		return ErrInvalidDocument
+	}
+	if d.Number == "" || d.IssuingCountry == "" || d.Expiry.IsZero() {
+		return ErrInvalidDocument
+	}
+	return nil
+}
+
+// Blob describes content kept in a blob store.
+type Blob struct {
+	ID       string
+	MIMEType string
+	Size     int64
+}
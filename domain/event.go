@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+const (
+	CustomerRegistered       = "customer.registered"
+	CustomerVerified         = "customer.verified"
+	CustomerDocumentAttached = "customer.document_attached"
+	CustomerErased           = "customer.erased"
+)
+
+// Event records a change to a customer for downstream systems. Events are
+// kept in the outbox at rest, so they carry no PII: consumers look customers
+// up by ID.
+type Event struct {
+	ID         string
+	Type       string
+	TenantID   string
+	CustomerID string
+	Data       map[string]string
+	OccurredAt time.Time
+}
+
+// NewCustomerEvent returns an event of the given type describing customer.
+func NewCustomerEvent(eventType string, customer *Customer) Event {
+	return Event{
+		ID:         NewCustomerID(),
+		Type:       eventType,
+		TenantID:   customer.TenantID,
+		CustomerID: customer.ID,
+		Data: map[string]string{
+			"kyc_status": customer.KYCStatus,
+		},
+		OccurredAt: time.Now(),
+	}
+}
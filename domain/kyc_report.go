@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ProviderResult is the outcome of a single KYC provider call.
+type ProviderResult struct {
+	Provider int
+	Status   string
+	Err      error
+	Duration time.Duration
+}
+
+// KYCReport summarises a verification across all providers.
+type KYCReport struct {
+	Email      string
+	Status     string
+	Providers  []ProviderResult
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+type kycProgressContext struct{}
+
+// WithKYCProgress returns a copy of ctx that makes KYC services report every
+// finished provider call to fn. fn may be called from several goroutines.
+func WithKYCProgress(ctx context.Context, fn func(ProviderResult)) context.Context {
+	return context.WithValue(ctx, kycProgressContext{}, fn)
+}
+
+// ReportKYCProgress hands result to the progress function carried by ctx.
+func ReportKYCProgress(ctx context.Context, result ProviderResult) {
+	if fn, ok := ctx.Value(kycProgressContext{}).(func(ProviderResult)); ok {
+		fn(result)
+	}
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultTenant is used when the context does not name a tenant.
+const DefaultTenant = "default"
+
+// maxTenantIDLength bounds the length of tenant IDs.
+const maxTenantIDLength = 64
+
+var (
+	ErrTenantMismatch = errors.New("customer belongs to another tenant")
+	ErrInvalidTenant  = errors.New("tenant ID must be 1 to 64 letters, digits, '-' or '_'")
+)
+
+// ValidateTenantID checks that tenantID only holds letters, digits, '-' and
+// '_'. Stores build keys by joining the tenant with other values, so a
+// separator in a tenant ID could reach the data of another tenant.
+func ValidateTenantID(tenantID string) error {
+	if tenantID == "" || len(tenantID) > maxTenantIDLength {
+		return ErrInvalidTenant
+	}
+	for _, c := range tenantID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return ErrInvalidTenant
+		}
+	}
+	return nil
+}
+
+type tenantContext struct{}
+
+// WithTenant returns a copy of ctx scoped to the given tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContext{}, tenantID)
+}
+
+// TenantFromContext returns the tenant carried by ctx, or DefaultTenant.
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantContext{}).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenant
+}
+
+// CheckTenant assigns customers without a tenant to the tenant of ctx and
+// fails for customers that belong to a different one or to an invalid tenant.
+func CheckTenant(ctx context.Context, customer *Customer) error {
+	tenantID := TenantFromContext(ctx)
+	if err := ValidateTenantID(tenantID); err != nil {
+		return err
+	}
+	if customer.TenantID == "" {
+		customer.TenantID = tenantID
+	}
+	if customer.TenantID != tenantID {
+		return ErrTenantMismatch
+	}
+	return nil
+}
@@ -9,10 +9,20 @@ import (
 type ExternalKYCService struct{}
 
 type ExternalKYCRequest struct {
-	FullName string
-	Email    string
-	Phone    string
-	Address  string
+	FullName  string
+	Email     string
+	Phone     string
+	Address   string
+	Documents []ExternalKYCDocument
+}
+
+type ExternalKYCDocument struct {
+	Type           string
+	Number         string
+	IssuingCountry string
+	Expiry         time.Time
+	Checksum       string
+	MIMEType       string
 }
 
 type ExternalKYCResponse struct {
@@ -0,0 +1,566 @@
+package infra
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+)
+
+const (
+	// backupVersion is the archive format written by Backup.
+	backupVersion = 1
+
+	backupManifestFile  = "manifest.json"
+	backupCustomersFile = "customers.json"
+	backupCacheFile     = "cache.json"
+
+	// maxBackupFileSize bounds the memory used to read one archive member.
+	maxBackupFileSize = 256 << 20
+)
+
+var (
+	ErrInvalidBackup     = errors.New("invalid backup archive")
+	ErrUnsupportedBackup = errors.New("unsupported backup version")
+	// ErrUnsupportedValue is returned by Write for a cache key holding a
+	// value that the archive cannot keep with its type.
+	ErrUnsupportedValue = errors.New("value cannot be backed up")
+)
+
+// BackupRepository is a customer store that can be backed up and restored.
+type BackupRepository interface {
+	Save(context.Context, *domain.Customer) error
+	FindByEmail(context.Context, string) (*domain.Customer, error)
+	Customers(context.Context) ([]*domain.Customer, error)
+	Tenants(context.Context) ([]string, error)
+}
+
+// RestoreMode decides what happens to entries that exist both in the store
+// and in the archive with different content. Entries that are only in the
+// store are kept in either mode.
+type RestoreMode string
+
+const (
+	// RestoreMerge keeps the existing entry.
+	RestoreMerge RestoreMode = "merge"
+	// RestoreReplace overwrites it with the archived one.
+	RestoreReplace RestoreMode = "replace"
+)
+
+// BackupManifest describes an archive. Checksums maps every other file of
+// the archive to its hex SHA-256.
+type BackupManifest struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Customers int               `json:"customers"`
+	Keys      int               `json:"keys"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+// backupEntry is a cache key in the archive. Type is one of the types of
+// rediscache.Entry and decides which of the value fields is set. TTL is the
+// time to live left when the backup was taken, in milliseconds; zero means
+// no expiry.
+type backupEntry struct {
+	Key     string                  `json:"key"`
+	Type    string                  `json:"type"`
+	Value   *backupScalar           `json:"value,omitempty"`
+	Items   []backupScalar          `json:"items,omitempty"`
+	Fields  map[string]backupScalar `json:"fields,omitempty"`
+	Members []string                `json:"members,omitempty"`
+	Scores  []backupScore           `json:"scores,omitempty"`
+	TTL     int64                   `json:"ttl_ms,omitempty"`
+}
+
+// backupScalar is a plain value with the name of its Go type, so that it is
+// restored as the same type rather than as whatever JSON decodes it to.
+type backupScalar struct {
+	Kind  string          `json:"kind"`
+	Value json.RawMessage `json:"value"`
+}
+
+// backupScore is a member of a sorted set. The score is kept as text so
+// that infinite scores survive JSON.
+type backupScore struct {
+	Member string `json:"member"`
+	Score  string `json:"score"`
+}
+
+// RestoreConflict is an entry that differed between the store and the
+// archive, or could not be restored. Kind is "customer" or "key".
+type RestoreConflict struct {
+	Kind       string
+	Name       string
+	Resolution string
+}
+
+// RestoreReport counts the customers and keys written by a restore and
+// those that were already up to date.
+type RestoreReport struct {
+	Customers int
+	Keys      int
+	Unchanged int
+	Conflicts []RestoreConflict
+}
+
+// Backup writes and restores archives of the customers of every tenant and
+// the keys of a cache. It is not scoped to a tenant: an archive holds the
+// customers of all tenants and restoring it writes them back to their own
+// tenants. An archive is a gzipped tar file with a manifest,
+// the customers as stored by the persistent repositories, so PII stays
+// encrypted when a keyring is configured, and the cache keys with their
+// remaining TTLs. Customers and keys are read one after the other, not as a
+// single point in time, and undelivered outbox events are not included.
+type Backup struct {
+	repository BackupRepository
+	cache      *rediscache.Cache
+	codec      customerCodec
+}
+
+func NewBackup(repository BackupRepository, cache *rediscache.Cache, opts ...StoreOption) *Backup {
+	return &Backup{repository: repository, cache: cache, codec: newCustomerCodec(opts)}
+}
+
+// Write writes an archive to w and returns its manifest.
+func (b *Backup) Write(ctx context.Context, w io.Writer) (*BackupManifest, error) {
+	tenants, err := b.repository.Tenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var records []*customerRecord
+	for _, tenantID := range tenants {
+		customers, err := b.repository.Customers(domain.WithTenant(ctx, tenantID))
+		if err != nil {
+			return nil, err
+		}
+		for _, customer := range customers {
+			record, err := b.codec.encode(customer)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+	}
+
+	var entries []backupEntry
+	for _, entry := range b.cache.Snapshot() {
+		if b.customerStoreKey(entry.Key) {
+			continue
+		}
+		archived, err := newBackupEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, archived)
+	}
+
+	files := make(map[string][]byte)
+	if files[backupCustomersFile], err = json.Marshal(records); err != nil {
+		return nil, err
+	}
+	if files[backupCacheFile], err = json.Marshal(entries); err != nil {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{
+		Version:   backupVersion,
+		CreatedAt: time.Now().UTC(),
+		Customers: len(records),
+		Keys:      len(entries),
+		Checksums: make(map[string]string),
+	}
+	for name, data := range files {
+		manifest.Checksums[name] = checksum(data)
+	}
+	if files[backupManifestFile], err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	for _, name := range []string{backupManifestFile, backupCustomersFile, backupCacheFile} {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(files[name])),
+			ModTime: manifest.CreatedAt,
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := archive.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore validates the archive read from r completely, then writes its
+// customers and keys. Conflicts are resolved according to mode and listed in
+// the report. Restored customers get a new version; archived TTLs count from
+// the time of the restore.
+func (b *Backup) Restore(ctx context.Context, r io.Reader, mode RestoreMode) (*RestoreReport, error) {
+	if mode != RestoreMerge && mode != RestoreReplace {
+		return nil, fmt.Errorf("unknown restore mode %q, expected merge or replace", mode)
+	}
+
+	records, entries, err := readBackup(r)
+	if err != nil {
+		return nil, err
+	}
+	customers := make([]*domain.Customer, 0, len(records))
+	for _, record := range records {
+		customer, err := b.codec.decode(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode customer %s: %w", record.ID, err)
+		}
+		customers = append(customers, customer)
+	}
+
+	report := &RestoreReport{}
+	for _, customer := range customers {
+		if err := b.restoreCustomer(ctx, customer, mode, report); err != nil {
+			return report, err
+		}
+	}
+	for _, entry := range entries {
+		if err := b.restoreKey(entry, mode, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (b *Backup) restoreCustomer(ctx context.Context, customer *domain.Customer, mode RestoreMode, report *RestoreReport) error {
+	ctx = domain.WithTenant(ctx, customer.TenantID)
+	conflict := RestoreConflict{Kind: "customer", Name: customer.TenantID + "/" + customer.Email}
+
+	existing, err := b.repository.FindByEmail(ctx, customer.Email)
+	switch {
+	case errors.Is(err, domain.ErrCustomerNotFound):
+		customer.Version = 0
+	case err != nil:
+		return err
+	case sameCustomer(existing, customer):
+		report.Unchanged++
+		return nil
+	case mode == RestoreMerge:
+		conflict.Resolution = "kept existing"
+		report.Conflicts = append(report.Conflicts, conflict)
+		return nil
+	default:
+		customer.Version = existing.Version
+		conflict.Resolution = "replaced"
+	}
+
+	err = b.repository.Save(ctx, customer)
+	if errors.Is(err, domain.ErrVersionConflict) || errors.Is(err, domain.ErrDuplicatePhone) {
+		conflict.Resolution = "not restored: " + err.Error()
+		report.Conflicts = append(report.Conflicts, conflict)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if conflict.Resolution != "" {
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+	report.Customers++
+	return nil
+}
+
+func (b *Backup) restoreKey(entry rediscache.Entry, mode RestoreMode, report *RestoreReport) error {
+	conflict := RestoreConflict{Kind: "key", Name: entry.Key}
+
+	if existing, found := b.cache.Dump(entry.Key); found {
+		if existing.Type == entry.Type && reflect.DeepEqual(existing.Value, entry.Value) {
+			report.Unchanged++
+			return nil
+		}
+		if mode == RestoreMerge {
+			conflict.Resolution = "kept existing"
+			report.Conflicts = append(report.Conflicts, conflict)
+			return nil
+		}
+		conflict.Resolution = "replaced"
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+
+	// Restore replaces the whole key, so neither the old value nor its TTL
+	// carry over.
+	if err := b.cache.Restore(entry); err != nil {
+		return fmt.Errorf("failed to restore key %s: %w", entry.Key, err)
+	}
+	report.Keys++
+	return nil
+}
+
+// newBackupEntry converts a cache entry to its archived form.
+func newBackupEntry(entry rediscache.Entry) (backupEntry, error) {
+	// Round up so a key about to expire is not restored without a TTL.
+	ttl := (entry.TTL + time.Millisecond - 1) / time.Millisecond
+	archived := backupEntry{Key: entry.Key, Type: entry.Type, TTL: int64(ttl)}
+
+	var err error
+	switch value := entry.Value.(type) {
+	case []interface{}:
+		archived.Items = make([]backupScalar, len(value))
+		for i, item := range value {
+			if archived.Items[i], err = newBackupScalar(item); err != nil {
+				break
+			}
+		}
+	case map[string]interface{}:
+		archived.Fields = make(map[string]backupScalar, len(value))
+		for field, item := range value {
+			if archived.Fields[field], err = newBackupScalar(item); err != nil {
+				break
+			}
+		}
+	case []string:
+		archived.Members = value
+	case []rediscache.ZMember:
+		archived.Scores = make([]backupScore, len(value))
+		for i, m := range value {
+			archived.Scores[i] = backupScore{Member: m.Member, Score: strconv.FormatFloat(m.Score, 'g', -1, 64)}
+		}
+	default:
+		var scalar backupScalar
+		scalar, err = newBackupScalar(value)
+		archived.Value = &scalar
+	}
+	if err != nil {
+		return backupEntry{}, fmt.Errorf("%w: key %s holds %T", ErrUnsupportedValue, entry.Key, entry.Value)
+	}
+	return archived, nil
+}
+
+// cacheEntry converts an archived entry back to a cache entry.
+func (e backupEntry) cacheEntry() (rediscache.Entry, error) {
+	entry := rediscache.Entry{Key: e.Key, Type: e.Type, TTL: time.Duration(e.TTL) * time.Millisecond}
+
+	var err error
+	switch e.Type {
+	case rediscache.StringType:
+		if e.Value == nil {
+			return entry, fmt.Errorf("key %s has no value", e.Key)
+		}
+		entry.Value, err = e.Value.value()
+	case rediscache.ListType:
+		items := make([]interface{}, len(e.Items))
+		for i, item := range e.Items {
+			if items[i], err = item.value(); err != nil {
+				break
+			}
+		}
+		entry.Value = items
+	case rediscache.HashType:
+		fields := make(map[string]interface{}, len(e.Fields))
+		for field, item := range e.Fields {
+			if fields[field], err = item.value(); err != nil {
+				break
+			}
+		}
+		entry.Value = fields
+	case rediscache.SetType:
+		entry.Value = append([]string{}, e.Members...)
+	case rediscache.SortedSetType:
+		members := make([]rediscache.ZMember, len(e.Scores))
+		for i, score := range e.Scores {
+			members[i].Member = score.Member
+			if members[i].Score, err = strconv.ParseFloat(score.Score, 64); err != nil {
+				break
+			}
+		}
+		entry.Value = members
+	default:
+		err = fmt.Errorf("unknown type %q", e.Type)
+	}
+	if err != nil {
+		return entry, fmt.Errorf("key %s: %w", e.Key, err)
+	}
+	return entry, nil
+}
+
+// newBackupScalar archives a plain value. Only the types below can be
+// restored as they were; other values are refused rather than degraded to
+// whatever JSON makes of them.
+func newBackupScalar(value interface{}) (backupScalar, error) {
+	var kind string
+	switch value.(type) {
+	case string:
+		kind = "string"
+	case []byte:
+		kind = "bytes"
+	case int:
+		kind = "int"
+	case int64:
+		kind = "int64"
+	case float64:
+		kind = "float64"
+	case bool:
+		kind = "bool"
+	default:
+		return backupScalar{}, fmt.Errorf("%w: %T", ErrUnsupportedValue, value)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return backupScalar{}, fmt.Errorf("%w: %v", ErrUnsupportedValue, err)
+	}
+	return backupScalar{Kind: kind, Value: data}, nil
+}
+
+// value decodes the archived value as its original type.
+func (s backupScalar) value() (interface{}, error) {
+	var err error
+	switch s.Kind {
+	case "string":
+		var v string
+		err = json.Unmarshal(s.Value, &v)
+		return v, err
+	case "bytes":
+		var v []byte
+		err = json.Unmarshal(s.Value, &v)
+		return v, err
+	case "int":
+		var v int
+		err = json.Unmarshal(s.Value, &v)
+		return v, err
+	case "int64":
+		var v int64
+		err = json.Unmarshal(s.Value, &v)
+		return v, err
+	case "float64":
+		var v float64
+		err = json.Unmarshal(s.Value, &v)
+		return v, err
+	case "bool":
+		var v bool
+		err = json.Unmarshal(s.Value, &v)
+		return v, err
+	}
+	return nil, fmt.Errorf("unknown value kind %q", s.Kind)
+}
+
+// cacheKeyOwner is implemented by repositories that keep their own keys in a
+// cache. Those keys are rebuilt from the customers and not backed up.
+type cacheKeyOwner interface {
+	ownsCacheKey(cache *rediscache.Cache, key string) bool
+}
+
+func (b *Backup) customerStoreKey(key string) bool {
+	owner, ok := b.repository.(cacheKeyOwner)
+	return ok && owner.ownsCacheKey(b.cache, key)
+}
+
+// readBackup reads an archive and checks its version and checksums.
+func readBackup(r io.Reader) ([]*customerRecord, []rediscache.Entry, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	archive := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if header.Typeflag != tar.TypeReg || files[header.Name] != nil {
+			return nil, nil, fmt.Errorf("%w: unexpected member %s", ErrInvalidBackup, header.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(archive, maxBackupFileSize+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if len(data) > maxBackupFileSize {
+			return nil, nil, fmt.Errorf("%w: %s is too large", ErrInvalidBackup, header.Name)
+		}
+		files[header.Name] = data
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(files[backupManifestFile], &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: missing or unreadable manifest", ErrInvalidBackup)
+	}
+	if manifest.Version != backupVersion {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedBackup, manifest.Version)
+	}
+	if len(files) != len(manifest.Checksums)+1 {
+		return nil, nil, fmt.Errorf("%w: files do not match the manifest", ErrInvalidBackup)
+	}
+	for name, sum := range manifest.Checksums {
+		data, ok := files[name]
+		if !ok || checksum(data) != sum {
+			return nil, nil, fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBackup, name)
+		}
+	}
+
+	var records []*customerRecord
+	if err := json.Unmarshal(files[backupCustomersFile], &records); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, backupCustomersFile, err)
+	}
+	entries, err := readBackupEntries(files[backupCacheFile])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, backupCacheFile, err)
+	}
+	if len(records) != manifest.Customers || len(entries) != manifest.Keys {
+		return nil, nil, fmt.Errorf("%w: counts do not match the manifest", ErrInvalidBackup)
+	}
+	return records, entries, nil
+}
+
+// readBackupEntries decodes the cache keys of an archive.
+func readBackupEntries(data []byte) ([]rediscache.Entry, error) {
+	var archived []backupEntry
+	if err := json.Unmarshal(data, &archived); err != nil {
+		return nil, err
+	}
+
+	entries := make([]rediscache.Entry, 0, len(archived))
+	for _, entry := range archived {
+		decoded, err := entry.cacheEntry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, decoded)
+	}
+	return entries, nil
+}
+
+// sameCustomer compares customers by their stored fields, ignoring the
+// version.
+func sameCustomer(a, b *domain.Customer) bool {
+	a, b = a.Clone(), b.Clone()
+	a.Version, b.Version = 0, 0
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(left) == string(right)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
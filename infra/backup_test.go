@@ -0,0 +1,251 @@
+package infra
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := NewCustomerRepository()
+	require.NoError(t, source.Save(ctx, &domain.Customer{ID: "c1", Email: "john@example.com", KYCStatus: "approved"}))
+	require.NoError(t, source.Save(domain.WithTenant(ctx, "acme"), &domain.Customer{ID: "a1", Email: "jane@example.com"}))
+
+	sourceCache := rediscache.NewRedisCache(2)
+	sourceCache.Set("session", "abc", time.Hour)
+	sourceCache.Set("plan", "gold", 0)
+	sourceCache.Set("gone", "soon", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	var archive bytes.Buffer
+	manifest, err := NewBackup(source, sourceCache).Write(ctx, &archive)
+	require.NoError(t, err)
+	assert.Equal(t, 2, manifest.Customers)
+	assert.Equal(t, 2, manifest.Keys)
+
+	target := NewCustomerRepository()
+	targetCache := rediscache.NewRedisCache(2)
+	report, err := NewBackup(target, targetCache).Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Customers)
+	assert.Equal(t, 2, report.Keys)
+	assert.Empty(t, report.Conflicts)
+
+	restored, err := target.FindByEmail(domain.WithTenant(ctx, "acme"), "jane@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", restored.ID)
+	assert.Equal(t, "gold", cacheValue(t, targetCache, "plan"))
+	assert.Nil(t, cacheValue(t, targetCache, "gone"))
+	for _, entry := range targetCache.Snapshot() {
+		if entry.Key == "session" {
+			assert.InDelta(t, time.Hour, entry.TTL, float64(time.Minute))
+		}
+	}
+
+	// Restoring again changes nothing.
+	report, err = NewBackup(target, targetCache).Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Unchanged)
+}
+
+func TestBackupRestoreModes(t *testing.T) {
+	ctx := context.Background()
+	source := NewCustomerRepository()
+	require.NoError(t, source.Save(ctx, &domain.Customer{ID: "c1", Email: "john@example.com", KYCStatus: "approved"}))
+	sourceCache := rediscache.NewRedisCache(2)
+	sourceCache.Set("plan", "gold", 0)
+
+	var archive bytes.Buffer
+	_, err := NewBackup(source, sourceCache).Write(ctx, &archive)
+	require.NoError(t, err)
+
+	target := NewCustomerRepository()
+	require.NoError(t, target.Save(ctx, &domain.Customer{ID: "c1", Email: "john@example.com", KYCStatus: "pending"}))
+	targetCache := rediscache.NewRedisCache(2)
+	targetCache.Set("plan", "silver", time.Hour)
+	backup := NewBackup(target, targetCache)
+
+	report, err := backup.Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	require.NoError(t, err)
+	assert.Len(t, report.Conflicts, 2)
+	customer, _ := target.FindByEmail(ctx, "john@example.com")
+	assert.Equal(t, "pending", customer.KYCStatus)
+	assert.Equal(t, "silver", cacheValue(t, targetCache, "plan"))
+
+	report, err = backup.Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreReplace)
+	require.NoError(t, err)
+	require.Len(t, report.Conflicts, 2)
+	assert.Equal(t, "replaced", report.Conflicts[0].Resolution)
+	customer, _ = target.FindByEmail(ctx, "john@example.com")
+	assert.Equal(t, "approved", customer.KYCStatus)
+	assert.Equal(t, 2, customer.Version)
+	assert.Equal(t, "gold", cacheValue(t, targetCache, "plan"))
+	assert.Equal(t, time.Duration(0), targetCache.Snapshot()[0].TTL)
+
+	_, err = backup.Restore(ctx, bytes.NewReader(archive.Bytes()), "overwrite")
+	assert.Error(t, err)
+}
+
+func TestBackupRejectsDamagedArchives(t *testing.T) {
+	ctx := context.Background()
+	source := NewCustomerRepository()
+	require.NoError(t, source.Save(ctx, &domain.Customer{ID: "c1", Email: "john@example.com"}))
+
+	var archive bytes.Buffer
+	_, err := NewBackup(source, rediscache.NewRedisCache(2)).Write(ctx, &archive)
+	require.NoError(t, err)
+
+	// Rewrite the archive with a modified customers file.
+	files := readArchive(t, archive.Bytes())
+	files[backupCustomersFile] = bytes.Replace(files[backupCustomersFile], []byte("john"), []byte("jack"), 1)
+	target := NewCustomerRepository()
+	_, err = NewBackup(target, rediscache.NewRedisCache(2)).Restore(ctx, bytes.NewReader(writeArchive(t, files)), RestoreMerge)
+	assert.ErrorIs(t, err, ErrInvalidBackup)
+	customers, _ := target.Customers(ctx)
+	assert.Empty(t, customers)
+
+	_, err = NewBackup(target, rediscache.NewRedisCache(2)).Restore(ctx, bytes.NewReader(archive.Bytes()[:archive.Len()/2]), RestoreMerge)
+	assert.ErrorIs(t, err, ErrInvalidBackup)
+
+	files = readArchive(t, archive.Bytes())
+	files[backupManifestFile] = bytes.Replace(files[backupManifestFile], []byte(`"version": 1`), []byte(`"version": 9`), 1)
+	_, err = NewBackup(target, rediscache.NewRedisCache(2)).Restore(ctx, bytes.NewReader(writeArchive(t, files)), RestoreMerge)
+	assert.ErrorIs(t, err, ErrUnsupportedBackup)
+}
+
+func TestBackupKeepsValueTypes(t *testing.T) {
+	ctx := context.Background()
+	sourceCache := rediscache.NewRedisCache(2)
+	sourceCache.Set("count", int64(42), 0)
+	sourceCache.Set("ratio", 0.5, 0)
+	sourceCache.Set("enabled", true, 0)
+	sourceCache.Set("name", "42", 0)
+
+	var archive bytes.Buffer
+	_, err := NewBackup(NewCustomerRepository(), sourceCache).Write(ctx, &archive)
+	require.NoError(t, err)
+
+	targetCache := rediscache.NewRedisCache(2)
+	_, err = NewBackup(NewCustomerRepository(), targetCache).Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), cacheValue(t, targetCache, "count"))
+	assert.Equal(t, 0.5, cacheValue(t, targetCache, "ratio"))
+	assert.Equal(t, true, cacheValue(t, targetCache, "enabled"))
+	assert.Equal(t, "42", cacheValue(t, targetCache, "name"))
+
+	// Values the archive cannot restore as they were are refused.
+	sourceCache.Set("result", struct{ Err error }{}, 0)
+	_, err = NewBackup(NewCustomerRepository(), sourceCache).Write(ctx, &archive)
+	assert.ErrorIs(t, err, ErrUnsupportedValue)
+}
+
+func TestBackupRoundTripOfNativeTypes(t *testing.T) {
+	ctx := context.Background()
+	sourceCache := rediscache.NewRedisCache(2)
+	sourceCache.RPush("queue", "a", int64(2), "a")
+	sourceCache.Expire("queue", time.Hour)
+	sourceCache.HSet("profile", map[string]interface{}{"name": "john", "visits": int64(3)})
+	sourceCache.SAdd("tags", "vip", "beta")
+	sourceCache.ZAdd("scores", rediscache.ZAddOptions{},
+		rediscache.ZMember{Member: "john", Score: 1.5},
+		rediscache.ZMember{Member: "jane", Score: math.Inf(-1)})
+
+	var archive bytes.Buffer
+	manifest, err := NewBackup(NewCustomerRepository(), sourceCache).Write(ctx, &archive)
+	require.NoError(t, err)
+	assert.Equal(t, 4, manifest.Keys)
+
+	targetCache := rediscache.NewRedisCache(2)
+	targetCache.SAdd("tags", "old")
+	report, err := NewBackup(NewCustomerRepository(), targetCache).Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreReplace)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Keys)
+	require.Len(t, report.Conflicts, 1)
+	assert.Equal(t, "tags", report.Conflicts[0].Name)
+
+	source, target := sourceCache.Snapshot(), targetCache.Snapshot()
+	require.Len(t, target, len(source))
+	for i := range source {
+		assert.Equal(t, source[i].Key, target[i].Key)
+		assert.Equal(t, source[i].Type, target[i].Type)
+		assert.Equal(t, source[i].Value, target[i].Value)
+		assert.InDelta(t, source[i].TTL, target[i].TTL, float64(time.Minute))
+	}
+
+	// Restoring again changes nothing.
+	report, err = NewBackup(NewCustomerRepository(), targetCache).Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Unchanged)
+	assert.Empty(t, report.Conflicts)
+}
+
+func TestBackupOfEncryptedCacheStore(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := LoadKeyring(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	cache := rediscache.NewRedisCache(2)
+	repository := NewCacheCustomerRepository(cache, WithKeyring(keyring))
+	require.NoError(t, repository.Save(ctx, &domain.Customer{ID: "c1", Email: "john@example.com"}))
+	cache.Set("plan", "gold", 0)
+
+	var archive bytes.Buffer
+	manifest, err := NewBackup(repository, cache, WithKeyring(keyring)).Write(ctx, &archive)
+	require.NoError(t, err)
+	// The store's own keys are rebuilt from the customers.
+	assert.Equal(t, 1, manifest.Keys)
+	assert.NotContains(t, string(readArchive(t, archive.Bytes())[backupCustomersFile]), "john@example.com")
+
+	targetCache := rediscache.NewRedisCache(2)
+	target := NewCacheCustomerRepository(targetCache, WithKeyring(keyring))
+	report, err := NewBackup(target, targetCache, WithKeyring(keyring)).Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Customers)
+	_, err = target.FindByEmail(ctx, "john@example.com")
+	assert.NoError(t, err)
+
+	_, err = NewBackup(NewCustomerRepository(), rediscache.NewRedisCache(2)).Restore(ctx, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	archive := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		files[header.Name], err = io.ReadAll(archive)
+		require.NoError(t, err)
+	}
+}
+
+func writeArchive(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for name, data := range files {
+		require.NoError(t, archive.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data))}))
+		_, err := archive.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
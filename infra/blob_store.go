@@ -0,0 +1,137 @@
+package infra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// sniffLen is the number of leading bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// LocalBlobStore is a content-addressed blob store on the local filesystem.
+// Blobs are kept apart per owner, and every blob is stored once per owner
+// under the hex SHA-256 of its content. Deleting the blob of one owner never
+// removes the same file attached by another.
+type LocalBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) *LocalBlobStore {
+	return &LocalBlobStore{dir: dir}
+}
+
+// Put copies content into the store for owner and returns its checksum,
+// sniffed MIME type and size. Storing the same content twice for the same
+// owner keeps a single copy.
+func (s *LocalBlobStore) Put(ctx context.Context, owner string, content io.Reader) (domain.Blob, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return domain.Blob{}, err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return domain.Blob{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	head := &prefixWriter{limit: sniffLen}
+	size, err := io.Copy(io.MultiWriter(tmp, hash, head), content)
+	if err != nil {
+		return domain.Blob{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return domain.Blob{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return domain.Blob{}, err
+	}
+
+	blob := domain.Blob{
+		ID:       hex.EncodeToString(hash.Sum(nil)),
+		MIMEType: http.DetectContentType(head.buf),
+		Size:     size,
+	}
+
+	path := s.path(owner, blob.ID)
+	if _, err := os.Stat(path); err == nil {
+		return blob, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return domain.Blob{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return domain.Blob{}, err
+	}
+
+	return blob, nil
+}
+
+// Delete removes the blob of owner with the given ID. Deleting a missing blob
+// is not an error.
+func (s *LocalBlobStore) Delete(ctx context.Context, owner, id string) error {
+	if err := os.Remove(s.path(owner, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DeleteOwner removes every blob of owner. Deleting an owner without blobs
+// is not an error.
+func (s *LocalBlobStore) DeleteOwner(ctx context.Context, owner string) error {
+	return os.RemoveAll(s.ownerDir(owner))
+}
+
+// Open returns the content of the blob of owner with the given ID.
+func (s *LocalBlobStore) Open(ctx context.Context, owner, id string) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(owner, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return file, err
+}
+
+// path puts the blobs of an owner in a directory named after the hex
+// SHA-256 of the owner, so any owner name is a safe directory name, and fans
+// them out over sub-directories named after the first two hex characters of
+// their ID.
+func (s *LocalBlobStore) path(owner, id string) string {
+	dir := s.ownerDir(owner)
+	if len(id) < 2 {
+		return filepath.Join(dir, id)
+	}
+	return filepath.Join(dir, id[:2], id)
+}
+
+// ownerDir is the directory holding every blob of owner.
+func (s *LocalBlobStore) ownerDir(owner string) string {
+	sum := sha256.Sum256([]byte(owner))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// prefixWriter keeps the first limit bytes written to it.
+type prefixWriter struct {
+	buf   []byte
+	limit int
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if room := w.limit - len(w.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		w.buf = append(w.buf, p[:room]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,103 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalBlobStore(dir)
+	ctx := context.Background()
+
+	content := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("x"), 1024)...)
+	sum := sha256.Sum256(content)
+
+	blob, err := store.Put(ctx, "acme/c1", bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:]), blob.ID)
+	assert.Equal(t, "application/pdf", blob.MIMEType)
+	assert.Equal(t, int64(len(content)), blob.Size)
+
+	// The same content is stored once.
+	again, err := store.Put(ctx, "acme/c1", bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, blob, again)
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*"))
+	assert.Len(t, files, 1)
+
+	reader, err := store.Open(ctx, "acme/c1", blob.ID)
+	require.NoError(t, err)
+	defer reader.Close()
+	stored, _ := io.ReadAll(reader)
+	assert.Equal(t, content, stored)
+
+	_, err = store.Open(ctx, "acme/c1", "missing")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "upload-*"))
+	assert.Empty(t, leftovers)
+	_, err = os.Stat(store.path("acme/c1", blob.ID))
+	assert.NoError(t, err)
+
+	// Another owner gets its own copy, which outlives the first one.
+	_, err = store.Put(ctx, "acme/c2", bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(ctx, "acme/c1", blob.ID))
+	_, err = store.Open(ctx, "acme/c1", blob.ID)
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+	reader, err = store.Open(ctx, "acme/c2", blob.ID)
+	require.NoError(t, err)
+	reader.Close()
+}
+
+func TestLocalBlobStoreIsPrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blobs")
+	store := NewLocalBlobStore(dir)
+
+	blob, err := store.Put(context.Background(), "acme/c1", bytes.NewReader([]byte("%PDF-1.4\n")))
+	require.NoError(t, err)
+
+	path := store.path("acme/c1", blob.ID)
+	for p := path; p != filepath.Dir(dir); p = filepath.Dir(p) {
+		info, err := os.Stat(p)
+		require.NoError(t, err)
+		want := os.FileMode(0o700)
+		if p == path {
+			want = 0o600
+		}
+		assert.Equal(t, want, info.Mode().Perm(), p)
+	}
+}
+
+func TestLocalBlobStoreDeleteOwner(t *testing.T) {
+	store := NewLocalBlobStore(t.TempDir())
+	ctx := context.Background()
+
+	first, err := store.Put(ctx, "acme/c1", bytes.NewReader([]byte("%PDF-1.4 first")))
+	require.NoError(t, err)
+	second, err := store.Put(ctx, "acme/c1", bytes.NewReader([]byte("%PDF-1.4 second")))
+	require.NoError(t, err)
+	other, err := store.Put(ctx, "acme/c2", bytes.NewReader([]byte("%PDF-1.4 first")))
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteOwner(ctx, "acme/c1"))
+	for _, id := range []string{first.ID, second.ID} {
+		_, err := store.Open(ctx, "acme/c1", id)
+		assert.ErrorIs(t, err, ErrBlobNotFound)
+	}
+	reader, err := store.Open(ctx, "acme/c2", other.ID)
+	require.NoError(t, err)
+	reader.Close()
+
+	require.NoError(t, store.DeleteOwner(ctx, "acme/c1"))
+}
@@ -0,0 +1,526 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/macadrich/go-task-challenge/constants"
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+)
+
+const (
+	// customerOutboxKey holds the JSON array of undelivered events.
+	customerOutboxKey = constants.ReservedKeyPrefix + "customer-outbox"
+	// customerTenantsKey is the set of tenants that have customers.
+	customerTenantsKey = constants.ReservedKeyPrefix + "customer-tenants"
+)
+
+// CacheCustomerRepository keeps customers in a rediscache.Cache. Each customer
+// is stored as JSON under its ID, with secondary index keys mapping emails to
+// IDs and sets of IDs per tenant and status:
+//
+//	__kyc:customer:<tenant>:<id>             customer record JSON
+//	__kyc:customer-email:<tenant>:<email>    customer ID
+//	__kyc:customer-status:<tenant>:<status>  set of customer IDs
+//	__kyc:customer-ids:<tenant>              set of customer IDs
+//	__kyc:customer-tenants                   set of tenant IDs
+//	__kyc:customer-outbox                    JSON array of undelivered events
+//
+// Every key starts with constants.ReservedKeyPrefix, which the REPL refuses.
+// Tenant IDs are validated with domain.ValidateTenantID and hold no ':', so
+// the keys of one tenant never match those of another.
+// With a keyring the PII in records is encrypted and the email in index keys
+// is replaced by its blind index. The cache has no transactions, so writes
+// are serialised by the repository. The change feed lives in the repository,
+// like the cache itself.
+type CacheCustomerRepository struct {
+	mu    sync.Mutex
+	cache *rediscache.Cache
+	codec customerCodec
+	feed  *changeFeed
+}
+
+func NewCacheCustomerRepository(cache *rediscache.Cache, opts ...StoreOption) *CacheCustomerRepository {
+	return &CacheCustomerRepository{cache: cache, codec: newCustomerCodec(opts), feed: newChangeFeed()}
+}
+
+// Save stores customer with the same version checks as CustomerRepository.
+// Customers without an ID get one assigned.
+func (r *CacheCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
+	return r.Commit(ctx, []*domain.Customer{customer}, nil)
+}
+
+// Commit saves customers and appends events to the outbox key. Every record
+// is validated and encoded before the first key is written, the records,
+// email keys and outbox are written with a single MSET, and readers are held
+// off by the repository lock until the indexes are updated too.
+func (r *CacheCustomerRepository) Commit(ctx context.Context, customers []*domain.Customer, events []domain.Event) error {
+	for _, customer := range customers {
+		if err := domain.CheckTenant(ctx, customer); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type change struct {
+		stored *domain.Customer
+		record *domain.Customer
+		data   string
+	}
+
+	// saved holds records of this commit so a customer saved twice is
+	// checked against its first write.
+	saved := make(map[string]*domain.Customer)
+	changes := make([]change, 0, len(customers))
+	for _, customer := range customers {
+		stored, err := r.stored(customer, saved)
+		if err != nil {
+			return err
+		}
+
+		var current int
+		if stored != nil {
+			current = stored.Version
+			if customer.ID == "" {
+				customer.ID = stored.ID
+			}
+			if customer.ID != stored.ID {
+				return domain.ErrVersionConflict
+			}
+		}
+		if customer.Version != current {
+			return domain.ErrVersionConflict
+		}
+		if customer.ID == "" {
+			customer.ID = domain.NewCustomerID()
+		}
+
+		record := customer.Clone()
+		record.Version = current + 1
+		data, err := r.encode(record)
+		if err != nil {
+			return err
+		}
+
+		saved[customer.TenantID+":"+record.ID] = record
+		saved[customer.TenantID+":"+record.Email] = record
+		changes = append(changes, change{stored: stored, record: record, data: data})
+	}
+
+	outbox, err := r.pendingEvents()
+	if err != nil {
+		return err
+	}
+	outboxData, err := json.Marshal(append(outbox, events...))
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{}, 2*len(changes)+1)
+	for _, change := range changes {
+		record := change.record
+		values[customerKey(record.TenantID, record.ID)] = change.data
+		values[r.emailKey(record.TenantID, record.Email)] = record.ID
+	}
+	if len(events) > 0 {
+		values[customerOutboxKey] = string(outboxData)
+	}
+	if err := r.cache.MSet(values); err != nil {
+		return err
+	}
+
+	for i, change := range changes {
+		record, stored, tenantID := change.record, change.stored, change.record.TenantID
+		if err := r.updateIndexes(stored, record); err != nil {
+			return err
+		}
+		if stored != nil && stored.Email != record.Email {
+			r.cache.Del(r.emailKey(tenantID, stored.Email))
+		}
+
+		r.feed.append(stored, record)
+		customers[i].Version = record.Version
+	}
+
+	return nil
+}
+
+// PendingEvents returns up to limit undelivered events in commit order.
+func (r *CacheCustomerRepository) PendingEvents(ctx context.Context, limit int) ([]domain.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events, err := r.pendingEvents()
+	if err != nil {
+		return nil, err
+	}
+	if limit < len(events) {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// MarkDelivered removes the events with the given IDs from the outbox.
+func (r *CacheCustomerRepository) MarkDelivered(ctx context.Context, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events, err := r.pendingEvents()
+	if err != nil {
+		return err
+	}
+
+	delivered := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		delivered[id] = true
+	}
+	pending := events[:0]
+	for _, event := range events {
+		if !delivered[event.ID] {
+			pending = append(pending, event)
+		}
+	}
+
+	if len(pending) == 0 {
+		r.cache.Del(customerOutboxKey)
+		return nil
+	}
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return r.cache.Set(customerOutboxKey, string(data), 0)
+}
+
+// Watch streams the changes to customers of the context's tenant with a
+// sequence number above fromSeq until ctx is cancelled.
+func (r *CacheCustomerRepository) Watch(ctx context.Context, fromSeq uint64) (<-chan domain.Change, error) {
+	return r.feed.watch(ctx, domain.TenantFromContext(ctx), fromSeq)
+}
+
+// Reencrypt rewrites every record that is not sealed with the active key of
+// the keyring and returns the number of records rewritten. Each record is
+// rewritten under the repository lock, so it can run alongside other calls.
+func (r *CacheCustomerRepository) Reencrypt(ctx context.Context) (int, error) {
+	if r.codec.keyring == nil {
+		return 0, nil
+	}
+	activeKeyID := r.codec.keyring.ActiveKeyID()
+	return r.rewrite(ctx, func(record *customerRecord) bool {
+		return record.KeyID != activeKeyID
+	})
+}
+
+// Migrate rewrites every record stored with an older schema version and
+// returns the number of records rewritten. Older records are upgraded when
+// they are read, so this only updates the stored form.
+func (r *CacheCustomerRepository) Migrate(ctx context.Context) (int, error) {
+	return r.rewrite(ctx, func(record *customerRecord) bool {
+		return record.storedVersion < currentSchemaVersion
+	})
+}
+
+// rewrite encodes the records selected by outdated again, one at a time under
+// the repository lock.
+func (r *CacheCustomerRepository) rewrite(ctx context.Context, outdated func(*customerRecord) bool) (int, error) {
+	r.mu.Lock()
+	ids, err := r.customerIDs()
+	r.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	rewritten := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return rewritten, err
+		}
+
+		done, err := r.rewriteRecord(id[0], id[1], outdated)
+		if err != nil {
+			return rewritten, err
+		}
+		if done {
+			rewritten++
+		}
+	}
+	return rewritten, nil
+}
+
+func (r *CacheCustomerRepository) rewriteRecord(tenantID, id string, outdated func(*customerRecord) bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, err := r.record(tenantID, id)
+	if err == domain.ErrCustomerNotFound {
+		return false, nil
+	}
+	if err != nil || !outdated(record) {
+		return false, err
+	}
+
+	customer, err := r.codec.decode(record)
+	if err != nil {
+		return false, err
+	}
+	data, err := r.encode(customer)
+	if err != nil {
+		return false, err
+	}
+	if err := r.cache.Set(customerKey(tenantID, id), data, 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// stored returns the current record for customer, looking at records written
+// earlier in the same commit first. The customer may exist under a different
+// email if it has an ID.
+func (r *CacheCustomerRepository) stored(customer *domain.Customer, saved map[string]*domain.Customer) (*domain.Customer, error) {
+	tenantID := customer.TenantID
+	if record, ok := saved[tenantID+":"+customer.Email]; ok {
+		return record, nil
+	}
+	stored, err := r.findByEmail(tenantID, customer.Email)
+	if err != domain.ErrCustomerNotFound {
+		return stored, err
+	}
+	if customer.ID == "" {
+		return nil, nil
+	}
+
+	if record, ok := saved[tenantID+":"+customer.ID]; ok {
+		return record, nil
+	}
+	stored, err = r.findByID(tenantID, customer.ID)
+	if err == domain.ErrCustomerNotFound {
+		return nil, nil
+	}
+	return stored, err
+}
+
+func (r *CacheCustomerRepository) FindByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	if err := domain.ValidateTenantID(tenantID); err != nil {
+		return nil, err
+	}
+	return r.findByEmail(tenantID, email)
+}
+
+// FindByStatus returns the tenant's customers with the given KYC status.
+func (r *CacheCustomerRepository) FindByStatus(ctx context.Context, status string) ([]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	if err := domain.ValidateTenantID(tenantID); err != nil {
+		return nil, err
+	}
+	ids, err := r.cache.SMembers(customerStatusKey(tenantID, status))
+	if err != nil {
+		return nil, err
+	}
+
+	customers := make([]*domain.Customer, 0, len(ids))
+	for _, id := range ids {
+		customer, err := r.findByID(tenantID, id)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, nil
+}
+
+// Customers returns the customers of the context's tenant ordered by email.
+func (r *CacheCustomerRepository) Customers(ctx context.Context) ([]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID := domain.TenantFromContext(ctx)
+	if err := domain.ValidateTenantID(tenantID); err != nil {
+		return nil, err
+	}
+	ids, err := r.cache.SMembers(customerIDsKey(tenantID))
+	if err != nil {
+		return nil, err
+	}
+
+	var customers []*domain.Customer
+	for _, id := range ids {
+		customer, err := r.findByID(tenantID, id)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	sort.Slice(customers, func(i, j int) bool { return customers[i].Email < customers[j].Email })
+	return customers, nil
+}
+
+// Tenants returns the IDs of the tenants that have customers, in order.
+func (r *CacheCustomerRepository) Tenants(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cache.SMembers(customerTenantsKey)
+}
+
+func (r *CacheCustomerRepository) findByEmail(tenantID, email string) (*domain.Customer, error) {
+	value, err := r.cache.Get(r.emailKey(tenantID, email))
+	if err != nil {
+		return nil, err
+	}
+	id, ok := value.(string)
+	if !ok {
+		return nil, domain.ErrCustomerNotFound
+	}
+	return r.findByID(tenantID, id)
+}
+
+func (r *CacheCustomerRepository) findByID(tenantID, id string) (*domain.Customer, error) {
+	record, err := r.record(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	customer, err := r.codec.decode(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode customer %s: %w", id, err)
+	}
+	return customer, nil
+}
+
+func (r *CacheCustomerRepository) record(tenantID, id string) (*customerRecord, error) {
+	value, err := r.cache.Get(customerKey(tenantID, id))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.(string)
+	if !ok {
+		return nil, domain.ErrCustomerNotFound
+	}
+
+	var record customerRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("failed to decode customer %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+func (r *CacheCustomerRepository) encode(customer *domain.Customer) (string, error) {
+	record, err := r.codec.encode(customer)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (r *CacheCustomerRepository) emailKey(tenantID, email string) string {
+	return customerEmailKey(tenantID, r.codec.emailIndex(tenantID, email))
+}
+
+// customerIDs returns every stored customer as [tenant, id] pairs.
+func (r *CacheCustomerRepository) customerIDs() ([][2]string, error) {
+	tenants, err := r.cache.SMembers(customerTenantsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids [][2]string
+	for _, tenantID := range tenants {
+		members, err := r.cache.SMembers(customerIDsKey(tenantID))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range members {
+			ids = append(ids, [2]string{tenantID, id})
+		}
+	}
+	return ids, nil
+}
+
+func (r *CacheCustomerRepository) pendingEvents() ([]domain.Event, error) {
+	value, err := r.cache.Get(customerOutboxKey)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.(string)
+	if !ok {
+		return nil, nil
+	}
+
+	var events []domain.Event
+	if err := json.Unmarshal([]byte(data), &events); err != nil {
+		return nil, fmt.Errorf("failed to decode outbox: %w", err)
+	}
+	return events, nil
+}
+
+// updateIndexes adds a new record to the ID sets and moves it to the set of
+// its status when that changed.
+func (r *CacheCustomerRepository) updateIndexes(stored, record *domain.Customer) error {
+	tenantID := record.TenantID
+	if stored == nil {
+		if _, err := r.cache.SAdd(customerTenantsKey, tenantID); err != nil {
+			return err
+		}
+		if _, err := r.cache.SAdd(customerIDsKey(tenantID), record.ID); err != nil {
+			return err
+		}
+	} else if stored.KYCStatus != record.KYCStatus {
+		if _, err := r.cache.SRem(customerStatusKey(tenantID, stored.KYCStatus), record.ID); err != nil {
+			return err
+		}
+	} else {
+		return nil
+	}
+	_, err := r.cache.SAdd(customerStatusKey(tenantID, record.KYCStatus), record.ID)
+	return err
+}
+
+// ownsCacheKey reports whether key is one of the repository's keys.
+func (r *CacheCustomerRepository) ownsCacheKey(cache *rediscache.Cache, key string) bool {
+	if cache != r.cache {
+		return false
+	}
+	if key == customerTenantsKey || key == customerOutboxKey {
+		return true
+	}
+	for _, prefix := range []string{"customer:", "customer-email:", "customer-status:", "customer-ids:"} {
+		if strings.HasPrefix(key, constants.ReservedKeyPrefix+prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func customerKey(tenantID, id string) string {
+	return constants.ReservedKeyPrefix + "customer:" + tenantID + ":" + id
+}
+
+func customerEmailKey(tenantID, email string) string {
+	return constants.ReservedKeyPrefix + "customer-email:" + tenantID + ":" + email
+}
+
+func customerStatusKey(tenantID, status string) string {
+	return constants.ReservedKeyPrefix + "customer-status:" + tenantID + ":" + status
+}
+
+func customerIDsKey(tenantID string) string {
+	return constants.ReservedKeyPrefix + "customer-ids:" + tenantID
+}
@@ -0,0 +1,125 @@
+package infra
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/macadrich/go-task-challenge/constants"
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheCustomerRepository(t *testing.T) {
+	cache := rediscache.NewRedisCache(4)
+	repository := NewCacheCustomerRepository(cache)
+	ctx := domain.WithTenant(context.Background(), "acme")
+
+	customer := &domain.Customer{Email: "john.doe@example.com", KYCStatus: "pending"}
+	require.NoError(t, repository.Save(ctx, customer))
+	assert.NotEmpty(t, customer.ID)
+	assert.Equal(t, 1, customer.Version)
+	assert.Equal(t, customer.ID, cacheValue(t, cache, "__kyc:customer-email:acme:john.doe@example.com"))
+
+	found, err := repository.FindByEmail(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, customer, found)
+
+	// Moving the customer to another status updates both index keys.
+	found.KYCStatus = "approved"
+	require.NoError(t, repository.Save(ctx, found))
+
+	pending, err := repository.FindByStatus(ctx, "pending")
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.Zero(t, cache.Exists("__kyc:customer-status:acme:pending"))
+
+	approved, err := repository.FindByStatus(ctx, "approved")
+	require.NoError(t, err)
+	require.Len(t, approved, 1)
+	assert.Equal(t, customer.ID, approved[0].ID)
+
+	// Stale writes, duplicate emails and other tenants are rejected.
+	customer.KYCStatus = "rejected"
+	assert.ErrorIs(t, repository.Save(ctx, customer), domain.ErrVersionConflict)
+	assert.ErrorIs(t, repository.Save(ctx, &domain.Customer{Email: "john.doe@example.com"}), domain.ErrVersionConflict)
+
+	_, err = repository.FindByEmail(context.Background(), "john.doe@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+}
+
+// cacheValue returns the plain value of key in cache.
+func cacheValue(t *testing.T, cache *rediscache.Cache, key string) interface{} {
+	t.Helper()
+	value, err := cache.Get(key)
+	require.NoError(t, err)
+	return value
+}
+
+func TestCacheCustomerRepositoryChangesEmail(t *testing.T) {
+	repository := NewCacheCustomerRepository(rediscache.NewRedisCache(4))
+	ctx := context.Background()
+
+	customer := &domain.Customer{Email: "old@example.com"}
+	require.NoError(t, repository.Save(ctx, customer))
+
+	customer.Email = "new@example.com"
+	require.NoError(t, repository.Save(ctx, customer))
+
+	_, err := repository.FindByEmail(ctx, "old@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	found, err := repository.FindByEmail(ctx, "new@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 2, found.Version)
+}
+
+func TestCacheCustomerRepositoryReportsFullCache(t *testing.T) {
+	repository := NewCacheCustomerRepository(rediscache.NewRedisCache(2, rediscache.WithMaxMemory(1)))
+
+	err := repository.Save(context.Background(), &domain.Customer{Email: "john.doe@example.com"})
+	assert.ErrorIs(t, err, rediscache.ErrOOM)
+}
+
+func TestCacheCustomerRepositoryIndexesAreSets(t *testing.T) {
+	cache := rediscache.NewRedisCache(4)
+	repository := NewCacheCustomerRepository(cache)
+	ctx := domain.WithTenant(context.Background(), "acme")
+
+	first := &domain.Customer{Email: "john.doe@example.com", KYCStatus: "pending"}
+	second := &domain.Customer{Email: "jane.doe@example.com", KYCStatus: "pending"}
+	require.NoError(t, repository.Commit(ctx, []*domain.Customer{first, second}, nil))
+
+	ids, err := cache.SMembers(customerIDsKey("acme"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{first.ID, second.ID}, ids)
+	pending, err := cache.SCard(customerStatusKey("acme", "pending"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, pending)
+	tenants, err := repository.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme"}, tenants)
+
+	for _, entry := range cache.Snapshot() {
+		assert.True(t, strings.HasPrefix(entry.Key, constants.ReservedKeyPrefix), entry.Key)
+	}
+}
+
+func TestCacheCustomerRepositoryRejectsSeparatorsInTenants(t *testing.T) {
+	cache := rediscache.NewRedisCache(4)
+	repository := NewCacheCustomerRepository(cache)
+	acme := domain.WithTenant(context.Background(), "acme")
+	acmeCorp := domain.WithTenant(context.Background(), "acme:corp")
+
+	// "acme:corp" + "bob@x.com" would share its email key with
+	// "acme" + "corp:bob@x.com".
+	err := repository.Save(acmeCorp, &domain.Customer{Email: "bob@x.com"})
+	assert.ErrorIs(t, err, domain.ErrInvalidTenant)
+
+	require.NoError(t, repository.Save(acme, &domain.Customer{Email: "corp:bob@x.com"}))
+	_, err = repository.FindByEmail(acmeCorp, "bob@x.com")
+	assert.ErrorIs(t, err, domain.ErrInvalidTenant)
+	_, err = repository.Customers(acmeCorp)
+	assert.ErrorIs(t, err, domain.ErrInvalidTenant)
+}
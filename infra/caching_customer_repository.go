@@ -0,0 +1,313 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/constants"
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+)
+
+// cachedMissing marks an email known not to belong to a customer.
+const cachedMissing = "missing"
+
+// CachedCustomerStore is a repository that can be wrapped by a
+// CachingCustomerRepository. All customer repositories in this package
+// implement it.
+type CachedCustomerStore interface {
+	Save(context.Context, *domain.Customer) error
+	FindByEmail(context.Context, string) (*domain.Customer, error)
+	Commit(ctx context.Context, customers []*domain.Customer, events []domain.Event) error
+	PendingEvents(ctx context.Context, limit int) ([]domain.Event, error)
+	MarkDelivered(ctx context.Context, ids []string) error
+	FindByStatus(ctx context.Context, status string) ([]*domain.Customer, error)
+	Customers(ctx context.Context) ([]*domain.Customer, error)
+	Tenants(ctx context.Context) ([]string, error)
+	Watch(ctx context.Context, fromSeq uint64) (<-chan domain.Change, error)
+}
+
+// IndexedCustomerStore is a CachedCustomerStore that also finds customers by
+// phone and last name, like CustomerRepository and FileCustomerRepository.
+type IndexedCustomerStore interface {
+	CachedCustomerStore
+	FindByPhone(ctx context.Context, phone string) (*domain.Customer, error)
+	FindByLastName(ctx context.Context, lastName string) ([]*domain.Customer, error)
+}
+
+// CacheStats counts the FindByEmail calls answered from the cache and those
+// that went to the repository.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CachingCustomerRepository reads customers by email through a
+// rediscache.Cache. Found customers are cached for ttl and unknown emails for
+// negativeTTL; saves and commits made through it invalidate the affected
+// emails. Concurrent misses for the same email share one repository read.
+// Every other method is passed through, so it can stand in for the
+// repository it wraps.
+type CachingCustomerRepository struct {
+	next        CachedCustomerStore
+	cache       *rediscache.Cache
+	codec       customerCodec
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu sync.Mutex
+	// keys remembers the cache key holding each customer, so the entry under
+	// an old email is dropped when the email changes.
+	keys     map[string]string
+	inflight map[string]*cachedRead
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// cachedRead is a repository read shared by concurrent misses. A read that
+// overlapped an invalidation is stale: it may have returned the old record,
+// so its result is not cached.
+type cachedRead struct {
+	done     chan struct{}
+	customer *domain.Customer
+	err      error
+	stale    bool
+}
+
+// NewCachingCustomerRepository wraps next. With a keyring in opts, cached
+// records are encrypted like those of the persistent repositories.
+func NewCachingCustomerRepository(next CachedCustomerStore, cache *rediscache.Cache, ttl, negativeTTL time.Duration, opts ...StoreOption) *CachingCustomerRepository {
+	return &CachingCustomerRepository{
+		next:        next,
+		cache:       cache,
+		codec:       newCustomerCodec(opts),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		keys:        make(map[string]string),
+		inflight:    make(map[string]*cachedRead),
+	}
+}
+
+// IndexedCachingCustomerRepository is a CachingCustomerRepository in front of
+// an IndexedCustomerStore. Lookups by phone and last name are passed through
+// uncached, so the read cache does not hide them.
+type IndexedCachingCustomerRepository struct {
+	*CachingCustomerRepository
+	indexed IndexedCustomerStore
+}
+
+// NewIndexedCachingCustomerRepository wraps next like
+// NewCachingCustomerRepository and keeps its secondary indexes reachable.
+func NewIndexedCachingCustomerRepository(next IndexedCustomerStore, cache *rediscache.Cache, ttl, negativeTTL time.Duration, opts ...StoreOption) *IndexedCachingCustomerRepository {
+	return &IndexedCachingCustomerRepository{
+		CachingCustomerRepository: NewCachingCustomerRepository(next, cache, ttl, negativeTTL, opts...),
+		indexed:                   next,
+	}
+}
+
+func (r *IndexedCachingCustomerRepository) FindByPhone(ctx context.Context, phone string) (*domain.Customer, error) {
+	return r.indexed.FindByPhone(ctx, phone)
+}
+
+func (r *IndexedCachingCustomerRepository) FindByLastName(ctx context.Context, lastName string) ([]*domain.Customer, error) {
+	return r.indexed.FindByLastName(ctx, lastName)
+}
+
+// Stats returns the hit and miss counters.
+func (r *CachingCustomerRepository) Stats() CacheStats {
+	return CacheStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+}
+
+func (r *CachingCustomerRepository) FindByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	tenantID := domain.TenantFromContext(ctx)
+	if err := domain.ValidateTenantID(tenantID); err != nil {
+		return nil, err
+	}
+	key := r.key(tenantID, email)
+
+	if customer, err, ok := r.cached(key); ok {
+		r.hits.Add(1)
+		return customer, err
+	}
+	r.misses.Add(1)
+
+	r.mu.Lock()
+	if read, ok := r.inflight[key]; ok {
+		r.mu.Unlock()
+		<-read.done
+		return cloneCustomer(read.customer), read.err
+	}
+	read := &cachedRead{done: make(chan struct{})}
+	r.inflight[key] = read
+	r.mu.Unlock()
+
+	read.customer, read.err = r.next.FindByEmail(ctx, email)
+
+	r.mu.Lock()
+	delete(r.inflight, key)
+	if !read.stale {
+		r.populate(key, read.customer, read.err)
+	}
+	r.mu.Unlock()
+	close(read.done)
+
+	return cloneCustomer(read.customer), read.err
+}
+
+func (r *CachingCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
+	defer r.invalidate(ctx, customer)
+	return r.next.Save(ctx, customer)
+}
+
+func (r *CachingCustomerRepository) Commit(ctx context.Context, customers []*domain.Customer, events []domain.Event) error {
+	defer r.invalidate(ctx, customers...)
+	return r.next.Commit(ctx, customers, events)
+}
+
+func (r *CachingCustomerRepository) PendingEvents(ctx context.Context, limit int) ([]domain.Event, error) {
+	return r.next.PendingEvents(ctx, limit)
+}
+
+func (r *CachingCustomerRepository) MarkDelivered(ctx context.Context, ids []string) error {
+	return r.next.MarkDelivered(ctx, ids)
+}
+
+func (r *CachingCustomerRepository) FindByStatus(ctx context.Context, status string) ([]*domain.Customer, error) {
+	return r.next.FindByStatus(ctx, status)
+}
+
+func (r *CachingCustomerRepository) Customers(ctx context.Context) ([]*domain.Customer, error) {
+	return r.next.Customers(ctx)
+}
+
+func (r *CachingCustomerRepository) Tenants(ctx context.Context) ([]string, error) {
+	return r.next.Tenants(ctx)
+}
+
+func (r *CachingCustomerRepository) Watch(ctx context.Context, fromSeq uint64) (<-chan domain.Change, error) {
+	return r.next.Watch(ctx, fromSeq)
+}
+
+// Reencrypt re-encrypts the wrapped repository. Cached records stay readable
+// as old keys are kept, and expire on their own.
+func (r *CachingCustomerRepository) Reencrypt(ctx context.Context) (int, error) {
+	next, ok := r.next.(interface {
+		Reencrypt(context.Context) (int, error)
+	})
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return next.Reencrypt(ctx)
+}
+
+// Migrate migrates the wrapped repository. Cached records are upgraded as
+// they are read.
+func (r *CachingCustomerRepository) Migrate(ctx context.Context) (int, error) {
+	next, ok := r.next.(interface {
+		Migrate(context.Context) (int, error)
+	})
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return next.Migrate(ctx)
+}
+
+// cached looks key up in the cache. ok is false on a miss.
+func (r *CachingCustomerRepository) cached(key string) (*domain.Customer, error, bool) {
+	// A key of another type cannot have been written by the cache and
+	// counts as a miss.
+	value, err := r.cache.Get(key)
+	data, found := value.(string)
+	if err != nil || !found {
+		return nil, nil, false
+	}
+	if data == cachedMissing {
+		return nil, domain.ErrCustomerNotFound, true
+	}
+
+	var record customerRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, nil, false
+	}
+	customer, err := r.codec.decode(&record)
+	if err != nil {
+		return nil, nil, false
+	}
+	return customer, nil, true
+}
+
+// populate caches the outcome of a repository read. The caller must hold
+// r.mu. A cache that is full only costs a later miss, so errors from it are
+// ignored.
+func (r *CachingCustomerRepository) populate(key string, customer *domain.Customer, err error) {
+	switch {
+	case errors.Is(err, domain.ErrCustomerNotFound):
+		if r.negativeTTL > 0 {
+			r.cache.Set(key, cachedMissing, r.negativeTTL)
+		}
+	case err == nil:
+		record, err := r.codec.encode(customer)
+		if err != nil {
+			return
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+		r.cache.Set(key, string(data), r.ttl)
+		r.keys[customer.TenantID+"\x00"+customer.ID] = key
+	}
+}
+
+// invalidate drops the cache entries of customers under their current and
+// previous email. It runs after the write, successful or not, and marks reads
+// in flight as stale.
+func (r *CachingCustomerRepository) invalidate(ctx context.Context, customers ...*domain.Customer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, customer := range customers {
+		tenantID := domain.TenantFromContext(ctx)
+		if customer.TenantID != "" {
+			tenantID = customer.TenantID
+		}
+		keys := []string{r.key(tenantID, customer.Email)}
+		if previous, ok := r.keys[tenantID+"\x00"+customer.ID]; ok {
+			keys = append(keys, previous)
+			delete(r.keys, tenantID+"\x00"+customer.ID)
+		}
+		for _, key := range keys {
+			if read, ok := r.inflight[key]; ok {
+				read.stale = true
+			}
+			r.cache.Del(key)
+		}
+	}
+}
+
+// ownsCacheKey reports whether key is one of the cached lookups or belongs
+// to the wrapped repository.
+func (r *CachingCustomerRepository) ownsCacheKey(cache *rediscache.Cache, key string) bool {
+	if cache == r.cache && strings.HasPrefix(key, constants.ReservedKeyPrefix+"customer-cache:") {
+		return true
+	}
+	next, ok := r.next.(cacheKeyOwner)
+	return ok && next.ownsCacheKey(cache, key)
+}
+
+func (r *CachingCustomerRepository) key(tenantID, email string) string {
+	return constants.ReservedKeyPrefix + "customer-cache:" + tenantID + ":" + r.codec.emailIndex(tenantID, email)
+}
+
+func cloneCustomer(customer *domain.Customer) *domain.Customer {
+	if customer == nil {
+		return nil
+	}
+	return customer.Clone()
+}
@@ -0,0 +1,148 @@
+package infra
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository counts and slows down reads by email.
+type countingRepository struct {
+	*CustomerRepository
+	reads atomic.Int32
+	delay time.Duration
+}
+
+func (r *countingRepository) FindByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	r.reads.Add(1)
+	time.Sleep(r.delay)
+	return r.CustomerRepository.FindByEmail(ctx, email)
+}
+
+func TestCachingCustomerRepository(t *testing.T) {
+	next := &countingRepository{CustomerRepository: NewCustomerRepository()}
+	cache := rediscache.NewRedisCache(4)
+	repository := NewCachingCustomerRepository(next, cache, time.Minute, time.Minute)
+	ctx := context.Background()
+
+	// Unknown emails are cached too, until a customer is saved with them.
+	_, err := repository.FindByEmail(ctx, "john@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	_, err = repository.FindByEmail(ctx, "john@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	assert.Equal(t, int32(1), next.reads.Load())
+
+	customer := &domain.Customer{ID: "c1", Email: "john@example.com", KYCStatus: "pending"}
+	require.NoError(t, repository.Save(ctx, customer))
+
+	found, err := repository.FindByEmail(ctx, "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", found.KYCStatus)
+	found.KYCStatus = "changed by caller"
+	found, err = repository.FindByEmail(ctx, "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", found.KYCStatus)
+	assert.Equal(t, int32(2), next.reads.Load())
+
+	customer.KYCStatus = "approved"
+	require.NoError(t, repository.Save(ctx, customer))
+	found, err = repository.FindByEmail(ctx, "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "approved", found.KYCStatus)
+	assert.Equal(t, 2, found.Version)
+
+	// A new email drops the entry under the old one.
+	customer.Email = "johnny@example.com"
+	require.NoError(t, repository.Save(ctx, customer))
+	_, err = repository.FindByEmail(ctx, "john@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 4}, repository.Stats())
+
+	// Cached lookups are left out of backups.
+	manifest, err := NewBackup(repository, cache).Write(ctx, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, 1, manifest.Customers)
+	assert.Zero(t, manifest.Keys)
+}
+
+func TestCachingCustomerRepositoryCoalescesMisses(t *testing.T) {
+	next := &countingRepository{CustomerRepository: NewCustomerRepository(), delay: 50 * time.Millisecond}
+	ctx := context.Background()
+	require.NoError(t, next.Save(ctx, &domain.Customer{ID: "c1", Email: "john@example.com"}))
+	repository := NewCachingCustomerRepository(next, rediscache.NewRedisCache(4), time.Minute, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			customer, err := repository.FindByEmail(ctx, "john@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, "c1", customer.ID)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), next.reads.Load())
+}
+
+func TestCachingCustomerRepositoryDropsStaleReads(t *testing.T) {
+	next := &countingRepository{CustomerRepository: NewCustomerRepository(), delay: 50 * time.Millisecond}
+	ctx := context.Background()
+	customer := &domain.Customer{ID: "c1", Email: "john@example.com", KYCStatus: "pending"}
+	require.NoError(t, next.Save(ctx, customer))
+	repository := NewCachingCustomerRepository(next, rediscache.NewRedisCache(4), time.Minute, time.Minute)
+
+	// The save lands while the first read is in flight, so its result must
+	// not be cached.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		repository.FindByEmail(ctx, "john@example.com")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	customer.KYCStatus = "approved"
+	require.NoError(t, repository.Save(ctx, customer))
+	<-done
+
+	found, err := repository.FindByEmail(ctx, "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "approved", found.KYCStatus)
+}
+
+func TestIndexedCachingCustomerRepositoryPassesLookupsThrough(t *testing.T) {
+	cache := rediscache.NewRedisCache(4)
+	repository := NewIndexedCachingCustomerRepository(NewCustomerRepository(), cache, time.Minute, time.Minute)
+	ctx := context.Background()
+
+	customer := &domain.Customer{ID: "c1", Email: "john@example.com", LastName: "Doe", Phone: "+1 555 0100"}
+	require.NoError(t, repository.Save(ctx, customer))
+
+	found, err := repository.FindByPhone(ctx, "+15550100")
+	require.NoError(t, err)
+	assert.Equal(t, "c1", found.ID)
+	byName, err := repository.FindByLastName(ctx, " doe ")
+	require.NoError(t, err)
+	require.Len(t, byName, 1)
+	assert.Equal(t, "c1", byName[0].ID)
+
+	// Backups still leave the cached lookups out.
+	_, err = repository.FindByEmail(ctx, "john@example.com")
+	require.NoError(t, err)
+	manifest, err := NewBackup(repository, cache).Write(ctx, io.Discard)
+	require.NoError(t, err)
+	assert.Zero(t, manifest.Keys)
+
+	var store CachedCustomerStore = NewCacheCustomerRepository(rediscache.NewRedisCache(2))
+	_, indexed := store.(IndexedCustomerStore)
+	assert.False(t, indexed)
+}
@@ -0,0 +1,176 @@
+package infra
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// changeFeedLimit is the number of changes kept for watchers to catch up
+// from.
+const changeFeedLimit = 10000
+
+// changeFeed numbers customer changes and hands them to watchers. Repositories
+// append under their write lock, so sequence order is commit order. Only the
+// most recent changes are retained; writers never wait for watchers.
+type changeFeed struct {
+	mu      sync.Mutex
+	changes []domain.Change
+	// last is the sequence number of the latest change, first the one of
+	// the oldest retained change.
+	last   uint64
+	first  uint64
+	limit  int
+	notify chan struct{}
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{first: 1, limit: changeFeedLimit, notify: make(chan struct{})}
+}
+
+// append records the change from before to after and returns its sequence
+// number. The customers must not be modified afterwards. When after erases
+// the customer, the personal data is dropped from before and from the
+// retained earlier changes of the customer too.
+func (f *changeFeed) append(before, after *domain.Customer) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if after.Erased() && before != nil && !before.Erased() {
+		before = erasedImage(before, after.ErasedAt)
+		f.scrub(after)
+	}
+
+	f.last++
+	f.changes = append(f.changes, domain.Change{
+		Seq:      f.last,
+		TenantID: after.TenantID,
+		Before:   before,
+		After:    after,
+		At:       time.Now(),
+	})
+	// Drop old changes in chunks so that appends stay cheap.
+	if len(f.changes) > f.limit+f.limit/4 {
+		f.changes = append([]domain.Change(nil), f.changes[len(f.changes)-f.limit:]...)
+	}
+	f.first = f.changes[0].Seq
+
+	close(f.notify)
+	f.notify = make(chan struct{})
+	return f.last
+}
+
+// scrub replaces the retained images of the erased customer with
+// pseudonymized copies. The images are replaced rather than changed, as
+// watchers may still hold them. The caller must hold f.mu.
+func (f *changeFeed) scrub(erased *domain.Customer) {
+	for i := range f.changes {
+		change := &f.changes[i]
+		if change.TenantID != erased.TenantID || change.After.ID != erased.ID {
+			continue
+		}
+		if change.Before != nil && !change.Before.Erased() {
+			change.Before = erasedImage(change.Before, erased.ErasedAt)
+		}
+		if !change.After.Erased() {
+			change.After = erasedImage(change.After, erased.ErasedAt)
+		}
+	}
+}
+
+// erasedImage returns a copy of customer without personal data.
+func erasedImage(customer *domain.Customer, at time.Time) *domain.Customer {
+	image := customer.Clone()
+	image.Erase(at)
+	return image
+}
+
+// lastSeq returns the sequence number of the latest change.
+func (f *changeFeed) lastSeq() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.last
+}
+
+// advance continues numbering after seq, which was restored from storage.
+// The changes up to seq are not retained.
+func (f *changeFeed) advance(seq uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if seq > f.last {
+		f.last = seq
+		f.changes = nil
+		f.first = seq + 1
+	}
+}
+
+// watch streams the changes of tenantID after fromSeq until ctx is done. The
+// channel is also closed when the watcher falls so far behind that changes it
+// has not received are dropped; watching again from the last received
+// sequence number then fails with domain.ErrChangesTruncated.
+func (f *changeFeed) watch(ctx context.Context, tenantID string, fromSeq uint64) (<-chan domain.Change, error) {
+	f.mu.Lock()
+	truncated := fromSeq+1 < f.first
+	f.mu.Unlock()
+	if truncated {
+		return nil, domain.ErrChangesTruncated
+	}
+
+	out := make(chan domain.Change)
+	go func() {
+		defer close(out)
+
+		cursor := fromSeq
+		for {
+			batch, notify, ok := f.since(cursor)
+			if !ok {
+				return
+			}
+			for _, change := range batch {
+				cursor = change.Seq
+				if change.TenantID != tenantID {
+					continue
+				}
+				if change.Before != nil {
+					change.Before = change.Before.Clone()
+				}
+				change.After = change.After.Clone()
+
+				select {
+				case out <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(batch) > 0 {
+				continue
+			}
+
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// since returns the retained changes after cursor and a channel closed on the
+// next append. ok is false if changes after cursor were dropped.
+func (f *changeFeed) since(cursor uint64) ([]domain.Change, <-chan struct{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cursor+1 < f.first {
+		return nil, nil, false
+	}
+	if cursor >= f.last {
+		return nil, f.notify, true
+	}
+	return append([]domain.Change(nil), f.changes[cursor+1-f.first:]...), f.notify, true
+}
@@ -0,0 +1,173 @@
+package infra
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type watchableRepository interface {
+	Save(context.Context, *domain.Customer) error
+	Watch(context.Context, uint64) (<-chan domain.Change, error)
+}
+
+func receive(t *testing.T, changes <-chan domain.Change) domain.Change {
+	t.Helper()
+	select {
+	case change, ok := <-changes:
+		require.True(t, ok, "change feed closed")
+		return change
+	case <-time.After(time.Second):
+		t.Fatal("no change received")
+		return domain.Change{}
+	}
+}
+
+func TestCustomerRepositoriesWatch(t *testing.T) {
+	file, err := OpenFileCustomerRepository(t.TempDir())
+	require.NoError(t, err)
+	defer file.Close()
+
+	repositories := map[string]watchableRepository{
+		"memory": NewCustomerRepository(),
+		"file":   file,
+		"cache":  NewCacheCustomerRepository(rediscache.NewRedisCache(4)),
+	}
+	for name, repository := range repositories {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			acme := domain.WithTenant(ctx, "acme")
+
+			customer := &domain.Customer{ID: "c1", Email: "john@example.com", KYCStatus: "pending"}
+			require.NoError(t, repository.Save(ctx, customer))
+			require.NoError(t, repository.Save(acme, &domain.Customer{ID: "a1", Email: "jane@example.com"}))
+
+			changes, err := repository.Watch(ctx, 0)
+			require.NoError(t, err)
+
+			created := receive(t, changes)
+			assert.Equal(t, uint64(1), created.Seq)
+			assert.Nil(t, created.Before)
+			assert.Equal(t, "john@example.com", created.After.Email)
+
+			customer.KYCStatus = "approved"
+			require.NoError(t, repository.Save(ctx, customer))
+
+			// The acme change is skipped, but counts towards the sequence.
+			updated := receive(t, changes)
+			assert.Equal(t, uint64(3), updated.Seq)
+			assert.Equal(t, "pending", updated.Before.KYCStatus)
+			assert.Equal(t, "approved", updated.After.KYCStatus)
+			assert.Equal(t, 2, updated.After.Version)
+
+			// Resuming after the last sequence number seen skips older changes.
+			resumed, err := repository.Watch(ctx, updated.Seq)
+			require.NoError(t, err)
+			customer.LastName = "Doe"
+			require.NoError(t, repository.Save(ctx, customer))
+			assert.Equal(t, uint64(4), receive(t, resumed).Seq)
+			assert.Equal(t, uint64(4), receive(t, changes).Seq)
+
+			acmeChanges, err := repository.Watch(acme, 0)
+			require.NoError(t, err)
+			assert.Equal(t, "a1", receive(t, acmeChanges).After.ID)
+
+			cancel()
+			_, open := <-changes
+			for open {
+				_, open = <-changes
+			}
+		})
+	}
+}
+
+func TestChangeFeedTruncates(t *testing.T) {
+	feed := newChangeFeed()
+	feed.limit = 4
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A watcher that is not reading falls behind and is closed.
+	behind, err := feed.watch(ctx, domain.DefaultTenant, 0)
+	require.NoError(t, err)
+
+	customer := &domain.Customer{TenantID: domain.DefaultTenant}
+	for i := 0; i < 10; i++ {
+		feed.append(nil, customer)
+	}
+
+	_, err = feed.watch(ctx, domain.DefaultTenant, 2)
+	assert.ErrorIs(t, err, domain.ErrChangesTruncated)
+	_, err = feed.watch(ctx, domain.DefaultTenant, 6)
+	assert.NoError(t, err)
+
+	// Draining ends when the watcher reaches the dropped changes.
+	for range behind {
+	}
+}
+
+func TestFileCustomerRepositoryKeepsSequenceAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repository.Save(ctx, &domain.Customer{ID: "c1", Email: "john@example.com"}))
+	require.NoError(t, repository.Save(ctx, &domain.Customer{ID: "c2", Email: "jane@example.com"}))
+	require.NoError(t, repository.Compact())
+	require.NoError(t, repository.Save(ctx, &domain.Customer{ID: "c3", Email: "joe@example.com"}))
+	// Close without compacting, so the last sequence number is in the WAL.
+	require.NoError(t, repository.wal.Close())
+
+	reopened, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	_, err = reopened.Watch(ctx, 1)
+	assert.ErrorIs(t, err, domain.ErrChangesTruncated)
+
+	changes, err := reopened.Watch(ctx, 3)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Save(ctx, &domain.Customer{ID: "c4", Email: "jim@example.com"}))
+	assert.Equal(t, uint64(4), receive(t, changes).Seq)
+}
+
+func TestChangeFeedScrubsErasedCustomers(t *testing.T) {
+	repository := NewCustomerRepository()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	customer := &domain.Customer{ID: "c1", Email: "john@example.com", FirstName: "John", KYCStatus: "pending"}
+	require.NoError(t, repository.Save(ctx, customer))
+	customer.KYCStatus = "approved"
+	require.NoError(t, repository.Save(ctx, customer))
+	other := &domain.Customer{ID: "c2", Email: "jane@example.com", FirstName: "Jane"}
+	require.NoError(t, repository.Save(ctx, other))
+
+	customer.Erase(time.Now())
+	require.NoError(t, repository.Save(ctx, customer))
+
+	changes, err := repository.Watch(ctx, 0)
+	require.NoError(t, err)
+	for seq := 1; seq <= 4; seq++ {
+		change := receive(t, changes)
+		if change.After.ID == "c2" {
+			assert.Equal(t, "Jane", change.After.FirstName)
+			continue
+		}
+		for _, image := range []*domain.Customer{change.Before, change.After} {
+			if image == nil {
+				continue
+			}
+			assert.True(t, image.Erased(), "change %d", change.Seq)
+			assert.Empty(t, image.FirstName)
+			assert.NotEqual(t, "john@example.com", image.Email)
+		}
+	}
+}
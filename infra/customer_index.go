@@ -0,0 +1,127 @@
+package infra
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// customerPartition holds the customers of one tenant keyed by email together
+// with their secondary indexes. It is guarded by the repository mutex.
+type customerPartition struct {
+	byEmail map[string]*domain.Customer
+	// byID maps customer IDs to emails, so a customer whose email changed
+	// replaces its old record.
+	byID map[string]string
+	// byPhone is unique: a normalized phone number belongs to one email.
+	byPhone    map[string]string
+	byStatus   map[string]map[string]bool
+	byLastName map[string]map[string]bool
+}
+
+func newCustomerPartition() *customerPartition {
+	return &customerPartition{
+		byEmail:    make(map[string]*domain.Customer),
+		byID:       make(map[string]string),
+		byPhone:    make(map[string]string),
+		byStatus:   make(map[string]map[string]bool),
+		byLastName: make(map[string]map[string]bool),
+	}
+}
+
+// stored returns the record customer replaces: the one with its email or,
+// after an email change, the one with its ID.
+func (p *customerPartition) stored(customer *domain.Customer) (*domain.Customer, bool) {
+	if stored, ok := p.byEmail[customer.Email]; ok {
+		return stored, true
+	}
+	if email, ok := p.byID[customer.ID]; ok && customer.ID != "" {
+		return p.byEmail[email], true
+	}
+	return nil, false
+}
+
+// put stores customer and moves its index entries from the previous record.
+func (p *customerPartition) put(customer *domain.Customer) {
+	if previous, ok := p.stored(customer); ok {
+		p.unindex(previous)
+	}
+
+	p.byEmail[customer.Email] = customer
+	if customer.ID != "" {
+		p.byID[customer.ID] = customer.Email
+	}
+	if phone := normalizePhone(customer.Phone); phone != "" {
+		p.byPhone[phone] = customer.Email
+	}
+	addToIndex(p.byStatus, customer.KYCStatus, customer.Email)
+	addToIndex(p.byLastName, normalizeName(customer.LastName), customer.Email)
+}
+
+func (p *customerPartition) unindex(customer *domain.Customer) {
+	delete(p.byEmail, customer.Email)
+	if p.byID[customer.ID] == customer.Email {
+		delete(p.byID, customer.ID)
+	}
+	if phone := normalizePhone(customer.Phone); p.byPhone[phone] == customer.Email {
+		delete(p.byPhone, phone)
+	}
+	removeFromIndex(p.byStatus, customer.KYCStatus, customer.Email)
+	removeFromIndex(p.byLastName, normalizeName(customer.LastName), customer.Email)
+}
+
+// phoneOwner returns the email of the customer using phone.
+func (p *customerPartition) phoneOwner(phone string) (string, bool) {
+	email, ok := p.byPhone[normalizePhone(phone)]
+	return email, ok
+}
+
+// lookup returns copies of the customers listed under key, ordered by email.
+func (p *customerPartition) lookup(index map[string]map[string]bool, key string) []*domain.Customer {
+	emails := make([]string, 0, len(index[key]))
+	for email := range index[key] {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	customers := make([]*domain.Customer, 0, len(emails))
+	for _, email := range emails {
+		customers = append(customers, p.byEmail[email].Clone())
+	}
+	return customers
+}
+
+func addToIndex(index map[string]map[string]bool, key, email string) {
+	if key == "" {
+		return
+	}
+	if index[key] == nil {
+		index[key] = make(map[string]bool)
+	}
+	index[key][email] = true
+}
+
+func removeFromIndex(index map[string]map[string]bool, key, email string) {
+	delete(index[key], email)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+// normalizePhone keeps the digits of a phone number and a leading plus, so
+// "+63 917-123 4567" and "+639171234567" are the same number.
+func normalizePhone(phone string) string {
+	var normalized strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// normalizeName lower-cases a name and collapses its whitespace.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
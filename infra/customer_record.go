@@ -0,0 +1,166 @@
+package infra
+
+import (
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// customerRecord is the form in which persistent stores keep a customer. Its
+// field names match the JSON of domain.Customer, so records written before
+// encryption was added still decode. When KeyID is set, the PII fields hold
+// AES-GCM ciphertext sealed with that key and EmailIndex holds the blind index
+// of the email. Older records are upgraded as they are read, see
+// customerMigrations.
+type customerRecord struct {
+	SchemaVersion int
+	ID            string
+	TenantID      string
+	FirstName     string
+	LastName      string
+	Email         string
+	Phone         string
+	Address       domain.Address
+	KYCStatus     string
+	Documents     []domain.Document
+	Version       int
+	CreatedAt     time.Time
+	ErasedAt      time.Time
+	KeyID         string `json:",omitempty"`
+	EmailIndex    string `json:",omitempty"`
+
+	// storedVersion is the schema version the record was read with.
+	storedVersion int
+}
+
+// customerCodec converts customers to and from records, encrypting PII when
+// a keyring is configured.
+type customerCodec struct {
+	keyring *Keyring
+}
+
+func (c customerCodec) encode(customer *domain.Customer) (*customerRecord, error) {
+	record := &customerRecord{
+		SchemaVersion: currentSchemaVersion,
+		ID:            customer.ID,
+		TenantID:      customer.TenantID,
+		FirstName:     customer.FirstName,
+		LastName:      customer.LastName,
+		Email:         customer.Email,
+		Phone:         customer.Phone,
+		Address:       customer.Address,
+		KYCStatus:     customer.KYCStatus,
+		Documents:     append([]domain.Document(nil), customer.Documents...),
+		Version:       customer.Version,
+		CreatedAt:     customer.CreatedAt,
+		ErasedAt:      customer.ErasedAt,
+	}
+	if c.keyring == nil {
+		return record, nil
+	}
+
+	record.KeyID = c.keyring.ActiveKeyID()
+	record.EmailIndex = c.emailIndex(customer.TenantID, customer.Email)
+	err := c.transform(record, func(field, value string) (string, error) {
+		return c.keyring.Encrypt(record.KeyID, value, record.additionalData(field))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (c customerCodec) decode(record *customerRecord) (*domain.Customer, error) {
+	plain := *record
+	plain.Documents = append([]domain.Document(nil), record.Documents...)
+	if record.KeyID != "" {
+		if c.keyring == nil {
+			return nil, ErrUnknownKey
+		}
+		err := c.transform(&plain, func(field, value string) (string, error) {
+			return c.keyring.Decrypt(record.KeyID, value, record.additionalData(field))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &domain.Customer{
+		ID:        plain.ID,
+		TenantID:  plain.TenantID,
+		FirstName: plain.FirstName,
+		LastName:  plain.LastName,
+		Email:     plain.Email,
+		Phone:     plain.Phone,
+		Address:   plain.Address,
+		KYCStatus: plain.KYCStatus,
+		Documents: plain.Documents,
+		Version:   plain.Version,
+		CreatedAt: plain.CreatedAt,
+		ErasedAt:  plain.ErasedAt,
+	}, nil
+}
+
+// emailIndex returns the value stores use to look customers up by email: the
+// blind index when encrypting, the email itself otherwise.
+func (c customerCodec) emailIndex(tenantID, email string) string {
+	if c.keyring == nil {
+		return email
+	}
+	return c.keyring.BlindIndex(tenantID + "\x00" + email)
+}
+
+// transform applies fn to every non-empty PII field of record.
+func (c customerCodec) transform(record *customerRecord, fn func(field, value string) (string, error)) error {
+	fields := map[string]*string{
+		"first_name": &record.FirstName,
+		"last_name":  &record.LastName,
+		"email":      &record.Email,
+		"phone":      &record.Phone,
+		// Street keeps the field name addresses were sealed with before
+		// they were structured.
+		"address":             &record.Address.Street,
+		"address_city":        &record.Address.City,
+		"address_postal_code": &record.Address.PostalCode,
+		"address_country":     &record.Address.Country,
+	}
+	for i := range record.Documents {
+		fields["document_number_"+string(record.Documents[i].Type)] = &record.Documents[i].Number
+	}
+
+	for field, value := range fields {
+		if *value == "" {
+			continue
+		}
+		transformed, err := fn(field, *value)
+		if err != nil {
+			return err
+		}
+		*value = transformed
+	}
+	return nil
+}
+
+// additionalData binds a ciphertext to the tenant, customer and field it
+// belongs to.
+func (r *customerRecord) additionalData(field string) string {
+	return r.TenantID + "\x00" + r.ID + "\x00" + field
+}
+
+// StoreOption configures the persistent customer repositories.
+type StoreOption func(*customerCodec)
+
+// WithKeyring encrypts customer PII at rest with keys from keyring.
+func WithKeyring(keyring *Keyring) StoreOption {
+	return func(c *customerCodec) {
+		c.keyring = keyring
+	}
+}
+
+func newCustomerCodec(opts []StoreOption) customerCodec {
+	var codec customerCodec
+	for _, opt := range opts {
+		opt(&codec)
+	}
+	return codec
+}
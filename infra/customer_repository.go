@@ -2,45 +2,322 @@ package infra
 
 import (
 	"context"
-	"errors"
+	"sort"
 	"sync"
 
 	"github.com/macadrich/go-task-challenge/domain"
 )
 
-// CustomerRepository to simulate database, in-memory customer repository
+// CustomerRepository to simulate database, in-memory customer repository.
+// Customers are partitioned by the tenant of the context and copied on the
+// way in and out, so callers never share state with the stored records.
+// Secondary indexes on phone, KYC status and last name are updated under the
+// same lock as the customers.
 type CustomerRepository struct {
-	mu        *sync.Mutex
-	customers map[string]*domain.Customer
+	mu      *sync.Mutex
+	tenants map[string]*customerPartition
+	// outbox holds committed events that were not delivered yet, in commit
+	// order.
+	outbox []domain.Event
+	feed   *changeFeed
 }
 
 func NewCustomerRepository() *CustomerRepository {
 	return &CustomerRepository{
-		mu:        &sync.Mutex{},
-		customers: make(map[string]*domain.Customer),
+		mu:      &sync.Mutex{},
+		tenants: make(map[string]*customerPartition),
+		feed:    newChangeFeed(),
 	}
 }
 
-func (r *CustomerRepository) GetCustomers() map[string]*domain.Customer {
-	return r.customers
+// GetCustomers returns copies of the customers of the context's tenant keyed
+// by email.
+func (r *CustomerRepository) GetCustomers(ctx context.Context) map[string]*domain.Customer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customers := make(map[string]*domain.Customer)
+	if partition, ok := r.tenants[domain.TenantFromContext(ctx)]; ok {
+		for email, customer := range partition.byEmail {
+			customers[email] = customer.Clone()
+		}
+	}
+	return customers
 }
 
+// Save stores a copy of customer in the context's tenant. The customer's
+// Version must match the stored one (zero for a new customer), otherwise
+// domain.ErrVersionConflict is returned. On success customer.Version is set to
+// the new version.
 func (r *CustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
+	return r.commit(ctx, []*domain.Customer{customer}, nil, nil)
+}
+
+// Commit saves customers and appends events to the outbox atomically: either
+// every change is applied or none is.
+func (r *CustomerRepository) Commit(ctx context.Context, customers []*domain.Customer, events []domain.Event) error {
+	return r.commit(ctx, customers, events, nil)
+}
+
+// PendingEvents returns up to limit undelivered events in commit order.
+func (r *CustomerRepository) PendingEvents(ctx context.Context, limit int) ([]domain.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if limit > len(r.outbox) {
+		limit = len(r.outbox)
+	}
+	return append([]domain.Event(nil), r.outbox[:limit]...), nil
+}
+
+// MarkDelivered removes the events with the given IDs from the outbox.
+func (r *CustomerRepository) MarkDelivered(ctx context.Context, ids []string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.customers[customer.Email] = customer
+	r.removeEvents(ids)
 	return nil
 }
 
+// Watch streams the changes to customers of the context's tenant with a
+// sequence number above fromSeq, starting with the retained ones, until ctx
+// is cancelled. Pass the last sequence number received to resume.
+func (r *CustomerRepository) Watch(ctx context.Context, fromSeq uint64) (<-chan domain.Change, error) {
+	return r.feed.watch(ctx, domain.TenantFromContext(ctx), fromSeq)
+}
+
+// commit validates customers and hands the records about to be stored,
+// together with events, to persist before applying them. A persist error
+// leaves the repository unchanged.
+func (r *CustomerRepository) commit(ctx context.Context, customers []*domain.Customer, events []domain.Event, persist func([]*domain.Customer, []domain.Event) error) error {
+	for _, customer := range customers {
+		if err := domain.CheckTenant(ctx, customer); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// versions and phones track customers saved more than once and phone
+	// numbers claimed earlier in the same commit.
+	versions := make(map[string]int)
+	phones := make(map[string]string)
+	records := make([]*domain.Customer, 0, len(customers))
+	for _, customer := range customers {
+		partition := r.partition(customer.TenantID)
+		key := customer.TenantID + "\x00" + customer.Email
+		current, seen := versions[key]
+		stored, exists := partition.stored(customer)
+		if exists && !seen {
+			current = stored.Version
+		}
+		if customer.Version != current {
+			return domain.ErrVersionConflict
+		}
+
+		if phone := normalizePhone(customer.Phone); phone != "" {
+			owner, taken := partition.phoneOwner(phone)
+			if taken && exists && owner == stored.Email {
+				taken = false
+			}
+			if claimed, ok := phones[customer.TenantID+"\x00"+phone]; ok {
+				owner, taken = claimed, true
+			}
+			if taken && owner != customer.Email {
+				return domain.ErrDuplicatePhone
+			}
+			phones[customer.TenantID+"\x00"+phone] = customer.Email
+		}
+
+		record := customer.Clone()
+		record.Version = current + 1
+		versions[key] = record.Version
+		records = append(records, record)
+	}
+
+	if persist != nil {
+		if err := persist(records, events); err != nil {
+			return err
+		}
+	}
+
+	for i, record := range records {
+		before, _ := r.partition(record.TenantID).stored(record)
+		r.put(record)
+		r.feed.append(before, record)
+		customers[i].Version = record.Version
+	}
+	r.outbox = append(r.outbox, events...)
+	return nil
+}
+
+// partition returns the partition of the given tenant, creating it on first
+// use. The caller must hold r.mu.
+func (r *CustomerRepository) partition(tenantID string) *customerPartition {
+	partition, ok := r.tenants[tenantID]
+	if !ok {
+		partition = newCustomerPartition()
+		r.tenants[tenantID] = partition
+	}
+	return partition
+}
+
+// put stores customer as is and updates the indexes. The caller must hold
+// r.mu.
+func (r *CustomerRepository) put(customer *domain.Customer) {
+	r.partition(customer.TenantID).put(customer)
+}
+
+// removeEvents drops delivered events from the outbox. The caller must hold
+// r.mu.
+func (r *CustomerRepository) removeEvents(ids []string) {
+	delivered := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		delivered[id] = true
+	}
+
+	pending := r.outbox[:0]
+	for _, event := range r.outbox {
+		if !delivered[event.ID] {
+			pending = append(pending, event)
+		}
+	}
+	r.outbox = pending
+}
+
+// restore stores copies of customers and appends events to the outbox without
+// any checks. It is used to load state from persistent storage, where the
+// same commit may be replayed twice, so events already pending are skipped.
+func (r *CustomerRepository) restore(customers []*domain.Customer, events []domain.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, customer := range customers {
+		if customer != nil {
+			r.put(customer.Clone())
+		}
+	}
+
+	pending := make(map[string]bool, len(r.outbox))
+	for _, event := range r.outbox {
+		pending[event.ID] = true
+	}
+	for _, event := range events {
+		if !pending[event.ID] {
+			r.outbox = append(r.outbox, event)
+		}
+	}
+}
+
+// Customers returns copies of the customers of the context's tenant ordered
+// by email.
+func (r *CustomerRepository) Customers(ctx context.Context) ([]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	partition, ok := r.tenants[domain.TenantFromContext(ctx)]
+	if !ok {
+		return nil, nil
+	}
+	emails := make([]string, 0, len(partition.byEmail))
+	for email := range partition.byEmail {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	customers := make([]*domain.Customer, 0, len(emails))
+	for _, email := range emails {
+		customers = append(customers, partition.byEmail[email].Clone())
+	}
+	return customers, nil
+}
+
+// Tenants returns the IDs of the tenants that have customers, in order.
+func (r *CustomerRepository) Tenants(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenants := make([]string, 0, len(r.tenants))
+	for tenantID, partition := range r.tenants {
+		if len(partition.byEmail) > 0 {
+			tenants = append(tenants, tenantID)
+		}
+	}
+	sort.Strings(tenants)
+	return tenants, nil
+}
+
+// all returns copies of the customers of every tenant and the pending events.
+func (r *CustomerRepository) all() ([]*domain.Customer, []domain.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var customers []*domain.Customer
+	for _, partition := range r.tenants {
+		for _, customer := range partition.byEmail {
+			customers = append(customers, customer.Clone())
+		}
+	}
+	return customers, append([]domain.Event(nil), r.outbox...)
+}
+
 func (r *CustomerRepository) FindByEmail(ctx context.Context, email string) (*domain.Customer, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	customer, exists := r.customers[email]
+	partition, ok := r.tenants[domain.TenantFromContext(ctx)]
+	if !ok {
+		return nil, domain.ErrCustomerNotFound
+	}
+	customer, exists := partition.byEmail[email]
+	if !exists {
+		return nil, domain.ErrCustomerNotFound
+	}
+
+	return customer.Clone(), nil
+}
+
+// FindByPhone returns the customer of the context's tenant using phone. Phone
+// numbers are compared by their digits.
+func (r *CustomerRepository) FindByPhone(ctx context.Context, phone string) (*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	partition, ok := r.tenants[domain.TenantFromContext(ctx)]
+	if !ok {
+		return nil, domain.ErrCustomerNotFound
+	}
+	email, exists := partition.phoneOwner(phone)
 	if !exists {
-		return nil, errors.New("customer not found")
+		return nil, domain.ErrCustomerNotFound
 	}
 
-	return customer, nil
+	return partition.byEmail[email].Clone(), nil
+}
+
+// FindByStatus returns the customers of the context's tenant with the given
+// KYC status, ordered by email.
+func (r *CustomerRepository) FindByStatus(ctx context.Context, status string) ([]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	partition, ok := r.tenants[domain.TenantFromContext(ctx)]
+	if !ok {
+		return nil, nil
+	}
+	return partition.lookup(partition.byStatus, status), nil
+}
+
+// FindByLastName returns the customers of the context's tenant with the given
+// last name, ignoring case and extra whitespace, ordered by email.
+func (r *CustomerRepository) FindByLastName(ctx context.Context, lastName string) ([]*domain.Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	partition, ok := r.tenants[domain.TenantFromContext(ctx)]
+	if !ok {
+		return nil, nil
+	}
+	return partition.lookup(partition.byLastName, normalizeName(lastName)), nil
 }
@@ -0,0 +1,229 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomerRepositoryCopiesCustomers(t *testing.T) {
+	repository := NewCustomerRepository()
+	ctx := context.Background()
+
+	customer := &domain.Customer{Email: "john.doe@example.com", KYCStatus: "pending"}
+	require.NoError(t, repository.Save(ctx, customer))
+	assert.Equal(t, 1, customer.Version)
+
+	// Mutating the saved or the loaded customer must not touch the store.
+	customer.KYCStatus = "approved"
+	found, err := repository.FindByEmail(ctx, customer.Email)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", found.KYCStatus)
+
+	found.KYCStatus = "rejected"
+	found, err = repository.FindByEmail(ctx, customer.Email)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", found.KYCStatus)
+
+	_, err = repository.FindByEmail(ctx, "missing@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+}
+
+func TestCustomerRepositoryRejectsStaleWrites(t *testing.T) {
+	repository := NewCustomerRepository()
+	ctx := context.Background()
+
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "john.doe@example.com"}))
+
+	first, _ := repository.FindByEmail(ctx, "john.doe@example.com")
+	second, _ := repository.FindByEmail(ctx, "john.doe@example.com")
+
+	first.KYCStatus = "approved"
+	require.NoError(t, repository.Save(ctx, first))
+	assert.Equal(t, 2, first.Version)
+
+	second.KYCStatus = "rejected"
+	assert.ErrorIs(t, repository.Save(ctx, second), domain.ErrVersionConflict)
+
+	// Registering the same email again is a conflict as well.
+	assert.ErrorIs(t, repository.Save(ctx, &domain.Customer{Email: "john.doe@example.com"}), domain.ErrVersionConflict)
+
+	stored, _ := repository.FindByEmail(ctx, "john.doe@example.com")
+	assert.Equal(t, "approved", stored.KYCStatus)
+}
+
+func TestCustomerRepositoryConcurrentSaves(t *testing.T) {
+	repository := NewCustomerRepository()
+	ctx := context.Background()
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "john.doe@example.com"}))
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			customer, err := repository.FindByEmail(ctx, "john.doe@example.com")
+			require.NoError(t, err)
+			customer.KYCStatus = "approved"
+			if repository.Save(ctx, customer) == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, _ := repository.FindByEmail(ctx, "john.doe@example.com")
+	assert.Equal(t, 1+succeeded, stored.Version)
+}
+
+func TestCustomerRepositoryPartitionsTenants(t *testing.T) {
+	repository := NewCustomerRepository()
+	acme := domain.WithTenant(context.Background(), "acme")
+	globex := domain.WithTenant(context.Background(), "globex")
+
+	require.NoError(t, repository.Save(acme, &domain.Customer{Email: "john.doe@example.com", FirstName: "Acme"}))
+	require.NoError(t, repository.Save(globex, &domain.Customer{Email: "john.doe@example.com", FirstName: "Globex"}))
+
+	found, err := repository.FindByEmail(acme, "john.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", found.FirstName)
+	assert.Equal(t, "acme", found.TenantID)
+
+	_, err = repository.FindByEmail(context.Background(), "john.doe@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+
+	// Writing a customer loaded from one tenant through another fails.
+	assert.ErrorIs(t, repository.Save(globex, found), domain.ErrTenantMismatch)
+	assert.Len(t, repository.GetCustomers(globex), 1)
+}
+
+func TestCustomerRepositorySecondaryIndexes(t *testing.T) {
+	repository := NewCustomerRepository()
+	ctx := context.Background()
+
+	john := &domain.Customer{Email: "john@example.com", LastName: "Dela Cruz", Phone: "+63 917 123 4567", KYCStatus: "pending"}
+	jane := &domain.Customer{Email: "jane@example.com", LastName: "dela  cruz", Phone: "+639170000000", KYCStatus: "pending"}
+	require.NoError(t, repository.Save(ctx, john))
+	require.NoError(t, repository.Save(ctx, jane))
+
+	found, err := repository.FindByPhone(ctx, "+639171234567")
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", found.Email)
+
+	byName, err := repository.FindByLastName(ctx, "DELA CRUZ")
+	require.NoError(t, err)
+	assert.Len(t, byName, 2)
+
+	// The phone index is unique, and a rejected save changes nothing.
+	duplicate := &domain.Customer{Email: "other@example.com", Phone: "+63-917-123-4567"}
+	assert.ErrorIs(t, repository.Save(ctx, duplicate), domain.ErrDuplicatePhone)
+	_, err = repository.FindByEmail(ctx, "other@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+
+	// Updating a customer moves its index entries.
+	john.KYCStatus = "approved"
+	john.Phone = "+639179999999"
+	require.NoError(t, repository.Save(ctx, john))
+
+	pending, err := repository.FindByStatus(ctx, "pending")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "jane@example.com", pending[0].Email)
+
+	_, err = repository.FindByPhone(ctx, "+639171234567")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	require.NoError(t, repository.Save(ctx, duplicate))
+
+	// Indexes are per tenant.
+	other := domain.WithTenant(ctx, "acme")
+	approved, err := repository.FindByStatus(other, "approved")
+	require.NoError(t, err)
+	assert.Empty(t, approved)
+}
+
+func TestCustomerRepositoryIndexesUnderConcurrentWrites(t *testing.T) {
+	repository := NewCustomerRepository()
+	ctx := context.Background()
+
+	statuses := []string{"pending", "approved", "rejected"}
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				email := fmt.Sprintf("customer%d@example.com", i%10)
+				customer, err := repository.FindByEmail(ctx, email)
+				if err != nil {
+					customer = &domain.Customer{Email: email}
+				}
+				customer.KYCStatus = statuses[(worker+i)%len(statuses)]
+				customer.LastName = fmt.Sprintf("Name%d", (worker+i)%4)
+				customer.Phone = fmt.Sprintf("555%04d", (worker*7+i)%15)
+				// Conflicts on version or phone are expected; the indexes
+				// must stay consistent either way.
+				repository.Save(ctx, customer)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+	partition := repository.tenants[domain.DefaultTenant]
+
+	phones, statusEntries, nameEntries := 0, 0, 0
+	for email, customer := range partition.byEmail {
+		assert.True(t, partition.byStatus[customer.KYCStatus][email])
+		assert.True(t, partition.byLastName[normalizeName(customer.LastName)][email])
+		if owner, ok := partition.byPhone[normalizePhone(customer.Phone)]; ok && owner == email {
+			phones++
+		}
+	}
+	for _, emails := range partition.byStatus {
+		statusEntries += len(emails)
+	}
+	for _, emails := range partition.byLastName {
+		nameEntries += len(emails)
+	}
+	assert.Equal(t, len(partition.byEmail), phones)
+	assert.Equal(t, len(partition.byEmail), len(partition.byPhone))
+	assert.Equal(t, len(partition.byEmail), statusEntries)
+	assert.Equal(t, len(partition.byEmail), nameEntries)
+}
+
+func TestCustomerRepositoryChangesEmail(t *testing.T) {
+	repository := NewCustomerRepository()
+	ctx := context.Background()
+
+	customer := &domain.Customer{ID: "c1", Email: "old@example.com", Phone: "1234567890", KYCStatus: "pending"}
+	require.NoError(t, repository.Save(ctx, customer))
+
+	customer.Email = "new@example.com"
+	require.NoError(t, repository.Save(ctx, customer))
+	assert.Equal(t, 2, customer.Version)
+
+	_, err := repository.FindByEmail(ctx, "old@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+	found, err := repository.FindByPhone(ctx, "1234567890")
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", found.Email)
+	pending, err := repository.FindByStatus(ctx, "pending")
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	// A stale copy under the old email is rejected.
+	stale := &domain.Customer{ID: "c1", Email: "old@example.com", Version: 1}
+	assert.ErrorIs(t, repository.Save(ctx, stale), domain.ErrVersionConflict)
+}
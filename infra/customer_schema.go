@@ -0,0 +1,112 @@
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// currentSchemaVersion is the version of the customer records written by
+// this code. Records carry it in SchemaVersion.
+const currentSchemaVersion = 3
+
+var ErrUnknownSchemaVersion = errors.New("customer record has a newer schema version")
+
+// customerMigration upgrades a record, decoded into a generic map, from one
+// schema version to the next.
+type customerMigration func(record map[string]interface{}) error
+
+// customerMigrations holds the migration from every historical version to
+// the next one, keyed by the version it upgrades from.
+//
+//	1  records written before schema versions were introduced; they have no
+//	   SchemaVersion field
+//	2  adds SchemaVersion
+//	3  turns Address from a single line into a structured address
+var customerMigrations = map[int]customerMigration{
+	// Version 1 records already have every field of version 2, with zero
+	// values for fields added since; only the version is new.
+	1: func(record map[string]interface{}) error { return nil },
+	2: migrateStructuredAddress,
+}
+
+// migrateStructuredAddress moves the single line address of a record into
+// the street of a structured address. The line may be encrypted, so it is
+// moved as is rather than parsed.
+func migrateStructuredAddress(record map[string]interface{}) error {
+	address := record["Address"]
+	if address == nil {
+		address = ""
+	}
+	line, ok := address.(string)
+	if !ok {
+		return fmt.Errorf("address is %T, not a string", address)
+	}
+	record["Address"] = map[string]interface{}{"Street": line}
+	return nil
+}
+
+// UnmarshalJSON reads a record of any known schema version and upgrades it
+// to the current one. The version it was stored with is kept in
+// storedVersion.
+func (r *customerRecord) UnmarshalJSON(data []byte) error {
+	type plain customerRecord
+
+	// Records at the current version are decoded directly; older ones may
+	// not fit the struct until they are migrated.
+	err := json.Unmarshal(data, (*plain)(r))
+	if err == nil && r.SchemaVersion == currentSchemaVersion {
+		r.storedVersion = currentSchemaVersion
+		return nil
+	}
+
+	var probe struct{ SchemaVersion int }
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	version := probe.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	if version > currentSchemaVersion {
+		return fmt.Errorf("%w: %d", ErrUnknownSchemaVersion, version)
+	}
+	if version == currentSchemaVersion {
+		return err
+	}
+
+	upgraded, err := migrateCustomerRecord(data, version, currentSchemaVersion, customerMigrations)
+	if err != nil {
+		return fmt.Errorf("failed to migrate customer record from schema version %d: %w", version, err)
+	}
+	*r = customerRecord{}
+	if err := json.Unmarshal(upgraded, (*plain)(r)); err != nil {
+		return err
+	}
+	r.storedVersion = version
+	return nil
+}
+
+// migrateCustomerRecord applies migrations to the record in data from
+// version from up to version to and returns the upgraded record.
+func migrateCustomerRecord(data []byte, from, to int, migrations map[int]customerMigration) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+
+	for version := from; version < to; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from schema version %d", version)
+		}
+		if err := migrate(record); err != nil {
+			return nil, fmt.Errorf("schema version %d: %w", version, err)
+		}
+	}
+	record["SchemaVersion"] = to
+	return json.Marshal(record)
+}
@@ -0,0 +1,172 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historicalRecords holds records as they were stored at every schema
+// version before the current one. A new schema version must add samples of
+// the version it replaces.
+var historicalRecords = map[int][]string{
+	1: {
+		// File store records from before encryption.
+		`{"ID":"c1","TenantID":"default","FirstName":"John","LastName":"Doe","Email":"john@example.com","Phone":"123","Address":"1 Main St","KYCStatus":"approved","Documents":null,"Version":3}`,
+		// Records with encrypted fields.
+		`{"ID":"c1","TenantID":"default","FirstName":"John","LastName":"Doe","Email":"john@example.com","Phone":"123","Address":"1 Main St","KYCStatus":"approved","Documents":[{"Type":"passport","Number":"P1","IssuingCountry":"PH","Expiry":"2030-01-01T00:00:00Z","BlobID":"ab","MIMEType":"image/png","Size":4,"AttachedAt":"2026-01-01T00:00:00Z"}],"Version":3,"KeyID":"k1","EmailIndex":"ff"}`,
+		// Records with creation and erasure times.
+		`{"ID":"c1","TenantID":"default","FirstName":"John","LastName":"Doe","Email":"john@example.com","Phone":"123","Address":"1 Main St","KYCStatus":"approved","Documents":null,"Version":3,"CreatedAt":"2026-01-01T00:00:00Z","ErasedAt":"0001-01-01T00:00:00Z"}`,
+	},
+	2: {
+		// Records with a single line address.
+		`{"SchemaVersion":2,"ID":"c1","TenantID":"default","FirstName":"John","LastName":"Doe","Email":"john@example.com","Phone":"123","Address":"1 Main St","KYCStatus":"approved","Documents":null,"Version":3,"CreatedAt":"2026-01-01T00:00:00Z","ErasedAt":"0001-01-01T00:00:00Z"}`,
+		// Records with encrypted fields.
+		`{"SchemaVersion":2,"ID":"c1","TenantID":"default","FirstName":"John","LastName":"Doe","Email":"john@example.com","Phone":"123","Address":"1 Main St","KYCStatus":"approved","Documents":null,"Version":3,"CreatedAt":"2026-01-01T00:00:00Z","ErasedAt":"0001-01-01T00:00:00Z","KeyID":"k1","EmailIndex":"ff"}`,
+	},
+}
+
+func TestCustomerRecordMigratesFromEveryVersion(t *testing.T) {
+	for version := 1; version < currentSchemaVersion; version++ {
+		require.NotEmpty(t, historicalRecords[version], "no sample records for schema version %d", version)
+		require.Contains(t, customerMigrations, version, "no migration from schema version %d", version)
+
+		for _, data := range historicalRecords[version] {
+			var record customerRecord
+			require.NoError(t, json.Unmarshal([]byte(data), &record), data)
+
+			assert.Equal(t, version, record.storedVersion)
+			assert.Equal(t, currentSchemaVersion, record.SchemaVersion)
+			assert.Equal(t, "c1", record.ID)
+			assert.Equal(t, "john@example.com", record.Email)
+			assert.Equal(t, domain.Address{Street: "1 Main St"}, record.Address)
+			assert.Equal(t, "approved", record.KYCStatus)
+			assert.Equal(t, 3, record.Version)
+		}
+	}
+}
+
+func TestCustomerRecordRejectsNewerVersions(t *testing.T) {
+	var record customerRecord
+	err := json.Unmarshal([]byte(`{"SchemaVersion":99,"ID":"c1"}`), &record)
+	assert.ErrorIs(t, err, ErrUnknownSchemaVersion)
+}
+
+func TestMigrateCustomerRecordAppliesMigrationsInOrder(t *testing.T) {
+	migrations := map[int]customerMigration{
+		1: func(record map[string]interface{}) error {
+			record["Street"] = record["Address"]
+			delete(record, "Address")
+			return nil
+		},
+		2: func(record map[string]interface{}) error {
+			record["Address"] = map[string]interface{}{"Street": record["Street"]}
+			delete(record, "Street")
+			return nil
+		},
+	}
+
+	upgraded, err := migrateCustomerRecord([]byte(`{"ID":"c1","Address":"1 Main St","Version":3}`), 1, 3, migrations)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ID":"c1","Address":{"Street":"1 Main St"},"Version":3,"SchemaVersion":3}`, string(upgraded))
+
+	_, err = migrateCustomerRecord([]byte(`{"ID":"c1"}`), 1, 4, migrations)
+	assert.Error(t, err)
+}
+
+func TestStructuredAddressMigrationKeepsEncryptedAddresses(t *testing.T) {
+	keyring, err := LoadKeyring(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	codec := customerCodec{keyring: keyring}
+
+	// A version 2 record sealed the whole address line as "address".
+	customer := encryptedCustomer()
+	record, err := codec.encode(customer)
+	require.NoError(t, err)
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	var stored map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &stored))
+	stored["SchemaVersion"] = 2
+	stored["Address"] = stored["Address"].(map[string]interface{})["Street"]
+	data, err = json.Marshal(stored)
+	require.NoError(t, err)
+
+	var migrated customerRecord
+	require.NoError(t, json.Unmarshal(data, &migrated))
+	assert.Equal(t, 2, migrated.storedVersion)
+	decoded, err := codec.decode(&migrated)
+	require.NoError(t, err)
+	assert.Equal(t, customer.Address, decoded.Address)
+}
+
+func TestFileCustomerRepositoryMigrate(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := `{"customers":[` + historicalRecords[1][0] + `]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, snapshotFileName), []byte(snapshot), 0o644))
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+	customer, err := repository.FindByEmail(ctx, "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Doe", customer.LastName)
+
+	count, err := repository.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"SchemaVersion":3`)
+	assert.Contains(t, string(data), `"Address":{"Street":"1 Main St"`)
+
+	// Only records stored with an older version are counted.
+	count, err = repository.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// A record from a newer version stops the open instead of being
+	// mistaken for a torn write.
+	require.NoError(t, repository.append(walEntry{Op: "commit", Customers: []*customerRecord{{SchemaVersion: 99, ID: "c2"}}}))
+	require.NoError(t, repository.wal.Close())
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+
+	_, err = OpenFileCustomerRepository(dir)
+	assert.ErrorIs(t, err, ErrUnknownSchemaVersion)
+	after, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), after.Size())
+}
+
+func TestCacheCustomerRepositoryMigrate(t *testing.T) {
+	cache := rediscache.NewRedisCache(4)
+	cache.Set(customerKey(domain.DefaultTenant, "c1"), historicalRecords[1][0], 0)
+	cache.Set(customerEmailKey(domain.DefaultTenant, "john@example.com"), "c1", 0)
+	cache.SAdd(customerTenantsKey, domain.DefaultTenant)
+	cache.SAdd(customerIDsKey(domain.DefaultTenant), "c1")
+	repository := NewCacheCustomerRepository(cache)
+	ctx := context.Background()
+
+	customer, err := repository.FindByEmail(ctx, "john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 3, customer.Version)
+
+	count, err := repository.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	stored := cacheValue(t, cache, customerKey(domain.DefaultTenant, "c1")).(string)
+	assert.True(t, strings.HasPrefix(stored, `{"SchemaVersion":3,`))
+
+	count, err = repository.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,24 @@
+package infra
+
+import (
+	"context"
+	"log"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+// DiscardEventPublisher accepts events without sending them anywhere. It
+// keeps the outbox draining when no event consumer is configured.
+type DiscardEventPublisher struct{}
+
+func (p *DiscardEventPublisher) Publish(ctx context.Context, event domain.Event) error {
+	return nil
+}
+
+// LogEventPublisher publishes events by writing them to the standard logger.
+type LogEventPublisher struct{}
+
+func (p *LogEventPublisher) Publish(ctx context.Context, event domain.Event) error {
+	log.Printf("event %s %s tenant=%s customer=%s %v", event.ID, event.Type, event.TenantID, event.CustomerID, event.Data)
+	return nil
+}
@@ -0,0 +1,587 @@
+package infra
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/macadrich/go-task-challenge/domain"
+)
+
+const (
+	walFileName      = "customers.wal"
+	snapshotFileName = "customers.snapshot"
+
+	// walHeaderSize is the length and CRC-32C prefix of every WAL record.
+	walHeaderSize = 8
+	// maxWALRecordSize bounds the payload of a WAL record, so that a damaged
+	// length is recognised instead of being taken for a record cut short.
+	maxWALRecordSize = 256 << 20
+	// compactEvery is the number of WAL records after which the log is
+	// folded into a new snapshot.
+	compactEvery = 1000
+)
+
+var (
+	ErrCorruptWAL = errors.New("customer WAL is corrupt")
+
+	// errTornWAL marks a record cut short by a crash during its write. Only
+	// the last record of the log can be torn.
+	errTornWAL = errors.New("torn WAL record")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// walEntry is one WAL record. A "commit" carries customers, the events
+// committed with them and the change feed sequence number of the last
+// customer, "delivered" the IDs of events that left the outbox.
+type walEntry struct {
+	Op        string            `json:"op"`
+	Seq       uint64            `json:"seq,omitempty"`
+	Customers []*customerRecord `json:"customers,omitempty"`
+	Events    []domain.Event    `json:"events,omitempty"`
+	EventIDs  []string          `json:"event_ids,omitempty"`
+}
+
+type snapshotFile struct {
+	Customers []*customerRecord `json:"customers"`
+	Outbox    []domain.Event    `json:"outbox,omitempty"`
+	Seq       uint64            `json:"seq,omitempty"`
+}
+
+// FileCustomerRepository is a durable customer repository. Every change is
+// appended to a checksummed write-ahead log and fsynced before it becomes
+// visible; the log is periodically compacted into a snapshot. Reads are served
+// from an in-memory copy rebuilt on open. Change feed sequence numbers carry
+// on across restarts, but only changes made since opening can be watched.
+type FileCustomerRepository struct {
+	mu         sync.Mutex
+	dir        string
+	codec      customerCodec
+	memory     *CustomerRepository
+	wal        *os.File
+	walSize    int64
+	walRecords int
+	// compactPending is set when a compaction failed and is retried after
+	// the next write.
+	compactPending bool
+
+	// stored holds the form of the last record written for every customer,
+	// keyed by tenant and ID, so rewrites can count the records they update.
+	stored map[string]storedForm
+}
+
+// storedForm is the schema version and encryption key a record was written
+// with.
+type storedForm struct {
+	version int
+	keyID   string
+}
+
+// OpenFileCustomerRepository opens or creates the repository in dir and
+// recovers its state from the last snapshot and the WAL. A torn record at the
+// end of the WAL, left by a crash during a write, is truncated.
+func OpenFileCustomerRepository(dir string, opts ...StoreOption) (*FileCustomerRepository, error) {
+	// Without a keyring the files hold plaintext PII, so only the current
+	// user may read them. Directories and files from before are tightened.
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	r := &FileCustomerRepository{
+		dir:    dir,
+		codec:  newCustomerCodec(opts),
+		memory: NewCustomerRepository(),
+		stored: make(map[string]storedForm),
+	}
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := r.replayWAL(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *FileCustomerRepository) Save(ctx context.Context, customer *domain.Customer) error {
+	return r.Commit(ctx, []*domain.Customer{customer}, nil)
+}
+
+// Commit saves customers and their events in a single WAL record, so after a
+// crash either all of them are recovered or none is. A commit that erases a
+// customer is compacted right away, so that the personal data in earlier
+// records does not stay in the WAL. Once the record is written the commit
+// succeeds, even if the compaction that follows fails.
+func (r *FileCustomerRepository) Commit(ctx context.Context, customers []*domain.Customer, events []domain.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.memory.commit(ctx, customers, events, func(customers []*domain.Customer, events []domain.Event) error {
+		records, err := r.encode(customers)
+		if err != nil {
+			return err
+		}
+		seq := r.memory.feed.lastSeq() + uint64(len(customers))
+		if err := r.append(walEntry{Op: "commit", Seq: seq, Customers: records, Events: events}); err != nil {
+			return err
+		}
+		r.track(records)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	erased := false
+	for _, event := range events {
+		if event.Type == domain.CustomerErased {
+			erased = true
+		}
+	}
+	r.compactAfterWrite(erased)
+	return nil
+}
+
+func (r *FileCustomerRepository) PendingEvents(ctx context.Context, limit int) ([]domain.Event, error) {
+	return r.memory.PendingEvents(ctx, limit)
+}
+
+func (r *FileCustomerRepository) MarkDelivered(ctx context.Context, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.append(walEntry{Op: "delivered", EventIDs: ids}); err != nil {
+		return err
+	}
+	r.memory.MarkDelivered(ctx, ids)
+
+	r.compactAfterWrite(false)
+	return nil
+}
+
+func (r *FileCustomerRepository) FindByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	return r.memory.FindByEmail(ctx, email)
+}
+
+func (r *FileCustomerRepository) FindByPhone(ctx context.Context, phone string) (*domain.Customer, error) {
+	return r.memory.FindByPhone(ctx, phone)
+}
+
+func (r *FileCustomerRepository) FindByStatus(ctx context.Context, status string) ([]*domain.Customer, error) {
+	return r.memory.FindByStatus(ctx, status)
+}
+
+func (r *FileCustomerRepository) FindByLastName(ctx context.Context, lastName string) ([]*domain.Customer, error) {
+	return r.memory.FindByLastName(ctx, lastName)
+}
+
+func (r *FileCustomerRepository) Customers(ctx context.Context) ([]*domain.Customer, error) {
+	return r.memory.Customers(ctx)
+}
+
+func (r *FileCustomerRepository) Tenants(ctx context.Context) ([]string, error) {
+	return r.memory.Tenants(ctx)
+}
+
+func (r *FileCustomerRepository) Watch(ctx context.Context, fromSeq uint64) (<-chan domain.Change, error) {
+	return r.memory.Watch(ctx, fromSeq)
+}
+
+// GetCustomers returns copies of the customers of the context's tenant keyed
+// by email.
+func (r *FileCustomerRepository) GetCustomers(ctx context.Context) map[string]*domain.Customer {
+	return r.memory.GetCustomers(ctx)
+}
+
+// Reencrypt rewrites every record with the active key of the keyring by
+// compacting the WAL into a new snapshot, and returns the number of records
+// that were sealed with another key.
+func (r *FileCustomerRepository) Reencrypt(ctx context.Context) (int, error) {
+	if r.codec.keyring == nil {
+		return 0, nil
+	}
+	activeKeyID := r.codec.keyring.ActiveKeyID()
+	return r.rewrite(func(form storedForm) bool {
+		return form.keyID != activeKeyID
+	})
+}
+
+// Migrate rewrites every record at the current schema version by compacting
+// the WAL into a new snapshot, and returns the number of records that were
+// stored with an older version. Older records are upgraded when they are
+// read, so this only updates the files.
+func (r *FileCustomerRepository) Migrate(ctx context.Context) (int, error) {
+	return r.rewrite(func(form storedForm) bool {
+		return form.version < currentSchemaVersion
+	})
+}
+
+// rewrite compacts and returns the number of records selected by outdated
+// before the compaction. Nothing is compacted when no record is selected.
+func (r *FileCustomerRepository) rewrite(outdated func(storedForm) bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, form := range r.stored {
+		if outdated(form) {
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	return count, r.compact()
+}
+
+// Compact writes a snapshot of all customers and empties the WAL.
+func (r *FileCustomerRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.compact()
+}
+
+// Close compacts the WAL and releases the log file.
+func (r *FileCustomerRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.compact(); err != nil {
+		return err
+	}
+	return r.wal.Close()
+}
+
+// append writes entry to the WAL as [length][CRC-32C][JSON] and fsyncs it.
+func (r *FileCustomerRepository) append(entry walEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxWALRecordSize {
+		return fmt.Errorf("WAL record of %d bytes exceeds the limit of %d", len(payload), maxWALRecordSize)
+	}
+
+	record := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, crcTable))
+	copy(record[walHeaderSize:], payload)
+
+	if _, err := r.wal.Write(record); err != nil {
+		r.rewind()
+		return err
+	}
+	if err := r.wal.Sync(); err != nil {
+		r.rewind()
+		return err
+	}
+
+	r.walSize += int64(len(record))
+	r.walRecords++
+	return nil
+}
+
+// rewind drops a partially written record so later appends do not follow
+// garbage.
+func (r *FileCustomerRepository) rewind() {
+	if r.wal.Truncate(r.walSize) == nil {
+		r.wal.Seek(r.walSize, io.SeekStart)
+	}
+}
+
+// compactAfterWrite compacts when now is set, when the WAL is long enough or
+// when an earlier compaction failed. The write before it is already durable
+// in the WAL, so a failed compaction is logged and retried after the next
+// write rather than reported as a failed write. It must be called with r.mu
+// held.
+func (r *FileCustomerRepository) compactAfterWrite(now bool) {
+	if !now && !r.compactPending && r.walRecords < compactEvery {
+		return
+	}
+	if err := r.compact(); err != nil {
+		log.Printf("customer store: compaction failed, retrying after the next write: %v", err)
+		r.compactPending = true
+	}
+}
+
+// compact must be called with r.mu held. The snapshot is written to a
+// temporary file and renamed into place, so a crash leaves either the old or
+// the new snapshot; replaying a WAL that was already folded in is harmless.
+func (r *FileCustomerRepository) compact() error {
+	customers, outbox := r.memory.all()
+	records, err := r.encode(customers)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshotFile{Customers: records, Outbox: outbox, Seq: r.memory.feed.lastSeq()})
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.dir, snapshotFileName)
+	if err := writeFileAtomic(path, data, 0o600); err != nil {
+		return err
+	}
+	r.stored = make(map[string]storedForm, len(records))
+	r.track(records)
+
+	if err := r.wal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := r.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.walSize = 0
+	r.walRecords = 0
+	if err := r.wal.Sync(); err != nil {
+		return err
+	}
+	r.compactPending = false
+	return nil
+}
+
+func (r *FileCustomerRepository) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot snapshotFile
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to read customer snapshot: %w", err)
+	}
+	customers, err := r.decode(snapshot.Customers)
+	if err != nil {
+		return err
+	}
+	r.memory.restore(customers, snapshot.Outbox)
+	r.memory.feed.advance(snapshot.Seq)
+	return nil
+}
+
+// replayWAL applies every intact WAL record and leaves the file open for
+// appending after the last one.
+func (r *FileCustomerRepository) replayWAL() error {
+	wal, err := os.OpenFile(filepath.Join(r.dir, walFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := wal.Chmod(0o600); err != nil {
+		wal.Close()
+		return err
+	}
+
+	info, err := wal.Stat()
+	if err != nil {
+		wal.Close()
+		return err
+	}
+
+	reader := bufio.NewReader(wal)
+	var offset int64
+	for {
+		entry, size, err := readWALRecord(reader, info.Size()-offset)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errTornWAL) {
+			// The last record was being written when the process stopped.
+			if err := wal.Truncate(offset); err != nil {
+				wal.Close()
+				return err
+			}
+			break
+		}
+		if errors.Is(err, ErrUnknownSchemaVersion) {
+			// The record is intact but written by a newer version.
+			wal.Close()
+			return err
+		}
+		if err != nil {
+			wal.Close()
+			return fmt.Errorf("%w at offset %d: %v", ErrCorruptWAL, offset, err)
+		}
+
+		switch entry.Op {
+		case "commit":
+			customers, err := r.decode(entry.Customers)
+			if err != nil {
+				wal.Close()
+				return err
+			}
+			r.memory.restore(customers, entry.Events)
+			r.memory.feed.advance(entry.Seq)
+		case "delivered":
+			r.memory.MarkDelivered(context.Background(), entry.EventIDs)
+		}
+		offset += size
+		r.walRecords++
+	}
+
+	if _, err := wal.Seek(offset, io.SeekStart); err != nil {
+		wal.Close()
+		return err
+	}
+	r.wal = wal
+	r.walSize = offset
+	return nil
+}
+
+func (r *FileCustomerRepository) encode(customers []*domain.Customer) ([]*customerRecord, error) {
+	records := make([]*customerRecord, 0, len(customers))
+	for _, customer := range customers {
+		record, err := r.codec.encode(customer)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (r *FileCustomerRepository) decode(records []*customerRecord) ([]*domain.Customer, error) {
+	customers := make([]*domain.Customer, 0, len(records))
+	for _, record := range records {
+		customer, err := r.codec.decode(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode customer %s: %w", record.ID, err)
+		}
+		customers = append(customers, customer)
+	}
+	r.track(records)
+	return customers, nil
+}
+
+// track remembers the form of records written to or read from the files.
+func (r *FileCustomerRepository) track(records []*customerRecord) {
+	for _, record := range records {
+		version := record.storedVersion
+		if version == 0 {
+			version = record.SchemaVersion
+		}
+		r.stored[record.TenantID+"\x00"+record.ID] = storedForm{version: version, keyID: record.KeyID}
+	}
+}
+
+// readWALRecord returns the next record and the number of bytes it spans.
+// remaining is the number of bytes left in the file. A record is torn, and
+// errTornWAL returned, only when the file ends inside it: within its header,
+// within its payload, or right after a payload that fails its checksum. A
+// damaged record followed by more data, or whose length reaches past an
+// intact record, is corrupt, as a crash only cuts the last write short.
+func readWALRecord(reader *bufio.Reader, remaining int64) (walEntry, int64, error) {
+	var entry walEntry
+
+	header := make([]byte, walHeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err == io.EOF {
+		return entry, 0, io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return entry, int64(n), errTornWAL
+	}
+	if err != nil {
+		return entry, int64(n), err
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	size := walHeaderSize + length
+	if length > maxWALRecordSize && remaining > walHeaderSize {
+		return entry, size, fmt.Errorf("record length %d exceeds the limit of %d", length, maxWALRecordSize)
+	}
+	if size > remaining {
+		rest := make([]byte, remaining-walHeaderSize)
+		if _, err := io.ReadFull(reader, rest); err != nil {
+			return entry, remaining, err
+		}
+		if containsWALRecord(rest) {
+			return entry, remaining, fmt.Errorf("record length %d runs past intact records", length)
+		}
+		return entry, remaining, errTornWAL
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return entry, size, err
+	}
+
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		if size == remaining {
+			return entry, size, errTornWAL
+		}
+		return entry, size, errors.New("checksum mismatch")
+	}
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return entry, size, err
+	}
+	return entry, size, nil
+}
+
+// containsWALRecord reports whether data holds a complete record with a
+// valid checksum at any offset. The payload of a torn record never does, so
+// a length reaching past such a record was damaged rather than cut short.
+func containsWALRecord(data []byte) bool {
+	for i := 0; i+walHeaderSize <= len(data); i++ {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + walHeaderSize + length
+		if length == 0 || end > len(data) {
+			continue
+		}
+		if crc32.Checksum(data[i+walHeaderSize:end], crcTable) == binary.BigEndian.Uint32(data[i+4:i+8]) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileAtomic replaces path with data so that readers see either the old
+// or the new content.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	// A temporary file left by a crash keeps its mode when reopened.
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,256 @@
+package infra
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCustomerRepositoryRecovers(t *testing.T) {
+	dir := t.TempDir()
+	ctx := domain.WithTenant(context.Background(), "acme")
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+
+	customer := &domain.Customer{Email: "john.doe@example.com", KYCStatus: "pending"}
+	require.NoError(t, repository.Save(ctx, customer))
+	customer.KYCStatus = "approved"
+	require.NoError(t, repository.Save(ctx, customer))
+
+	// Reopen without Close, as after a crash: the state comes from the WAL.
+	reopened, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	found, err := reopened.FindByEmail(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "approved", found.KYCStatus)
+	assert.Equal(t, 2, found.Version)
+
+	// After Close the state comes from the snapshot.
+	require.NoError(t, reopened.Close())
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	reopened, err = OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+	found, err = reopened.FindByEmail(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 2, found.Version)
+	assert.ErrorIs(t, reopened.Save(ctx, &domain.Customer{Email: "john.doe@example.com"}), domain.ErrVersionConflict)
+}
+
+func TestFileCustomerRepositoryTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "a@example.com"}))
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "b@example.com"}))
+
+	path := filepath.Join(dir, walFileName)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	reopened, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+
+	_, err = reopened.FindByEmail(ctx, "a@example.com")
+	assert.NoError(t, err)
+	_, err = reopened.FindByEmail(ctx, "b@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+
+	// New records are appended after the last intact one.
+	require.NoError(t, reopened.Save(ctx, &domain.Customer{Email: "c@example.com"}))
+	reopened, err = OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	_, err = reopened.FindByEmail(ctx, "c@example.com")
+	assert.NoError(t, err)
+}
+
+func TestFileCustomerRepositoryRejectsCorruptLog(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "a@example.com"}))
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "b@example.com"}))
+
+	path := filepath.Join(dir, walFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[walHeaderSize+2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err = OpenFileCustomerRepository(dir)
+	assert.ErrorIs(t, err, ErrCorruptWAL)
+}
+
+func TestFileCustomerRepositoryTruncatesDamagedLastRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "a@example.com"}))
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "b@example.com"}))
+
+	path := filepath.Join(dir, walFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	reopened, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	_, err = reopened.FindByEmail(ctx, "a@example.com")
+	assert.NoError(t, err)
+	_, err = reopened.FindByEmail(ctx, "b@example.com")
+	assert.ErrorIs(t, err, domain.ErrCustomerNotFound)
+}
+
+func TestFileCustomerRepositoryRejectsDamagedLengthBeforeTail(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "a@example.com"}))
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "b@example.com"}))
+
+	path := filepath.Join(dir, walFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	for _, length := range []uint32{maxWALRecordSize + 1, uint32(len(data))} {
+		damaged := append([]byte(nil), data...)
+		binary.BigEndian.PutUint32(damaged[0:4], length)
+		require.NoError(t, os.WriteFile(path, damaged, 0o644))
+
+		_, err = OpenFileCustomerRepository(dir)
+		assert.ErrorIs(t, err, ErrCorruptWAL, "length %d", length)
+
+		// The log is left as it was for inspection.
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(damaged)), info.Size())
+	}
+}
+
+func TestFileCustomerRepositoryRecoversOutbox(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+
+	first := &domain.Customer{Email: "a@example.com"}
+	second := &domain.Customer{Email: "b@example.com"}
+	require.NoError(t, repository.Commit(ctx, []*domain.Customer{first}, []domain.Event{domain.NewCustomerEvent(domain.CustomerRegistered, first)}))
+	require.NoError(t, repository.Commit(ctx, []*domain.Customer{second}, []domain.Event{domain.NewCustomerEvent(domain.CustomerRegistered, second)}))
+
+	pending, err := repository.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.NoError(t, repository.MarkDelivered(ctx, []string{pending[0].ID}))
+
+	// Recover once from the WAL and once from the snapshot.
+	for i := 0; i < 2; i++ {
+		reopened, err := OpenFileCustomerRepository(dir)
+		require.NoError(t, err)
+
+		recovered, err := reopened.PendingEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, recovered, 1)
+		assert.Equal(t, pending[1].ID, recovered[0].ID)
+		require.NoError(t, reopened.Close())
+	}
+}
+
+func TestFileCustomerRepositoryCompactsOnErase(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	customer := &domain.Customer{ID: "c1", Email: "john.doe@example.com", FirstName: "John", Phone: "5550100"}
+	require.NoError(t, repository.Save(ctx, customer))
+
+	customer.Erase(time.Now())
+	require.NoError(t, repository.Commit(ctx, []*domain.Customer{customer},
+		[]domain.Event{domain.NewCustomerEvent(domain.CustomerErased, customer)}))
+
+	for _, name := range []string{walFileName, snapshotFileName} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "john.doe@example.com", name)
+		assert.NotContains(t, string(data), "5550100", name)
+	}
+}
+
+func TestFileCustomerRepositoryRetriesFailedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	customer := &domain.Customer{ID: "c1", Email: "john.doe@example.com", Phone: "5550100"}
+	require.NoError(t, repository.Save(ctx, customer))
+
+	// A directory in the way of the temporary snapshot makes compaction fail.
+	tmp := filepath.Join(dir, snapshotFileName+".tmp")
+	require.NoError(t, os.Mkdir(tmp, 0o700))
+
+	customer.Erase(time.Now())
+	err = repository.Commit(ctx, []*domain.Customer{customer},
+		[]domain.Event{domain.NewCustomerEvent(domain.CustomerErased, customer)})
+	require.NoError(t, err, "the erase is durable in the WAL")
+	_, err = repository.FindByEmail(ctx, "erased:c1")
+	require.NoError(t, err)
+
+	// The compaction is retried after the next write.
+	require.NoError(t, os.Remove(tmp))
+	require.NoError(t, repository.Save(ctx, &domain.Customer{Email: "jane.doe@example.com"}))
+	wal, err := os.ReadFile(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Empty(t, wal)
+	snapshot, err := os.ReadFile(filepath.Join(dir, snapshotFileName))
+	require.NoError(t, err)
+	assert.NotContains(t, string(snapshot), "5550100")
+}
+
+func TestFileCustomerRepositoryIsPrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "customers")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, walFileName), nil, 0o644))
+
+	repository, err := OpenFileCustomerRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repository.Save(context.Background(), &domain.Customer{Email: "a@example.com"}))
+	require.NoError(t, repository.Close())
+
+	for path, want := range map[string]os.FileMode{
+		dir:                                  0o700,
+		filepath.Join(dir, walFileName):      0o600,
+		filepath.Join(dir, snapshotFileName): 0o600,
+	} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Mode().Perm(), path)
+	}
+}
@@ -0,0 +1,201 @@
+package infra
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrUnknownKey    = errors.New("unknown encryption key")
+	ErrDecryptFailed = errors.New("failed to decrypt customer field")
+)
+
+// keySize selects AES-256.
+const keySize = 32
+
+type keyFile struct {
+	Active   string            `json:"active"`
+	IndexKey string            `json:"index_key"`
+	Keys     map[string]string `json:"keys"`
+}
+
+// Keyring holds the AES-GCM keys used to encrypt customer PII at rest and the
+// HMAC key of the email blind index. Keys are identified by an ID stored with
+// every record; rotating adds a new active key and keeps the old ones for
+// decryption. The index key never rotates, as that would break lookups.
+type Keyring struct {
+	mu       sync.RWMutex
+	path     string
+	active   string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+// LoadKeyring reads the keyring from path, creating it with a fresh key if the
+// file does not exist.
+func LoadKeyring(path string) (*Keyring, error) {
+	k := &Keyring{path: path, keys: make(map[string][]byte)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		if k.indexKey, err = randomKey(); err != nil {
+			return nil, err
+		}
+		if _, err := k.Rotate(); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if k.indexKey, err = base64.StdEncoding.DecodeString(file.IndexKey); err != nil {
+		return nil, fmt.Errorf("failed to read index key: %w", err)
+	}
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("invalid key %s in key file", id)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[file.Active]; !ok {
+		return nil, fmt.Errorf("%w: active key %s", ErrUnknownKey, file.Active)
+	}
+	k.active = file.Active
+
+	return k, nil
+}
+
+// ActiveKeyID returns the ID of the key used for new records.
+func (k *Keyring) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.active
+}
+
+// Rotate adds a new key, makes it active and saves the key file.
+func (k *Keyring) Rotate() (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+	id := fmt.Sprintf("k%d", len(k.keys)+1)
+
+	k.keys[id] = key
+	previous := k.active
+	k.active = id
+	if err := k.save(); err != nil {
+		delete(k.keys, id)
+		k.active = previous
+		return "", err
+	}
+	return id, nil
+}
+
+// Encrypt seals plaintext with the given key. additionalData binds the
+// ciphertext to its record and field, so it cannot be moved elsewhere.
+func (k *Keyring) Encrypt(keyID, plaintext, additionalData string) (string, error) {
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt.
+func (k *Keyring) Decrypt(keyID, ciphertext, additionalData string) (string, error) {
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrDecryptFailed
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(additionalData))
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns a keyed hash of value that can be used to look records
+// up without storing value itself.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (k *Keyring) aead(keyID string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	key, ok := k.keys[keyID]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// save writes the key file. The caller must hold k.mu.
+func (k *Keyring) save() error {
+	file := keyFile{
+		Active:   k.active,
+		IndexKey: base64.StdEncoding.EncodeToString(k.indexKey),
+		Keys:     make(map[string]string, len(k.keys)),
+	}
+	for id, key := range k.keys {
+		file.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(k.path, data, 0o600)
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
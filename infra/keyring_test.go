@@ -0,0 +1,131 @@
+package infra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/macadrich/go-task-challenge/domain"
+	rediscache "github.com/macadrich/go-task-challenge/redis-cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keyring, err := LoadKeyring(path)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", keyring.ActiveKeyID())
+
+	sealed, err := keyring.Encrypt("k1", "Juan", "aad")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "Juan")
+
+	_, err = keyring.Decrypt("k1", sealed, "other")
+	assert.ErrorIs(t, err, ErrDecryptFailed)
+
+	keyID, err := keyring.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, "k2", keyID)
+
+	// Reloading keeps old keys for decryption and the blind index stable.
+	reloaded, err := LoadKeyring(path)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", reloaded.ActiveKeyID())
+	plaintext, err := reloaded.Decrypt("k1", sealed, "aad")
+	require.NoError(t, err)
+	assert.Equal(t, "Juan", plaintext)
+	assert.Equal(t, keyring.BlindIndex("john@example.com"), reloaded.BlindIndex("john@example.com"))
+
+	_, err = reloaded.Decrypt("k9", sealed, "aad")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func encryptedCustomer() *domain.Customer {
+	return &domain.Customer{
+		ID:        "c1",
+		FirstName: "Juan",
+		LastName:  "Dela Cruz",
+		Email:     "juan@example.com",
+		Phone:     "+639171234567",
+		Address:   domain.Address{Street: "123 Rizal St"},
+	}
+}
+
+func TestFileCustomerRepositoryEncryptsAtRest(t *testing.T) {
+	dir := t.TempDir()
+	keyring, err := LoadKeyring(filepath.Join(dir, "keys.json"))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	repository, err := OpenFileCustomerRepository(filepath.Join(dir, "customers"), WithKeyring(keyring))
+	require.NoError(t, err)
+	require.NoError(t, repository.Save(ctx, encryptedCustomer()))
+
+	wal, err := os.ReadFile(filepath.Join(dir, "customers", walFileName))
+	require.NoError(t, err)
+	for _, pii := range []string{"Juan", "Dela Cruz", "juan@example.com", "+639171234567", "Rizal"} {
+		assert.NotContains(t, string(wal), pii)
+	}
+	assert.Contains(t, string(wal), `"KeyID":"k1"`)
+
+	_, err = keyring.Rotate()
+	require.NoError(t, err)
+	count, err := repository.Reencrypt(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	require.NoError(t, repository.Close())
+
+	snapshot, err := os.ReadFile(filepath.Join(dir, "customers", snapshotFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(snapshot), `"KeyID":"k2"`)
+	assert.NotContains(t, string(snapshot), "juan@example.com")
+
+	reopened, err := OpenFileCustomerRepository(filepath.Join(dir, "customers"), WithKeyring(keyring))
+	require.NoError(t, err)
+	defer reopened.Close()
+	found, err := reopened.FindByEmail(ctx, "juan@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Dela Cruz", found.LastName)
+
+	// Without the keyring the records cannot be read.
+	_, err = OpenFileCustomerRepository(filepath.Join(dir, "customers"))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestCacheCustomerRepositoryEncryptsAtRest(t *testing.T) {
+	keyring, err := LoadKeyring(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	cache := rediscache.NewRedisCache(4)
+	repository := NewCacheCustomerRepository(cache, WithKeyring(keyring))
+	ctx := context.Background()
+
+	require.NoError(t, repository.Save(ctx, encryptedCustomer()))
+
+	record, ok := cacheValue(t, cache, customerKey(domain.DefaultTenant, "c1")).(string)
+	require.True(t, ok)
+	assert.NotContains(t, record, "juan@example.com")
+	assert.Nil(t, cacheValue(t, cache, customerEmailKey(domain.DefaultTenant, "juan@example.com")))
+
+	found, err := repository.FindByEmail(ctx, "juan@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Juan", found.FirstName)
+
+	_, err = keyring.Rotate()
+	require.NoError(t, err)
+	count, err := repository.Reencrypt(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	record = cacheValue(t, cache, customerKey(domain.DefaultTenant, "c1")).(string)
+	assert.True(t, strings.Contains(record, `"KeyID":"k2"`))
+	found, err = repository.FindByEmail(ctx, "juan@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "+639171234567", found.Phone)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/macadrich/go-task-challenge/domain"
 	"github.com/macadrich/go-task-challenge/external"
@@ -11,20 +12,62 @@ import (
 
 type KYCAdapter struct {
 	externalService *external.ExternalKYCService
+	// providers overrides the number of providers asked per verification
+	// when set.
+	providers int
 }
 
-func NewKYCAdapter(externalService *external.ExternalKYCService) *KYCAdapter {
-	return &KYCAdapter{externalService: externalService}
+// KYCAdapterOption configures a KYCAdapter.
+type KYCAdapterOption func(*KYCAdapter)
+
+// WithProviders makes the adapter ask n providers for every verification,
+// whatever number the caller requests.
+func WithProviders(n int) KYCAdapterOption {
+	return func(a *KYCAdapter) {
+		a.providers = n
+	}
 }
 
-func (a *KYCAdapter) ValidateKYC(ctx context.Context, customer *domain.Customer) error {
-	// Map the domain customer to the external service request format.
+// Providers returns the number of providers asked per verification when
+// requested are asked for.
+func (a *KYCAdapter) Providers(requested int) int {
+	if a.providers > 0 {
+		return a.providers
+	}
+	return requested
+}
+
+func NewKYCAdapter(externalService *external.ExternalKYCService, opts ...KYCAdapterOption) *KYCAdapter {
+	adapter := &KYCAdapter{externalService: externalService}
+	for _, opt := range opts {
+		opt(adapter)
+	}
+	return adapter
+}
+
+func newExternalKYCRequest(customer *domain.Customer) *external.ExternalKYCRequest {
 	request := &external.ExternalKYCRequest{
 		FullName: customer.FirstName + " " + customer.LastName,
 		Email:    customer.Email,
 		Phone:    customer.Phone,
-		Address:  customer.Address,
+		Address:  customer.Address.String(),
+	}
+	for _, document := range customer.Documents {
+		request.Documents = append(request.Documents, external.ExternalKYCDocument{
+			Type:           string(document.Type),
+			Number:         document.Number,
+			IssuingCountry: document.IssuingCountry,
+			Expiry:         document.Expiry,
+			Checksum:       document.BlobID,
+			MIMEType:       document.MIMEType,
+		})
 	}
+	return request
+}
+
+func (a *KYCAdapter) ValidateKYC(ctx context.Context, customer *domain.Customer) error {
+	// Map the domain customer to the external service request format.
+	request := newExternalKYCRequest(customer)
 
 	// Call the external service.
 	response, err := a.externalService.Validate(request)
@@ -44,12 +87,8 @@ func (a *KYCAdapter) ValidateKYC(ctx context.Context, customer *domain.Customer)
 
 func (a *KYCAdapter) VerifyCustomerKYC(ctx context.Context, numRequest int, customer *domain.Customer) error {
 	// Map the domain customer to the external service request format.
-	request := &external.ExternalKYCRequest{
-		FullName: customer.FirstName + " " + customer.LastName,
-		Email:    customer.Email,
-		Phone:    customer.Phone,
-		Address:  customer.Address,
-	}
+	request := newExternalKYCRequest(customer)
+	numRequest = a.Providers(numRequest)
 
 	results := make(chan *external.ExternalKYCResponse, numRequest)
 	errorsChan := make(chan error, numRequest)
@@ -58,24 +97,38 @@ func (a *KYCAdapter) VerifyCustomerKYC(ctx context.Context, numRequest int, cust
 
 	for i := 0; i < numRequest; i++ {
 		wg.Add(1)
-		go func() {
+		go func(provider int) {
 			defer wg.Done()
 			// Simulate receiving verification result from external API
+			start := time.Now()
 			response, err := a.externalService.Verify(request)
+			result := domain.ProviderResult{Provider: provider, Err: err, Duration: time.Since(start)}
 			if err != nil {
+				domain.ReportKYCProgress(ctx, result)
 				errorsChan <- err
 				return
 			}
+			result.Status = response.Status
+			domain.ReportKYCProgress(ctx, result)
 			results <- response
-		}()
+		}(i + 1)
 	}
 
+	done := make(chan struct{})
 	go func() {
 		wg.Wait()
 		close(results)
 		close(errorsChan)
+		close(done)
 	}()
 
+	// Stop waiting for the providers once the caller gives up.
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
 	var finalStatus string
 	for response := range results {
 		if response.Status == "approved" {
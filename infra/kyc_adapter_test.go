@@ -20,7 +20,7 @@ func TestKYCAdapter(t *testing.T) {
 		LastName:  "Doe",
 		Email:     "john.doe@example.com",
 		Phone:     "1234567890",
-		Address:   "123 Main St",
+		Address:   domain.Address{Street: "123 Main St"},
 	}
 
 	ctx := context.Background()
@@ -30,6 +30,22 @@ func TestKYCAdapter(t *testing.T) {
 	assert.Equal(t, "pending", customer.KYCStatus)
 }
 
+func TestKYCAdapterWithProviders(t *testing.T) {
+	adapter := NewKYCAdapter(&external.ExternalKYCService{}, WithProviders(3))
+
+	var mu sync.Mutex
+	providers := 0
+	ctx := domain.WithKYCProgress(context.Background(), func(domain.ProviderResult) {
+		mu.Lock()
+		providers++
+		mu.Unlock()
+	})
+
+	customer := &domain.Customer{FirstName: "John", LastName: "Doe", Email: "john.doe@example.com"}
+	assert.NoError(t, adapter.VerifyCustomerKYC(ctx, constants.NumberOfRoutines, customer))
+	assert.Equal(t, 3, providers)
+}
+
 func TestSimulateKYCValidation(t *testing.T) {
 	externalService := &external.ExternalKYCService{}
 	adapter := NewKYCAdapter(externalService)
@@ -46,7 +62,7 @@ func TestSimulateKYCValidation(t *testing.T) {
 				LastName:  "Doe",
 				Email:     "john.doe@example.com",
 				Phone:     "1234567890",
-				Address:   "123 Main St",
+				Address:   domain.Address{Street: "123 Main St"},
 			}
 
 			ctx := context.Background()
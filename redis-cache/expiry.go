@@ -0,0 +1,147 @@
+package rediscache
+
+import (
+	"container/heap"
+	"time"
+)
+
+const (
+	// expiryInterval is how often the active expiry cycle runs, like the
+	// default hz of Redis.
+	expiryInterval = 100 * time.Millisecond
+
+	// expiryBudget bounds the time of one cycle, a quarter of the interval
+	// as in Redis. A cycle that runs out of time with expired keys left is
+	// followed by the next one after expiryBudget instead of a full
+	// interval, so a backlog is reclaimed quickly without taking over the
+	// cache.
+	expiryBudget = expiryInterval / 4
+
+	// expiryBatch is how many keys are removed each time the lock of a
+	// shard is taken, so that commands on the shard are not held up.
+	expiryBatch = 20
+)
+
+// expiry is the expiry time of a key. The expiries of a shard are kept both
+// by key and in a min-heap, so the keys expiring first are found without
+// scanning the others.
+type expiry struct {
+	key   string
+	at    time.Time
+	index int
+}
+
+// expiryHeap orders expiries by time, the first to expire on top.
+type expiryHeap []*expiry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	e := x.(*expiry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// setExpiry makes key expire at the given time. The caller must hold sh.mu
+// for writing.
+func (sh *shard) setExpiry(key string, at time.Time) {
+	if e, found := sh.ttl[key]; found {
+		e.at = at
+		heap.Fix(&sh.expiries, e.index)
+		return
+	}
+	e := &expiry{key: key, at: at}
+	sh.ttl[key] = e
+	heap.Push(&sh.expiries, e)
+}
+
+// clearExpiry removes the expiry of key. The caller must hold sh.mu for
+// writing.
+func (sh *shard) clearExpiry(key string) {
+	if e, found := sh.ttl[key]; found {
+		heap.Remove(&sh.expiries, e.index)
+		delete(sh.ttl, key)
+	}
+}
+
+// expireBatch removes up to max expired keys, the first to expire first, and
+// reports whether expired keys are left.
+func (sh *shard) expireBatch(now time.Time, max int) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	for removed := 0; len(sh.expiries) > 0 && !now.Before(sh.expiries[0].at); removed++ {
+		if removed == max {
+			return true
+		}
+		sh.delete(sh.expiries[0].key)
+	}
+	return false
+}
+
+// cleanExpiredKeys runs the active expiry cycle until the cache is closed.
+// Keys are also removed when a command finds them expired, so the cycle only
+// reclaims the memory of keys nobody reads.
+func (c *Cache) cleanExpiredKeys() {
+	timer := time.NewTimer(expiryInterval)
+	defer timer.Stop()
+
+	next := 0
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-timer.C:
+		}
+		var finished bool
+		next, finished = c.expireCycle(next, time.Now().Add(expiryBudget))
+		if finished {
+			timer.Reset(expiryInterval)
+		} else {
+			timer.Reset(expiryBudget)
+		}
+	}
+}
+
+// expireCycle removes expired keys a batch at a time, going round the shards
+// from start until a whole round finds none left or the deadline passes. It
+// returns the shard the next cycle starts at and whether this one finished.
+func (c *Cache) expireCycle(start int, deadline time.Time) (int, bool) {
+	i := start
+	for clean := 0; clean < len(c.shards); {
+		now := time.Now()
+		if !now.Before(deadline) {
+			return i, false
+		}
+		if c.shards[i].expireBatch(now, expiryBatch) {
+			clean = 0
+		} else {
+			clean++
+		}
+		i = (i + 1) % len(c.shards)
+	}
+	return i, true
+}
+
+// Close stops the active expiry of the cache. The cache keeps working and
+// expired keys are still removed when commands find them. Close may be
+// called more than once.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+}
@@ -0,0 +1,126 @@
+package rediscache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// checkExpiries verifies that the expiry heap of every shard holds exactly
+// the keys with a TTL, the first to expire on top.
+func checkExpiries(t *testing.T, cache *Cache) {
+	t.Helper()
+	for _, sh := range cache.shards {
+		sh.mu.RLock()
+		if len(sh.expiries) != len(sh.ttl) {
+			t.Errorf("Expected %d expiries in the heap, got %d", len(sh.ttl), len(sh.expiries))
+		}
+		for i, e := range sh.expiries {
+			if e.index != i || sh.ttl[e.key] != e {
+				t.Errorf("Heap entry %d for %s is out of date", i, e.key)
+			}
+			if i > 0 && e.at.Before(sh.expiries[(i-1)/2].at) {
+				t.Errorf("Heap entry %d expires before its parent", i)
+			}
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+func TestCacheExpiryHeapFollowsTTLChanges(t *testing.T) {
+	cache := NewRedisCache(2, WithShards(2))
+	defer cache.Close()
+
+	for i := 0; i < 50; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), "value", time.Duration(50-i)*time.Hour)
+	}
+	for i := 0; i < 50; i += 5 {
+		key := fmt.Sprintf("key%d", i)
+		switch i % 4 {
+		case 0:
+			cache.Persist(key)
+		case 1:
+			cache.Expire(key, time.Minute)
+		case 2:
+			cache.Set(key, "other", 0)
+		default:
+			cache.Del(key)
+		}
+	}
+	cache.GetEx("key1", GetExOptions{TTL: time.Second})
+	cache.GetEx("key2", GetExOptions{Persist: true})
+
+	checkExpiries(t, cache)
+	if ttl := cache.TTL("key5"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Expected key5 to expire within a minute, got %v", ttl)
+	}
+}
+
+func TestCacheReclaimsExpiredKeysNobodyReads(t *testing.T) {
+	cache := NewRedisCache(2)
+	defer cache.Close()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("short%d", i), "value", 10*time.Millisecond)
+	}
+	cache.Set("long", "value", time.Hour)
+	cache.Set("forever", "value", 0)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cache.MemoryStats().Keys > 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected expired keys to be reclaimed, %d keys left", cache.MemoryStats().Keys)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cache.Exists("long", "forever") != 2 {
+		t.Error("Expected keys that did not expire to stay")
+	}
+	checkExpiries(t, cache)
+}
+
+func TestCacheExpireBatchIsBounded(t *testing.T) {
+	cache := NewRedisCache(1, WithShards(1))
+	cache.Close()
+
+	for i := 0; i < 3*expiryBatch; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), "value", time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	sh := cache.shards[0]
+	if left := sh.expireBatch(time.Now(), expiryBatch); !left {
+		t.Error("Expected expired keys to be left after one batch")
+	}
+	if keys := cache.MemoryStats().Keys; keys != 2*expiryBatch {
+		t.Errorf("Expected one batch of keys removed, %d keys left", keys)
+	}
+
+	if _, finished := cache.expireCycle(0, time.Now()); finished {
+		t.Error("Expected a cycle past its deadline to stop")
+	}
+	if _, finished := cache.expireCycle(0, time.Now().Add(time.Second)); !finished {
+		t.Error("Expected the cycle to finish")
+	}
+	if keys := cache.MemoryStats().Keys; keys != 0 {
+		t.Errorf("Expected every expired key to be removed, %d keys left", keys)
+	}
+}
+
+func TestCacheCloseStopsActiveExpiry(t *testing.T) {
+	cache := NewRedisCache(1)
+	cache.Close()
+	cache.Close()
+
+	cache.Set("key", "value", 10*time.Millisecond)
+	time.Sleep(3 * expiryInterval)
+	if keys := cache.MemoryStats().Keys; keys != 1 {
+		t.Errorf("Expected the expired key to stay after Close, got %d keys", keys)
+	}
+	if value, _ := cache.Get("key"); value != nil {
+		t.Errorf("Expected expired keys to be hidden after Close, got %v", value)
+	}
+	if keys := cache.MemoryStats().Keys; keys != 0 {
+		t.Errorf("Expected reading the key to remove it, got %d keys", keys)
+	}
+}
@@ -0,0 +1,284 @@
+package rediscache
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// hashValue is the value of a key holding a hash. A key is deleted when its
+// hash has no fields left.
+type hashValue struct {
+	fields map[string]interface{}
+	bytes  int64
+}
+
+func (h *hashValue) memoryUsage() int64 {
+	return h.bytes
+}
+
+func (h *hashValue) set(field string, value interface{}) {
+	if previous, found := h.fields[field]; found {
+		h.bytes -= valueSize(previous)
+	} else {
+		h.bytes += int64(len(field)) + itemOverhead
+	}
+	h.fields[field] = value
+	h.bytes += valueSize(value)
+}
+
+func (h *hashValue) remove(field string) bool {
+	value, found := h.fields[field]
+	if !found {
+		return false
+	}
+	delete(h.fields, field)
+	h.bytes -= int64(len(field)) + itemOverhead + valueSize(value)
+	return true
+}
+
+// sortedFields returns the fields of the hash in order, so that HKEYS, HVALS
+// and HGETALL list them the same way every time.
+func (h *hashValue) sortedFields() []string {
+	fields := make([]string, 0, len(h.fields))
+	for field := range h.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// hash returns the hash stored under key, or nil when there is none. With
+// create a missing hash is created. The caller must hold sh.mu for writing.
+func (sh *shard) hash(key string, now time.Time, create bool) (*hashValue, error) {
+	if sh.expired(key, now) {
+		sh.delete(key)
+	}
+	value, found := sh.data[key]
+	if !found {
+		if !create {
+			return nil, nil
+		}
+		h := &hashValue{fields: make(map[string]interface{})}
+		sh.data[key] = h
+		return h, nil
+	}
+	h, ok := value.(*hashValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return h, nil
+}
+
+// handleHashRequest runs the hash commands. Writing a field keeps the expiry
+// of the key.
+func (c *Cache) handleHashRequest(req Request) {
+	sh := c.shardFor(req.Key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	now := time.Now()
+
+	create := req.Command == HSET || req.Command == HINCRBY
+	h, err := sh.hash(req.Key, now, create)
+	if err != nil {
+		req.Result <- reply{err: err}
+		return
+	}
+
+	switch req.Command {
+	case HSET:
+		added := 0
+		for field, value := range req.Value.(map[string]interface{}) {
+			if _, found := h.fields[field]; !found {
+				added++
+			}
+			h.set(field, value)
+		}
+		if len(h.fields) == 0 {
+			sh.delete(req.Key)
+		}
+		req.Result <- reply{value: added}
+
+	case HGET:
+		var value interface{}
+		if h != nil {
+			value = h.fields[req.Fields[0]]
+		}
+		req.Result <- reply{value: value}
+
+	case HMGET:
+		values := make([]interface{}, len(req.Fields))
+		if h != nil {
+			for i, field := range req.Fields {
+				values[i] = h.fields[field]
+			}
+		}
+		req.Result <- reply{value: values}
+
+	case HGETALL:
+		fields := make(map[string]interface{})
+		if h != nil {
+			for field, value := range h.fields {
+				fields[field] = value
+			}
+		}
+		req.Result <- reply{value: fields}
+
+	case HDEL:
+		removed := 0
+		if h != nil {
+			for _, field := range req.Fields {
+				if h.remove(field) {
+					removed++
+				}
+			}
+			if len(h.fields) == 0 {
+				sh.delete(req.Key)
+			}
+		}
+		req.Result <- reply{value: removed}
+
+	case HEXISTS:
+		found := false
+		if h != nil {
+			_, found = h.fields[req.Fields[0]]
+		}
+		req.Result <- reply{value: found}
+
+	case HLEN:
+		length := 0
+		if h != nil {
+			length = len(h.fields)
+		}
+		req.Result <- reply{value: length}
+
+	case HINCRBY:
+		field := req.Fields[0]
+		var current int64
+		if value, found := h.fields[field]; found {
+			if current, err = integerValue(value); err != nil {
+				// integerValue reports other types as WRONGTYPE, which
+				// is about keys; a field only holds a bad number.
+				err = ErrNotInteger
+			}
+		}
+		if err == nil {
+			current, err = addInteger(current, req.Value.(int64))
+		}
+		if err != nil {
+			if len(h.fields) == 0 {
+				sh.delete(req.Key)
+			}
+			req.Result <- reply{err: err}
+			return
+		}
+		h.set(field, strconv.FormatInt(current, 10))
+		req.Result <- reply{value: current}
+
+	case HKEYS:
+		fields := []string{}
+		if h != nil {
+			fields = h.sortedFields()
+		}
+		req.Result <- reply{value: fields}
+
+	case HVALS:
+		values := []interface{}{}
+		if h != nil {
+			for _, field := range h.sortedFields() {
+				values = append(values, h.fields[field])
+			}
+		}
+		req.Result <- reply{value: values}
+	}
+}
+
+// HSet sets fields of the hash under key and returns how many of them are new.
+func (c *Cache) HSet(key string, fields map[string]interface{}) (int, error) {
+	result := c.call(Request{Command: HSET, Key: key, Value: fields})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// HGet returns the value of field in the hash under key, or nil when there is
+// no such field.
+func (c *Cache) HGet(key, field string) (interface{}, error) {
+	result := c.call(Request{Command: HGET, Key: key, Fields: []string{field}})
+	return result.value, result.err
+}
+
+// HMGet returns the values of fields in the hash under key in the same order,
+// with nil for missing fields.
+func (c *Cache) HMGet(key string, fields ...string) ([]interface{}, error) {
+	result := c.call(Request{Command: HMGET, Key: key, Fields: fields})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.([]interface{}), nil
+}
+
+// HGetAll returns a copy of the fields of the hash under key.
+func (c *Cache) HGetAll(key string) (map[string]interface{}, error) {
+	result := c.call(Request{Command: HGETALL, Key: key})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.(map[string]interface{}), nil
+}
+
+// HDel removes fields from the hash under key and returns how many existed.
+func (c *Cache) HDel(key string, fields ...string) (int, error) {
+	result := c.call(Request{Command: HDEL, Key: key, Fields: fields})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// HExists reports whether the hash under key has field.
+func (c *Cache) HExists(key, field string) (bool, error) {
+	result := c.call(Request{Command: HEXISTS, Key: key, Fields: []string{field}})
+	if result.err != nil {
+		return false, result.err
+	}
+	return result.value.(bool), nil
+}
+
+// HLen returns the number of fields of the hash under key.
+func (c *Cache) HLen(key string) (int, error) {
+	result := c.call(Request{Command: HLEN, Key: key})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// HIncrBy adds delta to the counter in field of the hash under key and
+// returns the new value. A missing field starts at zero.
+func (c *Cache) HIncrBy(key, field string, delta int64) (int64, error) {
+	result := c.call(Request{Command: HINCRBY, Key: key, Fields: []string{field}, Value: delta})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int64), nil
+}
+
+// HKeys returns the fields of the hash under key in order.
+func (c *Cache) HKeys(key string) ([]string, error) {
+	result := c.call(Request{Command: HKEYS, Key: key})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.([]string), nil
+}
+
+// HVals returns the values of the hash under key, ordered by field.
+func (c *Cache) HVals(key string) ([]interface{}, error) {
+	result := c.call(Request{Command: HVALS, Key: key})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.([]interface{}), nil
+}
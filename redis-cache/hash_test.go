@@ -0,0 +1,129 @@
+package rediscache
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheHashCommands(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	added, err := cache.HSet("session", map[string]interface{}{"user": "alice", "tenant": "acme"})
+	if err != nil || added != 2 {
+		t.Fatalf("Expected 2 new fields, got %d, %v", added, err)
+	}
+	if added, _ := cache.HSet("session", map[string]interface{}{"user": "bob", "role": "admin"}); added != 1 {
+		t.Errorf("Expected 1 new field, got %d", added)
+	}
+
+	if value, _ := cache.HGet("session", "user"); value != "bob" {
+		t.Errorf("Expected bob, got %v", value)
+	}
+	if value, _ := cache.HGet("session", "missing"); value != nil {
+		t.Errorf("Expected nil, got %v", value)
+	}
+	if values, _ := cache.HMGet("session", "role", "missing", "tenant"); !reflect.DeepEqual(values, []interface{}{"admin", nil, "acme"}) {
+		t.Errorf("Expected [admin <nil> acme], got %v", values)
+	}
+
+	all, _ := cache.HGetAll("session")
+	if !reflect.DeepEqual(all, map[string]interface{}{"user": "bob", "tenant": "acme", "role": "admin"}) {
+		t.Errorf("Unexpected fields %v", all)
+	}
+	all["user"] = "changed"
+	if value, _ := cache.HGet("session", "user"); value != "bob" {
+		t.Errorf("Expected HGetAll to return a copy, got %v", value)
+	}
+
+	if keys, _ := cache.HKeys("session"); !reflect.DeepEqual(keys, []string{"role", "tenant", "user"}) {
+		t.Errorf("Expected sorted fields, got %v", keys)
+	}
+	if values, _ := cache.HVals("session"); !reflect.DeepEqual(values, []interface{}{"admin", "acme", "bob"}) {
+		t.Errorf("Expected values ordered by field, got %v", values)
+	}
+
+	if found, _ := cache.HExists("session", "role"); !found {
+		t.Error("Expected role to exist")
+	}
+	if removed, _ := cache.HDel("session", "role", "missing"); removed != 1 {
+		t.Errorf("Expected 1 removed field, got %d", removed)
+	}
+	if length, _ := cache.HLen("session"); length != 2 {
+		t.Errorf("Expected 2 fields, got %d", length)
+	}
+
+	cache.HDel("session", "user", "tenant")
+	if cache.Exists("session") != 0 {
+		t.Error("Expected a hash without fields to be deleted")
+	}
+}
+
+func TestCacheHashKeepsKeyTTL(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.HSet("stats", map[string]interface{}{"calls": "1"})
+	cache.Expire("stats", 50*time.Millisecond)
+
+	if _, err := cache.HIncrBy("stats", "calls", 1); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := cache.TTL("stats"); remaining <= 0 {
+		t.Errorf("Expected writing a field to keep the TTL, got %v", remaining)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if length, _ := cache.HLen("stats"); length != 0 {
+		t.Errorf("Expected the hash to expire, got %d fields", length)
+	}
+}
+
+func TestCacheHIncrBy(t *testing.T) {
+	cache := NewRedisCache(4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				cache.HIncrBy("stats", "calls", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if value, _ := cache.HGet("stats", "calls"); value != "200" {
+		t.Errorf("Expected 200, got %v", value)
+	}
+
+	cache.HSet("stats", map[string]interface{}{"name": "provider"})
+	if _, err := cache.HIncrBy("stats", "name", 1); !errors.Is(err, ErrNotInteger) {
+		t.Errorf("Expected ErrNotInteger, got %v", err)
+	}
+	if _, err := cache.HIncrBy("empty", "name", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheHashWrongType(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("plain", "value", 0)
+	cache.HSet("hash", map[string]interface{}{"field": "value"})
+
+	if _, err := cache.HSet("plain", map[string]interface{}{"field": "value"}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.HGet("plain", "field"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.LPush("hash", "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.Get("hash"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if values := cache.MGet("hash"); values[0] != nil {
+		t.Errorf("Expected MGet to return nil for the hash, got %v", values[0])
+	}
+}
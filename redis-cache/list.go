@@ -0,0 +1,495 @@
+package rediscache
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNegativeTimeout is returned by BLPop and BRPop for a negative timeout.
+var ErrNegativeTimeout = errors.New("ERR timeout is negative")
+
+// listValue is the value of a key holding a list. A key is deleted when its
+// list becomes empty.
+type listValue struct {
+	items []interface{}
+	bytes int64
+}
+
+func (l *listValue) memoryUsage() int64 {
+	return l.bytes
+}
+
+// setItems replaces the items of the list.
+func (l *listValue) setItems(items []interface{}) {
+	l.items = items
+	l.bytes = 0
+	for _, item := range items {
+		l.bytes += valueSize(item) + itemOverhead
+	}
+}
+
+func (l *listValue) pushBack(values []interface{}) {
+	l.items = append(l.items, values...)
+	for _, value := range values {
+		l.bytes += valueSize(value) + itemOverhead
+	}
+}
+
+// pushFront inserts values at the head one after the other, so that the last
+// value ends up first.
+func (l *listValue) pushFront(values []interface{}) {
+	items := make([]interface{}, 0, len(values)+len(l.items))
+	for i := len(values) - 1; i >= 0; i-- {
+		items = append(items, values[i])
+		l.bytes += valueSize(values[i]) + itemOverhead
+	}
+	l.items = append(items, l.items...)
+}
+
+func (l *listValue) pop(left bool) interface{} {
+	var value interface{}
+	if left {
+		value = l.items[0]
+		l.items[0] = nil
+		l.items = l.items[1:]
+	} else {
+		last := len(l.items) - 1
+		value = l.items[last]
+		l.items[last] = nil
+		l.items = l.items[:last]
+	}
+	l.bytes -= valueSize(value) + itemOverhead
+	return value
+}
+
+// bounds turns the inclusive Redis indexes start and stop into a range of
+// the items. It reports false when the range is empty.
+func (l *listValue) bounds(start, stop int) (int, int, bool) {
+	return indexRange(len(l.items), start, stop)
+}
+
+// indexRange turns the inclusive Redis indexes start and stop of a sequence
+// of n elements, which count from the end when negative, into a half-open
+// range. It reports false when the range is empty.
+func indexRange(n, start, stop int) (int, int, bool) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop || start >= n {
+		return 0, 0, false
+	}
+	return start, stop + 1, true
+}
+
+// popWaiter is a client blocked in BLPOP or BRPOP. Waiters are kept per key
+// in the order they blocked, in the shard of the key. A client blocked on
+// keys of several shards may be reached from any of them, so whoever sets
+// done first, a push serving it or the client giving up, wins.
+type popWaiter struct {
+	keys   []string
+	left   bool
+	done   atomic.Bool
+	result chan poppedValue
+}
+
+// poppedValue is the reply of BLPOP and BRPOP.
+type poppedValue struct {
+	key   string
+	value interface{}
+}
+
+// list returns the list stored under key, or nil when there is none. With
+// create a missing list is created. The caller must hold sh.mu for writing.
+func (sh *shard) list(key string, now time.Time, create bool) (*listValue, error) {
+	if sh.expired(key, now) {
+		sh.delete(key)
+	}
+	value, found := sh.data[key]
+	if !found {
+		if !create {
+			return nil, nil
+		}
+		l := &listValue{}
+		sh.data[key] = l
+		return l, nil
+	}
+	l, ok := value.(*listValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return l, nil
+}
+
+// dropEmpty deletes key when its list has no items left. The caller must hold
+// sh.mu for writing.
+func (sh *shard) dropEmpty(key string, l *listValue) {
+	if len(l.items) == 0 {
+		sh.delete(key)
+	}
+}
+
+// handleListRequest runs the list commands. Blocking pops never wait in the
+// worker: when no list has items the waiter is returned to the caller, which
+// waits on it while the worker serves other requests.
+func (c *Cache) handleListRequest(req Request) {
+	switch req.Command {
+	case BLPOP, BRPOP:
+		c.handleBlockingPop(req)
+		return
+	case UNBLOCK:
+		c.unblock(req)
+		return
+	}
+	sh := c.shardFor(req.Key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	now := time.Now()
+
+	switch req.Command {
+	case LPUSH, RPUSH:
+		l, err := sh.list(req.Key, now, true)
+		if err != nil {
+			req.Result <- reply{err: err}
+			return
+		}
+		if req.Command == RPUSH {
+			l.pushBack(req.Values)
+		} else {
+			l.pushFront(req.Values)
+		}
+		length := len(l.items)
+		sh.serveWaiters(req.Key, l)
+		req.Result <- reply{value: length}
+
+	case LPOP, RPOP:
+		l, err := sh.list(req.Key, now, false)
+		if err != nil || l == nil {
+			req.Result <- reply{err: err}
+			return
+		}
+		value := l.pop(req.Command == LPOP)
+		sh.dropEmpty(req.Key, l)
+		req.Result <- reply{value: value}
+
+	case LLEN:
+		l, err := sh.list(req.Key, now, false)
+		length := 0
+		if l != nil {
+			length = len(l.items)
+		}
+		req.Result <- reply{value: length, err: err}
+
+	case LRANGE:
+		l, err := sh.list(req.Key, now, false)
+		items := []interface{}{}
+		if l != nil {
+			if start, end, ok := l.bounds(req.Start, req.Stop); ok {
+				items = append(items, l.items[start:end]...)
+			}
+		}
+		req.Result <- reply{value: items, err: err}
+
+	case LINDEX:
+		l, err := sh.list(req.Key, now, false)
+		var value interface{}
+		if l != nil {
+			index := req.Start
+			if index < 0 {
+				index += len(l.items)
+			}
+			if index >= 0 && index < len(l.items) {
+				value = l.items[index]
+			}
+		}
+		req.Result <- reply{value: value, err: err}
+
+	case LREM:
+		l, err := sh.list(req.Key, now, false)
+		if err != nil || l == nil {
+			req.Result <- reply{value: 0, err: err}
+			return
+		}
+		removed := l.remove(req.Value, req.Count)
+		sh.dropEmpty(req.Key, l)
+		req.Result <- reply{value: removed}
+
+	case LTRIM:
+		l, err := sh.list(req.Key, now, false)
+		if err != nil || l == nil {
+			req.Result <- reply{err: err}
+			return
+		}
+		if start, end, ok := l.bounds(req.Start, req.Stop); ok {
+			l.setItems(append([]interface{}(nil), l.items[start:end]...))
+		} else {
+			l.setItems(nil)
+		}
+		sh.dropEmpty(req.Key, l)
+		req.Result <- reply{}
+
+	}
+}
+
+// handleBlockingPop pops from the first non-empty list among the keys of a
+// BLPOP or BRPOP, or queues a waiter on all of them. The shards of the keys
+// stay locked until the waiter is queued, so no push can be missed.
+func (c *Cache) handleBlockingPop(req Request) {
+	unlock := c.lockKeys(req.Keys, true)
+	defer unlock()
+	now := time.Now()
+
+	left := req.Command == BLPOP
+	for _, key := range req.Keys {
+		sh := c.shardFor(key)
+		l, err := sh.list(key, now, false)
+		if err != nil {
+			req.Result <- reply{err: err}
+			return
+		}
+		if l != nil {
+			value := l.pop(left)
+			sh.dropEmpty(key, l)
+			req.Result <- reply{value: poppedValue{key: key, value: value}}
+			return
+		}
+	}
+	waiter := &popWaiter{keys: req.Keys, left: left, result: make(chan poppedValue, 1)}
+	for _, key := range req.Keys {
+		sh := c.shardFor(key)
+		sh.waiters[key] = append(sh.waiters[key], waiter)
+	}
+	req.Result <- reply{value: waiter}
+}
+
+// unblock takes a waiter out of the queues of all its keys and replies
+// whether it had been served before.
+func (c *Cache) unblock(req Request) {
+	waiter := req.Value.(*popWaiter)
+	unlock := c.lockKeys(waiter.keys, true)
+	defer unlock()
+
+	served := !waiter.done.CompareAndSwap(false, true)
+	for _, key := range waiter.keys {
+		c.shardFor(key).removeWaiter(key, waiter)
+	}
+	req.Result <- served
+}
+
+// remove deletes up to count items equal to value, from the head when count
+// is positive, from the tail when it is negative and everywhere when it is
+// zero, and returns how many it deleted.
+func (l *listValue) remove(value interface{}, count int) int {
+	removed := 0
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	matches := func(item interface{}) bool {
+		return (limit == 0 || removed < limit) && reflect.DeepEqual(item, value)
+	}
+
+	kept := make([]interface{}, 0, len(l.items))
+	if count >= 0 {
+		for _, item := range l.items {
+			if matches(item) {
+				removed++
+				continue
+			}
+			kept = append(kept, item)
+		}
+	} else {
+		for i := len(l.items) - 1; i >= 0; i-- {
+			if matches(l.items[i]) {
+				removed++
+				continue
+			}
+			kept = append(kept, l.items[i])
+		}
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+	}
+	l.setItems(kept)
+	return removed
+}
+
+// serveWaiters hands items of the list under key to the clients blocked on
+// it, first come first served. Waiters already served through another key
+// or gone are skipped. The caller must hold sh.mu for writing.
+func (sh *shard) serveWaiters(key string, l *listValue) {
+	for len(l.items) > 0 && len(sh.waiters[key]) > 0 {
+		waiter := sh.waiters[key][0]
+		sh.removeWaiter(key, waiter)
+		if waiter.done.CompareAndSwap(false, true) {
+			waiter.result <- poppedValue{key: key, value: l.pop(waiter.left)}
+		}
+	}
+	sh.dropEmpty(key, l)
+}
+
+// removeWaiter takes waiter out of the queue of key. The caller must hold
+// sh.mu for writing.
+func (sh *shard) removeWaiter(key string, waiter *popWaiter) {
+	queue := sh.waiters[key]
+	for i, w := range queue {
+		if w == waiter {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(sh.waiters, key)
+	} else {
+		sh.waiters[key] = queue
+	}
+}
+
+// call sends a command that replies with a reply and waits for it.
+func (c *Cache) call(req Request) reply {
+	req.Result = make(chan interface{})
+	c.requests <- req
+	return (<-req.Result).(reply)
+}
+
+// LPush inserts values at the head of the list under key, so that the last
+// value ends up first, and returns the length of the list.
+func (c *Cache) LPush(key string, values ...interface{}) (int, error) {
+	result := c.call(Request{Command: LPUSH, Key: key, Values: values})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// RPush appends values to the list under key and returns the length of the
+// list.
+func (c *Cache) RPush(key string, values ...interface{}) (int, error) {
+	result := c.call(Request{Command: RPUSH, Key: key, Values: values})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// LPop removes and returns the first item of the list under key, or nil when
+// there is no such list.
+func (c *Cache) LPop(key string) (interface{}, error) {
+	result := c.call(Request{Command: LPOP, Key: key})
+	return result.value, result.err
+}
+
+// RPop removes and returns the last item of the list under key, or nil when
+// there is no such list.
+func (c *Cache) RPop(key string) (interface{}, error) {
+	result := c.call(Request{Command: RPOP, Key: key})
+	return result.value, result.err
+}
+
+// LLen returns the length of the list under key.
+func (c *Cache) LLen(key string) (int, error) {
+	result := c.call(Request{Command: LLEN, Key: key})
+	return result.value.(int), result.err
+}
+
+// LRange returns the items of the list under key from start to stop
+// inclusive. Negative indexes count from the end of the list.
+func (c *Cache) LRange(key string, start, stop int) ([]interface{}, error) {
+	result := c.call(Request{Command: LRANGE, Key: key, Start: start, Stop: stop})
+	return result.value.([]interface{}), result.err
+}
+
+// LIndex returns the item at index of the list under key, or nil when the
+// index is out of range. A negative index counts from the end of the list.
+func (c *Cache) LIndex(key string, index int) (interface{}, error) {
+	result := c.call(Request{Command: LINDEX, Key: key, Start: index})
+	return result.value, result.err
+}
+
+// LRem removes items equal to value from the list under key and returns how
+// many it removed. A positive count removes at most count items from the
+// head, a negative count from the tail and zero removes all of them.
+func (c *Cache) LRem(key string, count int, value interface{}) (int, error) {
+	result := c.call(Request{Command: LREM, Key: key, Count: count, Value: value})
+	return result.value.(int), result.err
+}
+
+// LTrim keeps only the items of the list under key from start to stop
+// inclusive. Negative indexes count from the end of the list.
+func (c *Cache) LTrim(key string, start, stop int) error {
+	return c.call(Request{Command: LTRIM, Key: key, Start: start, Stop: stop}).err
+}
+
+// BLPop removes and returns the first item of the first non-empty list among
+// keys, together with its key. When all lists are empty it waits up to
+// timeout for an item, or forever when timeout is zero; clients waiting on
+// the same key are served in the order they started waiting. The key is
+// empty when the timeout passed.
+func (c *Cache) BLPop(timeout time.Duration, keys ...string) (string, interface{}, error) {
+	return c.blockingPop(BLPOP, timeout, keys)
+}
+
+// BRPop is like BLPop but removes the last item of the list.
+func (c *Cache) BRPop(timeout time.Duration, keys ...string) (string, interface{}, error) {
+	return c.blockingPop(BRPOP, timeout, keys)
+}
+
+func (c *Cache) blockingPop(command Command, timeout time.Duration, keys []string) (string, interface{}, error) {
+	if timeout < 0 {
+		return "", nil, ErrNegativeTimeout
+	}
+	if len(keys) == 0 {
+		return "", nil, ErrSyntax
+	}
+	result := c.call(Request{Command: command, Keys: keys})
+	if result.err != nil {
+		return "", nil, result.err
+	}
+	waiter, blocked := result.value.(*popWaiter)
+	if !blocked {
+		popped := result.value.(poppedValue)
+		return popped.key, popped.value, nil
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case popped := <-waiter.result:
+		if len(keys) > 1 {
+			// The waiter is still queued on the other keys.
+			c.unblockWaiter(waiter)
+		}
+		return popped.key, popped.value, nil
+	case <-expired:
+	}
+
+	// An item may have been handed over while the timeout passed; it must
+	// not be lost.
+	if served := c.unblockWaiter(waiter); served {
+		popped := <-waiter.result
+		return popped.key, popped.value, nil
+	}
+	return "", nil, nil
+}
+
+// unblockWaiter takes waiter out of every queue and reports whether it had
+// been served.
+func (c *Cache) unblockWaiter(waiter *popWaiter) bool {
+	req := Request{Command: UNBLOCK, Value: waiter, Result: make(chan interface{})}
+	c.requests <- req
+	return (<-req.Result).(bool)
+}
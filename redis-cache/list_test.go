@@ -0,0 +1,211 @@
+package rediscache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheListCommands(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	if length, err := cache.RPush("list", "b", "c"); err != nil || length != 2 {
+		t.Fatalf("Expected length 2, got %d, %v", length, err)
+	}
+	if length, _ := cache.LPush("list", "a", "z"); length != 4 {
+		t.Errorf("Expected length 4, got %d", length)
+	}
+
+	items, _ := cache.LRange("list", 0, -1)
+	if !reflect.DeepEqual(items, []interface{}{"z", "a", "b", "c"}) {
+		t.Errorf("Expected [z a b c], got %v", items)
+	}
+	if items, _ := cache.LRange("list", -2, 100); !reflect.DeepEqual(items, []interface{}{"b", "c"}) {
+		t.Errorf("Expected [b c], got %v", items)
+	}
+	if items, _ := cache.LRange("list", 3, 1); len(items) != 0 {
+		t.Errorf("Expected no items, got %v", items)
+	}
+
+	if value, _ := cache.LIndex("list", -1); value != "c" {
+		t.Errorf("Expected c, got %v", value)
+	}
+	if value, _ := cache.LIndex("list", 10); value != nil {
+		t.Errorf("Expected nil, got %v", value)
+	}
+
+	if value, _ := cache.LPop("list"); value != "z" {
+		t.Errorf("Expected z, got %v", value)
+	}
+	if value, _ := cache.RPop("list"); value != "c" {
+		t.Errorf("Expected c, got %v", value)
+	}
+	if length, _ := cache.LLen("list"); length != 2 {
+		t.Errorf("Expected length 2, got %d", length)
+	}
+
+	cache.Del("list")
+	if value, err := cache.LPop("list"); err != nil || value != nil {
+		t.Errorf("Expected nil for a missing list, got %v, %v", value, err)
+	}
+}
+
+func TestCacheLRemAndLTrim(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.RPush("list", "x", "a", "x", "b", "x")
+
+	if removed, _ := cache.LRem("list", -2, "x"); removed != 2 {
+		t.Errorf("Expected 2 removed, got %d", removed)
+	}
+	if items, _ := cache.LRange("list", 0, -1); !reflect.DeepEqual(items, []interface{}{"x", "a", "b"}) {
+		t.Errorf("Expected [x a b], got %v", items)
+	}
+	if removed, _ := cache.LRem("list", 0, "x"); removed != 1 {
+		t.Errorf("Expected 1 removed, got %d", removed)
+	}
+
+	if err := cache.LTrim("list", 1, -1); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := cache.LRange("list", 0, -1); !reflect.DeepEqual(items, []interface{}{"b"}) {
+		t.Errorf("Expected [b], got %v", items)
+	}
+
+	cache.LTrim("list", 5, 10)
+	if cache.Exists("list") != 0 {
+		t.Error("Expected an emptied list to be deleted")
+	}
+}
+
+func TestCacheListWrongType(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("plain", "value", 0)
+	cache.RPush("list", "item")
+
+	if _, err := cache.LPush("plain", "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.LLen("plain"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, _, err := cache.BLPop(time.Second, "plain"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.Incr("list"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, _, err := cache.SetWithOptions("list", "x", SetOptions{Get: true}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.Get("list"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if values := cache.MGet("list"); values[0] != nil {
+		t.Errorf("Expected MGet to return nil for the list, got %v", values[0])
+	}
+	if _, err := cache.GetDel("list"); !errors.Is(err, ErrWrongType) || cache.Exists("list") != 1 {
+		t.Errorf("Expected GetDel to leave the list with ErrWrongType, got %v", err)
+	}
+	if _, err := cache.GetSet("list", "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestCacheBlockingPopServesWaitersInOrder(t *testing.T) {
+	// A single worker shows that blocked clients do not hold on to it.
+	cache := NewRedisCache(1)
+
+	got := make([]interface{}, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			_, value, err := cache.BLPop(0, "queue")
+			if err != nil {
+				t.Errorf("BLPop failed: %v", err)
+			}
+			got[id] = value
+		}(i)
+		waitForWaiters(t, cache, "queue", i+1)
+	}
+
+	cache.RPush("queue", "a", "b", "c")
+	wg.Wait()
+
+	if !reflect.DeepEqual(got, []interface{}{"a", "b", "c"}) {
+		t.Errorf("Expected waiters served in order, got %v", got)
+	}
+	if cache.Exists("queue") != 0 {
+		t.Error("Expected the drained list to be deleted")
+	}
+}
+
+func TestCacheBlockingPopMultipleKeys(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.RPush("second", "1", "2")
+
+	if key, value, _ := cache.BRPop(time.Second, "first", "second"); key != "second" || value != "2" {
+		t.Errorf("Expected second/2, got %s/%v", key, value)
+	}
+
+	done := make(chan string)
+	go func() {
+		key, value, _ := cache.BLPop(time.Second, "first", "third")
+		done <- fmt.Sprintf("%s/%v", key, value)
+	}()
+	waitForWaiters(t, cache, "third", 1)
+	cache.LPush("third", "x")
+
+	if got := <-done; got != "third/x" {
+		t.Errorf("Expected third/x, got %s", got)
+	}
+	for _, sh := range cache.shards {
+		sh.mu.RLock()
+		if len(sh.waiters) != 0 {
+			t.Errorf("Expected the served waiter to leave every queue, got %v", sh.waiters)
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+func TestCacheBlockingPopTimeout(t *testing.T) {
+	cache := NewRedisCache(1)
+
+	start := time.Now()
+	key, value, err := cache.BLPop(50*time.Millisecond, "queue")
+	if err != nil || key != "" || value != nil {
+		t.Errorf("Expected a timeout, got %s, %v, %v", key, value, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected BLPop to wait for the timeout, returned after %v", elapsed)
+	}
+
+	cache.RPush("queue", "kept")
+	if value, _ := cache.LPop("queue"); value != "kept" {
+		t.Errorf("Expected an item pushed after the timeout to stay in the list, got %v", value)
+	}
+	if _, _, err := cache.BLPop(-time.Second, "queue"); !errors.Is(err, ErrNegativeTimeout) {
+		t.Errorf("Expected ErrNegativeTimeout, got %v", err)
+	}
+}
+
+// waitForWaiters waits until count clients are blocked on key.
+func waitForWaiters(t *testing.T, cache *Cache, key string, count int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		sh := cache.shardFor(key)
+		sh.mu.RLock()
+		blocked := len(sh.waiters[key])
+		sh.mu.RUnlock()
+		if blocked >= count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d clients blocked on %s", count, key)
+}
@@ -0,0 +1,401 @@
+package rediscache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// ErrOOM is returned by commands that add data while the cache is over its
+// memory limit and no key can be evicted.
+var ErrOOM = errors.New("OOM command not allowed when used memory > 'maxmemory'")
+
+// EvictionPolicy selects the keys removed when the cache is over its memory
+// limit, like the maxmemory-policy setting of Redis.
+type EvictionPolicy string
+
+const (
+	// NoEviction refuses commands that add data instead of removing keys.
+	NoEviction EvictionPolicy = "noeviction"
+	// AllKeysLRU removes the least recently used keys.
+	AllKeysLRU EvictionPolicy = "allkeys-lru"
+	// VolatileLRU removes the least recently used keys that have a TTL.
+	VolatileLRU EvictionPolicy = "volatile-lru"
+	// AllKeysLFU removes the least frequently used keys.
+	AllKeysLFU EvictionPolicy = "allkeys-lfu"
+	// VolatileTTL removes the keys with a TTL that expire first.
+	VolatileTTL EvictionPolicy = "volatile-ttl"
+	// AllKeysRandom removes random keys.
+	AllKeysRandom EvictionPolicy = "allkeys-random"
+)
+
+// ParseEvictionPolicy returns the policy with the given Redis name.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(name); policy {
+	case NoEviction, AllKeysLRU, VolatileLRU, AllKeysLFU, VolatileTTL, AllKeysRandom:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown eviction policy %q", name)
+}
+
+const (
+	// evictionSamples is how many keys are compared to pick one to evict.
+	// Like Redis, eviction approximates the policy instead of keeping
+	// every key ordered.
+	evictionSamples = 5
+
+	// entryOverhead approximates the bookkeeping of a key beyond its name
+	// and value, and itemOverhead that of an item in a list, hash, set or
+	// sorted set.
+	entryOverhead = 64
+	itemOverhead  = 16
+
+	// lfuInitial, lfuLogFactor and lfuDecay shape the access frequency of
+	// a key as in Redis: the counter starts at lfuInitial, grows more
+	// slowly the higher it is and loses one for every lfuDecay without
+	// access.
+	lfuInitial   = 5
+	lfuLogFactor = 10
+	lfuDecay     = time.Minute
+)
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithMaxMemory limits the approximate memory used by the keys. Zero means no
+// limit.
+func WithMaxMemory(bytes int64) Option {
+	return func(c *Cache) {
+		c.maxMemory.Store(bytes)
+	}
+}
+
+// WithEvictionPolicy selects the keys removed when the memory limit is
+// reached. The default is NoEviction.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *Cache) {
+		c.policy.Store(policy)
+	}
+}
+
+// MemoryStats describes the memory used by the cache and the keys it evicted.
+type MemoryStats struct {
+	UsedMemory     int64
+	MaxMemory      int64
+	Policy         EvictionPolicy
+	Keys           int
+	EvictedKeys    int64
+	RejectedWrites int64
+}
+
+// keyMeta is what the cache knows about a key to account for its memory and
+// pick keys to evict. The map holding it is guarded by the lock of the
+// shard; the access fields are updated atomically so that reads holding the
+// lock for reading can record them.
+type keyMeta struct {
+	size       int64
+	lastAccess atomic.Int64
+	frequency  atomic.Uint32
+}
+
+func newKeyMeta(now time.Time) *keyMeta {
+	m := &keyMeta{}
+	m.lastAccess.Store(now.UnixNano())
+	m.frequency.Store(lfuInitial)
+	return m
+}
+
+// touch records an access to the key.
+func (m *keyMeta) touch(now time.Time) {
+	counter := m.decayedFrequency(now)
+	if counter < 255 {
+		base := float64(0)
+		if counter > lfuInitial {
+			base = float64(counter - lfuInitial)
+		}
+		if rand.Float64() < 1/(base*lfuLogFactor+1) {
+			counter++
+		}
+	}
+	m.frequency.Store(counter)
+	m.lastAccess.Store(now.UnixNano())
+}
+
+// decayedFrequency returns the access frequency, lowered for the time since
+// the last access.
+func (m *keyMeta) decayedFrequency(now time.Time) uint32 {
+	counter := m.frequency.Load()
+	periods := now.Sub(time.Unix(0, m.lastAccess.Load())) / lfuDecay
+	if periods >= time.Duration(counter) {
+		return 0
+	}
+	return counter - uint32(periods)
+}
+
+// valueSize approximates the memory used by value.
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case dataType:
+		return v.memoryUsage()
+	default:
+		return int64(reflect.TypeOf(value).Size())
+	}
+}
+
+// writeCommands are the commands that change the size of keys, mapped to
+// whether they add data. Their keys are accounted for again after they run,
+// and the ones adding data first make room for it. Deleted keys are
+// accounted for by c.delete.
+var writeCommands = map[Command]bool{
+	SET:         true,
+	MSET:        true,
+	MSETNX:      true,
+	INCRBY:      true,
+	INCRBYFLOAT: true,
+	LPUSH:       true,
+	RPUSH:       true,
+	HSET:        true,
+	HINCRBY:     true,
+	SADD:        true,
+	ZADD:        true,
+	ZINCRBY:     true,
+	RESTORE:     true,
+
+	LPOP:  false,
+	RPOP:  false,
+	LREM:  false,
+	LTRIM: false,
+	BLPOP: false,
+	BRPOP: false,
+	HDEL:  false,
+	SREM:  false,
+	ZREM:  false,
+}
+
+// track updates the memory accounted for key after it changed. The caller
+// must hold sh.mu for writing.
+func (sh *shard) track(key string, now time.Time) {
+	value, found := sh.data[key]
+	if !found {
+		sh.untrack(key)
+		return
+	}
+	m := sh.meta[key]
+	if m == nil {
+		m = newKeyMeta(now)
+		sh.meta[key] = m
+	}
+	size := int64(len(key)) + valueSize(value) + entryOverhead
+	sh.used.Add(size - m.size)
+	m.size = size
+}
+
+// untrack forgets key after it was deleted. The caller must hold sh.mu for
+// writing.
+func (sh *shard) untrack(key string) {
+	if m, found := sh.meta[key]; found {
+		sh.used.Add(-m.size)
+		delete(sh.meta, key)
+	}
+}
+
+// requestKeys returns the keys a request works on.
+func requestKeys(req Request) []string {
+	keys := req.Keys
+	if req.Key != "" {
+		keys = append([]string{req.Key}, keys...)
+	}
+	if values, ok := req.Value.(map[string]interface{}); ok && (req.Command == MSET || req.Command == MSETNX) {
+		keys = append(keys, mapKeys(values)...)
+	}
+	return keys
+}
+
+// account records the access to the keys of req and, for commands that
+// change them, their new size.
+func (c *Cache) account(req Request) {
+	keys := requestKeys(req)
+	if len(keys) == 0 {
+		return
+	}
+	now := time.Now()
+	_, writes := writeCommands[req.Command]
+
+	for _, key := range keys {
+		sh := c.shardFor(key)
+		if writes {
+			sh.mu.Lock()
+			sh.track(key, now)
+		} else {
+			sh.mu.RLock()
+		}
+		if m := sh.meta[key]; m != nil {
+			m.touch(now)
+		}
+		if writes {
+			sh.mu.Unlock()
+		} else {
+			sh.mu.RUnlock()
+		}
+	}
+}
+
+// evictionPolicy returns the policy in use.
+func (c *Cache) evictionPolicy() EvictionPolicy {
+	return c.policy.Load().(EvictionPolicy)
+}
+
+// makeRoom evicts keys until the cache is within its memory limit and
+// reports whether it is, counting a refused write when it is not.
+func (c *Cache) makeRoom() bool {
+	for {
+		limit := c.maxMemory.Load()
+		if limit <= 0 || c.used.Load() <= limit {
+			return true
+		}
+		if !c.evict(time.Now()) {
+			c.rejected.Add(1)
+			return false
+		}
+	}
+}
+
+// evictionSample is a key considered for eviction. A higher score is a better
+// key to evict.
+type evictionSample struct {
+	shard *shard
+	key   string
+	score float64
+}
+
+// evict removes the best key to evict among a few sampled ones and reports
+// whether there was any key to choose from. Samples are taken from the
+// shards in turn, starting at a random one; map iteration in Go starts at a
+// random position too, which is enough for sampling.
+func (c *Cache) evict(now time.Time) bool {
+	policy := c.evictionPolicy()
+	if policy == NoEviction {
+		return false
+	}
+	want := evictionSamples
+	if policy == AllKeysRandom {
+		want = 1
+	}
+
+	var best *evictionSample
+	sampled := 0
+	start := rand.Intn(len(c.shards))
+	for i := 0; i < len(c.shards) && sampled < want; i++ {
+		sh := c.shards[(start+i)%len(c.shards)]
+		sh.mu.RLock()
+		for _, key := range sh.sampleKeys(policy, want-sampled) {
+			sample := evictionSample{shard: sh, key: key, score: sh.evictionScore(policy, key, now)}
+			if best == nil || sample.score > best.score {
+				best = &sample
+			}
+			sampled++
+		}
+		sh.mu.RUnlock()
+	}
+	if best == nil {
+		return false
+	}
+
+	// The key may have been deleted since it was sampled; the next round
+	// then finds the memory it freed.
+	best.shard.mu.Lock()
+	if _, found := best.shard.data[best.key]; found {
+		best.shard.delete(best.key)
+		c.evicted.Add(1)
+	}
+	best.shard.mu.Unlock()
+	return true
+}
+
+// sampleKeys returns up to n keys the policy may evict. The caller must hold
+// sh.mu.
+func (sh *shard) sampleKeys(policy EvictionPolicy, n int) []string {
+	keys := make([]string, 0, n)
+	if policy == VolatileLRU || policy == VolatileTTL {
+		for key := range sh.ttl {
+			if len(keys) == n {
+				break
+			}
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	for key := range sh.data {
+		if len(keys) == n {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// evictionScore tells how good a key to evict key is under policy. The
+// caller must hold sh.mu.
+func (sh *shard) evictionScore(policy EvictionPolicy, key string, now time.Time) float64 {
+	switch policy {
+	case AllKeysLFU:
+		if m := sh.meta[key]; m != nil {
+			return -float64(m.decayedFrequency(now))
+		}
+	case VolatileTTL:
+		return -float64(sh.ttl[key].at.UnixNano())
+	default:
+		if m := sh.meta[key]; m != nil {
+			return float64(now.UnixNano() - m.lastAccess.Load())
+		}
+	}
+	return 0
+}
+
+// rejectWrite answers a request refused because the cache is full.
+func rejectWrite(req Request) {
+	if req.Command == SET {
+		req.Result <- setResult{err: ErrOOM}
+		return
+	}
+	req.Result <- reply{err: ErrOOM}
+}
+
+// SetMaxMemory changes the memory limit. Zero means no limit. Keys are
+// evicted when the next command adds data.
+func (c *Cache) SetMaxMemory(bytes int64) {
+	c.maxMemory.Store(bytes)
+}
+
+// SetEvictionPolicy changes the keys removed when the memory limit is
+// reached.
+func (c *Cache) SetEvictionPolicy(policy EvictionPolicy) {
+	c.policy.Store(policy)
+}
+
+// MemoryStats returns the memory used by the cache and its eviction counters.
+func (c *Cache) MemoryStats() MemoryStats {
+	keys := 0
+	for _, sh := range c.shards {
+		sh.mu.RLock()
+		keys += len(sh.data)
+		sh.mu.RUnlock()
+	}
+	return MemoryStats{
+		UsedMemory:     c.used.Load(),
+		MaxMemory:      c.maxMemory.Load(),
+		Policy:         c.evictionPolicy(),
+		Keys:           keys,
+		EvictedKeys:    c.evicted.Load(),
+		RejectedWrites: c.rejected.Load(),
+	}
+}
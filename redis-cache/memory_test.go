@@ -0,0 +1,190 @@
+package rediscache
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// entrySize returns the memory accounted for one key set like the keys of
+// the eviction tests.
+func entrySize(t *testing.T) int64 {
+	t.Helper()
+	cache := NewRedisCache(1)
+	cache.Set("key00", "value", 0)
+	return cache.MemoryStats().UsedMemory
+}
+
+func TestCacheMemoryAccounting(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	cache.Set("key", "value", 0)
+	single := cache.MemoryStats().UsedMemory
+	if single <= int64(len("key")+len("value")) {
+		t.Fatalf("Expected the key to be accounted for, got %d bytes", single)
+	}
+	cache.Set("key", "a much longer value", 0)
+	if used := cache.MemoryStats().UsedMemory; used != single+int64(len("a much longer value")-len("value")) {
+		t.Errorf("Expected overwriting to account for the new value, got %d bytes", used)
+	}
+
+	cache.RPush("list", "a", "b", "c")
+	cache.HSet("hash", map[string]interface{}{"field": "value"})
+	cache.SAdd("set", "member")
+	cache.ZAdd("zset", ZAddOptions{}, ZMember{Member: "member", Score: 1})
+	withTypes := cache.MemoryStats().UsedMemory
+
+	cache.LPop("list")
+	if used := cache.MemoryStats().UsedMemory; used >= withTypes {
+		t.Errorf("Expected popping to free memory, got %d then %d bytes", withTypes, used)
+	}
+
+	cache.Del("key", "list", "hash", "set", "zset")
+	if stats := cache.MemoryStats(); stats.UsedMemory != 0 || stats.Keys != 0 {
+		t.Errorf("Expected no memory used after deleting every key, got %+v", stats)
+	}
+
+	cache.Set("short", "value", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	cache.Get("short")
+	if used := cache.MemoryStats().UsedMemory; used != 0 {
+		t.Errorf("Expected expired keys to free their memory, got %d bytes", used)
+	}
+}
+
+func TestCacheNoEvictionRefusesWrites(t *testing.T) {
+	size := entrySize(t)
+	cache := NewRedisCache(2, WithMaxMemory(3*size))
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		_, _, err = cache.SetWithOptions(fmt.Sprintf("key%02d", i), "value", SetOptions{})
+	}
+	if !errors.Is(err, ErrOOM) {
+		t.Fatalf("Expected ErrOOM once the cache is full, got %v", err)
+	}
+	if _, err := cache.RPush("list", "item"); !errors.Is(err, ErrOOM) {
+		t.Errorf("Expected ErrOOM for a push, got %v", err)
+	}
+	if err := cache.Set("other", "value", 0); !errors.Is(err, ErrOOM) {
+		t.Errorf("Expected ErrOOM from Set, got %v", err)
+	}
+	if err := cache.MSet(map[string]interface{}{"a": "1"}); !errors.Is(err, ErrOOM) {
+		t.Errorf("Expected ErrOOM from MSet, got %v", err)
+	}
+	if stored, err := cache.SetNX("other", "value"); stored || !errors.Is(err, ErrOOM) {
+		t.Errorf("Expected ErrOOM from SetNX, got %v, %v", stored, err)
+	}
+	if stored, err := cache.MSetNX(map[string]interface{}{"a": "1"}); stored || !errors.Is(err, ErrOOM) {
+		t.Errorf("Expected ErrOOM from MSetNX, got %v, %v", stored, err)
+	}
+	if value, _ := cache.Get("key00"); value != "value" {
+		t.Errorf("Expected reads to keep working, got %v", value)
+	}
+
+	stats := cache.MemoryStats()
+	if stats.EvictedKeys != 0 || stats.RejectedWrites < 2 || stats.Policy != NoEviction {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	cache.Del("key00", "key01")
+	if _, _, err := cache.SetWithOptions("key10", "value", SetOptions{}); err != nil {
+		t.Errorf("Expected writes to work again after deleting keys, got %v", err)
+	}
+}
+
+func TestCacheAllKeysLRUKeepsRecentlyUsedKeys(t *testing.T) {
+	size := entrySize(t)
+	cache := NewRedisCache(2, WithMaxMemory(10*size), WithEvictionPolicy(AllKeysLRU))
+
+	cache.Set("hot00", "value", 0)
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Microsecond)
+		cache.Set(fmt.Sprintf("key%02d", i), "value", 0)
+		cache.Get("hot00")
+	}
+
+	if value, _ := cache.Get("hot00"); value != "value" {
+		t.Error("Expected the recently used key to survive eviction")
+	}
+	stats := cache.MemoryStats()
+	if stats.EvictedKeys == 0 || stats.UsedMemory > stats.MaxMemory+size {
+		t.Errorf("Expected keys to be evicted to stay near the limit, got %+v", stats)
+	}
+}
+
+func TestCacheAllKeysLFUKeepsFrequentlyUsedKeys(t *testing.T) {
+	size := entrySize(t)
+	cache := NewRedisCache(2, WithMaxMemory(10*size), WithEvictionPolicy(AllKeysLFU))
+
+	cache.Set("hot00", "value", 0)
+	for i := 0; i < 50; i++ {
+		cache.Get("hot00")
+	}
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%02d", i), "value", 0)
+	}
+
+	if value, _ := cache.Get("hot00"); value != "value" {
+		t.Error("Expected the frequently used key to survive eviction")
+	}
+	if stats := cache.MemoryStats(); stats.EvictedKeys == 0 {
+		t.Errorf("Expected keys to be evicted, got %+v", stats)
+	}
+}
+
+func TestCacheVolatilePoliciesOnlyEvictKeysWithTTL(t *testing.T) {
+	size := entrySize(t)
+
+	cache := NewRedisCache(2, WithMaxMemory(3*size), WithEvictionPolicy(VolatileTTL))
+	cache.Set("key00", "value", 0)
+	cache.Set("key01", "value", 2*time.Hour)
+	cache.Set("key02", "value", time.Hour)
+	cache.Set("key03", "value", 3*time.Hour)
+	cache.Set("key04", "value", 0)
+
+	if cache.Exists("key02") != 0 {
+		t.Error("Expected the key expiring first to be evicted")
+	}
+	if cache.Exists("key00", "key01", "key03", "key04") != 4 {
+		t.Error("Expected the other keys to stay")
+	}
+
+	cache = NewRedisCache(2, WithMaxMemory(2*size), WithEvictionPolicy(VolatileLRU))
+	cache.Set("key00", "value", 0)
+	cache.Set("key01", "value", 0)
+	cache.Set("key02", "value", 0)
+	if _, _, err := cache.SetWithOptions("key03", "value", SetOptions{}); !errors.Is(err, ErrOOM) {
+		t.Errorf("Expected ErrOOM when no key has a TTL, got %v", err)
+	}
+	if cache.Exists("key00", "key01", "key02") != 3 {
+		t.Error("Expected keys without TTL to stay")
+	}
+}
+
+func TestCacheEvictionSettingsCanChange(t *testing.T) {
+	size := entrySize(t)
+	cache := NewRedisCache(2)
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%02d", i), "value", 0)
+	}
+
+	cache.SetMaxMemory(5 * size)
+	cache.SetEvictionPolicy(AllKeysRandom)
+	cache.Set("key10", "value", 0)
+
+	stats := cache.MemoryStats()
+	// Like Redis, keys are evicted before the write, which may then go over
+	// the limit until the next one.
+	if stats.EvictedKeys != 5 || stats.Keys != 6 {
+		t.Errorf("Expected 5 random keys to be evicted, got %+v", stats)
+	}
+
+	if _, err := ParseEvictionPolicy("allkeys-lru"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseEvictionPolicy("sometimes"); err == nil {
+		t.Error("Expected an unknown policy to be refused")
+	}
+}
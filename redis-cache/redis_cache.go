@@ -1,40 +1,161 @@
 package rediscache
 
 import (
+	"errors"
+	"math"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Command string
 
 const (
-	SET Command = "SET"
-	GET Command = "GET"
-	DEL Command = "DEL"
+	SET      Command = "SET"
+	GET      Command = "GET"
+	DEL      Command = "DEL"
+	EXISTS   Command = "EXISTS"
+	EXPIRE   Command = "EXPIRE"
+	EXPIREAT Command = "EXPIREAT"
+	TTL      Command = "TTL"
+	PERSIST  Command = "PERSIST"
+
+	INCRBY      Command = "INCRBY"
+	INCRBYFLOAT Command = "INCRBYFLOAT"
+
+	GETDEL Command = "GETDEL"
+	GETEX  Command = "GETEX"
+	MSET   Command = "MSET"
+	MSETNX Command = "MSETNX"
+	MGET   Command = "MGET"
+
+	LPUSH   Command = "LPUSH"
+	RPUSH   Command = "RPUSH"
+	LPOP    Command = "LPOP"
+	RPOP    Command = "RPOP"
+	LLEN    Command = "LLEN"
+	LRANGE  Command = "LRANGE"
+	LINDEX  Command = "LINDEX"
+	LREM    Command = "LREM"
+	LTRIM   Command = "LTRIM"
+	BLPOP   Command = "BLPOP"
+	BRPOP   Command = "BRPOP"
+	UNBLOCK Command = "UNBLOCK"
+
+	HSET    Command = "HSET"
+	HGET    Command = "HGET"
+	HMGET   Command = "HMGET"
+	HGETALL Command = "HGETALL"
+	HDEL    Command = "HDEL"
+	HEXISTS Command = "HEXISTS"
+	HLEN    Command = "HLEN"
+	HINCRBY Command = "HINCRBY"
+	HKEYS   Command = "HKEYS"
+	HVALS   Command = "HVALS"
+
+	SADD      Command = "SADD"
+	SREM      Command = "SREM"
+	SMEMBERS  Command = "SMEMBERS"
+	SISMEMBER Command = "SISMEMBER"
+	SCARD     Command = "SCARD"
+	SINTER    Command = "SINTER"
+	SUNION    Command = "SUNION"
+	SDIFF     Command = "SDIFF"
+
+	ZADD          Command = "ZADD"
+	ZREM          Command = "ZREM"
+	ZSCORE        Command = "ZSCORE"
+	ZINCRBY       Command = "ZINCRBY"
+	ZRANGE        Command = "ZRANGE"
+	ZRANGEBYSCORE Command = "ZRANGEBYSCORE"
+	ZRANK         Command = "ZRANK"
+	ZCARD         Command = "ZCARD"
+
+	DUMP    Command = "DUMP"
+	RESTORE Command = "RESTORE"
 )
 
+// Errors returned by the counter commands, worded like the Redis replies.
+var (
+	ErrWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat   = errors.New("ERR value is not a valid float")
+	ErrOverflow   = errors.New("ERR increment or decrement would overflow")
+	ErrNotFinite  = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// Remaining times returned by TTL for keys without an expiry, following the
+// -1 and -2 replies of Redis.
+const (
+	NoExpiry   time.Duration = -1
+	KeyMissing time.Duration = -2
+)
+
+// Request is a command handled by a worker. Commands on several keys use
+// Keys instead of Key, and MSET and MSETNX pass their values as a
+// map[string]interface{} in Value. SET takes its TTL from Options; EXPIREAT
+// and GETEX use At instead of TTL. The list commands take their indexes from
+// Start and Stop and the count of LREM from Count. The hash commands take
+// their fields from Fields and the fields to set as a map in Value; the set
+// and sorted set commands take their members from Fields, except ZADD which
+// passes a []ZMember in Value. ZRANGEBYSCORE uses Min and Max.
 type Request struct {
-	Command Command
-	Key     string
-	Value   interface{}
-	TTL     time.Duration
-	Result  chan interface{}
+	Command  Command
+	Key      string
+	Keys     []string
+	Value    interface{}
+	Values   []interface{}
+	Fields   []string
+	TTL      time.Duration
+	At       time.Time
+	Persist  bool
+	Options  SetOptions
+	Start    int
+	Stop     int
+	Count    int
+	ZOptions ZAddOptions
+	Min      ScoreBound
+	Max      ScoreBound
+	Result   chan interface{}
 }
 
+// Cache is a Redis-like in-memory store. Requests are served by a pool of
+// workers and the keyspace is split into shards, each with its own lock, so
+// that workers on different keys do not wait for each other.
 type Cache struct {
-	data       map[string]interface{}
-	ttl        map[string]time.Time
-	mu         sync.RWMutex
+	shards     []*shard
 	requests   chan Request
 	workerPool int
+
+	// used accounts for the memory of the keys of every shard; maxMemory
+	// and policy decide when and which keys are evicted.
+	used      atomic.Int64
+	maxMemory atomic.Int64
+	policy    atomic.Value
+	evicted   atomic.Int64
+	rejected  atomic.Int64
+
+	// stop ends the active expiry cycle when the cache is closed.
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
-func NewRedisCache(workerPool int) *Cache {
+func NewRedisCache(workerPool int, opts ...Option) *Cache {
 	cache := &Cache{
-		data:       make(map[string]interface{}),
-		ttl:        make(map[string]time.Time),
 		requests:   make(chan Request),
 		workerPool: workerPool,
+		stop:       make(chan struct{}),
+	}
+	cache.policy.Store(NoEviction)
+	for _, opt := range opts {
+		opt(cache)
+	}
+	if cache.shards == nil {
+		cache.shards = make([]*shard, defaultShards)
+	}
+	for i := range cache.shards {
+		cache.shards[i] = newShard(&cache.used)
 	}
 	cache.startWorkers()
 	go cache.cleanExpiredKeys()
@@ -52,80 +173,532 @@ func (c *Cache) startWorkers() {
 }
 
 func (c *Cache) handleRequest(req Request) {
+	if writeCommands[req.Command] && !c.makeRoom() {
+		rejectWrite(req)
+		return
+	}
+	// The reply waits for the accounting so that callers see the memory
+	// used by their own commands.
+	result := req.Result
+	req.Result = make(chan interface{}, 1)
+	c.execute(req)
+	c.account(req)
+	result <- <-req.Result
+}
+
+func (c *Cache) execute(req Request) {
 	switch req.Command {
 	case SET:
-		c.mu.Lock()
-		c.data[req.Key] = req.Value
-		if req.TTL > 0 {
-			c.ttl[req.Key] = time.Now().Add(req.TTL)
-		}
-		c.mu.Unlock()
-		req.Result <- true
+		sh := c.shardFor(req.Key)
+		sh.mu.Lock()
+		previous, stored, err := sh.set(req.Key, req.Value, req.Options, time.Now())
+		sh.mu.Unlock()
+		req.Result <- setResult{previous: previous, stored: stored, err: err}
 
 	case GET:
-		c.mu.RLock()
-		if value, found := c.data[req.Key]; found {
-			if expiry, ok := c.ttl[req.Key]; ok && time.Now().After(expiry) {
-				c.mu.RUnlock()
-				c.Del(req.Key)
-				req.Result <- nil
-			} else {
-				c.mu.RUnlock()
-				req.Result <- value
+		sh := c.shardFor(req.Key)
+		sh.mu.RLock()
+		value, found := sh.data[req.Key]
+		expired := found && sh.expired(req.Key, time.Now())
+		sh.mu.RUnlock()
+
+		if expired {
+			// Workers must not queue requests themselves: once every
+			// worker waits on the request channel nothing serves it.
+			sh.mu.Lock()
+			if sh.expired(req.Key, time.Now()) {
+				sh.delete(req.Key)
 			}
-		} else {
-			c.mu.RUnlock()
-			req.Result <- nil
+			sh.mu.Unlock()
+			value = nil
 		}
+		if _, native := value.(dataType); native {
+			req.Result <- reply{err: ErrWrongType}
+			break
+		}
+		req.Result <- reply{value: value}
 
 	case DEL:
-		c.Del(req.Key)
-		req.Result <- true
+		unlock := c.lockKeys(req.Keys, true)
+		now := time.Now()
+		removed := 0
+		for _, key := range req.Keys {
+			sh := c.shardFor(key)
+			if _, found := sh.data[key]; found && !sh.expired(key, now) {
+				removed++
+			}
+			sh.delete(key)
+		}
+		unlock()
+		req.Result <- removed
+
+	case EXISTS:
+		unlock := c.lockKeys(req.Keys, false)
+		now := time.Now()
+		count := 0
+		for _, key := range req.Keys {
+			sh := c.shardFor(key)
+			if _, found := sh.data[key]; found && !sh.expired(key, now) {
+				count++
+			}
+		}
+		unlock()
+		req.Result <- count
+
+	case EXPIRE, EXPIREAT:
+		sh := c.shardFor(req.Key)
+		sh.mu.Lock()
+		now := time.Now()
+		at := req.At
+		if req.Command == EXPIRE {
+			at = now.Add(req.TTL)
+		}
+		_, found := sh.data[req.Key]
+		found = found && !sh.expired(req.Key, now)
+		switch {
+		case !found:
+			sh.delete(req.Key)
+		case !now.Before(at):
+			// An expiry in the past deletes the key, as in Redis.
+			sh.delete(req.Key)
+		default:
+			sh.setExpiry(req.Key, at)
+		}
+		sh.mu.Unlock()
+		req.Result <- found
+
+	case TTL:
+		sh := c.shardFor(req.Key)
+		sh.mu.RLock()
+		now := time.Now()
+		remaining := KeyMissing
+		if _, found := sh.data[req.Key]; found && !sh.expired(req.Key, now) {
+			remaining = NoExpiry
+			if expiry, ok := sh.ttl[req.Key]; ok {
+				remaining = expiry.at.Sub(now)
+			}
+		}
+		sh.mu.RUnlock()
+		req.Result <- remaining
+
+	case PERSIST:
+		sh := c.shardFor(req.Key)
+		sh.mu.Lock()
+		_, hasTTL := sh.ttl[req.Key]
+		persisted := hasTTL && !sh.expired(req.Key, time.Now())
+		if hasTTL && !persisted {
+			sh.delete(req.Key)
+		}
+		sh.clearExpiry(req.Key)
+		sh.mu.Unlock()
+		req.Result <- persisted
+
+	case INCRBY:
+		sh := c.shardFor(req.Key)
+		sh.mu.Lock()
+		result, err := sh.incrBy(req.Key, req.Value.(int64))
+		sh.mu.Unlock()
+		req.Result <- reply{value: result, err: err}
+
+	case INCRBYFLOAT:
+		sh := c.shardFor(req.Key)
+		sh.mu.Lock()
+		result, err := sh.incrByFloat(req.Key, req.Value.(float64))
+		sh.mu.Unlock()
+		req.Result <- reply{value: result, err: err}
+
+	case GETDEL:
+		sh := c.shardFor(req.Key)
+		sh.mu.Lock()
+		value := sh.live(req.Key, time.Now())
+		if _, native := value.(dataType); native {
+			sh.mu.Unlock()
+			req.Result <- reply{err: ErrWrongType}
+			break
+		}
+		sh.delete(req.Key)
+		sh.mu.Unlock()
+		req.Result <- reply{value: value}
+
+	case GETEX:
+		sh := c.shardFor(req.Key)
+		sh.mu.Lock()
+		now := time.Now()
+		value := sh.live(req.Key, now)
+		if _, native := value.(dataType); native {
+			sh.mu.Unlock()
+			req.Result <- reply{err: ErrWrongType}
+			break
+		}
+		if value != nil {
+			switch {
+			case req.Persist:
+				sh.clearExpiry(req.Key)
+			case req.TTL > 0:
+				sh.setExpiry(req.Key, now.Add(req.TTL))
+			case !req.At.IsZero() && !now.Before(req.At):
+				sh.delete(req.Key)
+			case !req.At.IsZero():
+				sh.setExpiry(req.Key, req.At)
+			}
+		}
+		sh.mu.Unlock()
+		req.Result <- reply{value: value}
+
+	case MSET:
+		values := req.Value.(map[string]interface{})
+		unlock := c.lockKeys(mapKeys(values), true)
+		now := time.Now()
+		for key, value := range values {
+			c.shardFor(key).set(key, value, SetOptions{}, now)
+		}
+		unlock()
+		req.Result <- reply{value: true}
+
+	case MSETNX:
+		values := req.Value.(map[string]interface{})
+		unlock := c.lockKeys(mapKeys(values), true)
+		now := time.Now()
+		stored := true
+		for key := range values {
+			if c.shardFor(key).live(key, now) != nil {
+				stored = false
+				break
+			}
+		}
+		if stored {
+			for key, value := range values {
+				c.shardFor(key).set(key, value, SetOptions{}, now)
+			}
+		}
+		unlock()
+		req.Result <- reply{value: stored}
+
+	case MGET:
+		unlock := c.lockKeys(req.Keys, false)
+		now := time.Now()
+		values := make([]interface{}, len(req.Keys))
+		for i, key := range req.Keys {
+			values[i] = plainValue(c.shardFor(key).live(key, now))
+		}
+		unlock()
+		req.Result <- values
+
+	case LPUSH, RPUSH, LPOP, RPOP, LLEN, LRANGE, LINDEX, LREM, LTRIM, BLPOP, BRPOP, UNBLOCK:
+		c.handleListRequest(req)
+
+	case HSET, HGET, HMGET, HGETALL, HDEL, HEXISTS, HLEN, HINCRBY, HKEYS, HVALS:
+		c.handleHashRequest(req)
+
+	case SADD, SREM, SMEMBERS, SISMEMBER, SCARD, SINTER, SUNION, SDIFF:
+		c.handleSetRequest(req)
+
+	case ZADD, ZREM, ZSCORE, ZINCRBY, ZRANGE, ZRANGEBYSCORE, ZRANK, ZCARD:
+		c.handleSortedSetRequest(req)
+
+	case DUMP, RESTORE:
+		c.handleDumpRequest(req)
+	}
+}
+
+// mapKeys returns the keys of values.
+func mapKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// reply is the result of a command that can fail.
+type reply struct {
+	value interface{}
+	err   error
+}
+
+// incrBy adds delta to the integer stored under key. A missing key starts at
+// zero and the expiry of the key is kept. The caller must hold sh.mu for
+// writing.
+func (sh *shard) incrBy(key string, delta int64) (int64, error) {
+	if sh.expired(key, time.Now()) {
+		sh.delete(key)
+	}
+	var current int64
+	if value, found := sh.data[key]; found {
+		var err error
+		if current, err = integerValue(value); err != nil {
+			return 0, err
+		}
+	}
+	current, err := addInteger(current, delta)
+	if err != nil {
+		return 0, err
+	}
+	// Counters are kept as strings, like every other value set from the REPL.
+	sh.data[key] = strconv.FormatInt(current, 10)
+	return current, nil
+}
+
+// addInteger adds delta to current, failing instead of overflowing.
+func addInteger(current, delta int64) (int64, error) {
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	return current + delta, nil
+}
+
+// incrByFloat adds delta to the number stored under key. A missing key starts
+// at zero and the expiry of the key is kept. The caller must hold sh.mu for
+// writing.
+func (sh *shard) incrByFloat(key string, delta float64) (float64, error) {
+	if sh.expired(key, time.Now()) {
+		sh.delete(key)
+	}
+	var current float64
+	if value, found := sh.data[key]; found {
+		var err error
+		if current, err = floatValue(value); err != nil {
+			return 0, err
+		}
+	}
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return 0, ErrNotFinite
+	}
+	sh.data[key] = strconv.FormatFloat(current, 'f', -1, 64)
+	return current, nil
+}
+
+// integerValue reads a stored value as a counter.
+func integerValue(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		return n, nil
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return 0, ErrNotInteger
+		}
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, ErrNotInteger
+		}
+		return int64(v), nil
+	case float32, float64:
+		return 0, ErrNotInteger
+	default:
+		return 0, ErrWrongType
 	}
 }
 
-func (c *Cache) Set(key string, value interface{}, ttl time.Duration) {
+// floatValue reads a stored value as a floating point counter.
+func floatValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, ErrNotFloat
+		}
+		return f, nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	n, err := integerValue(value)
+	if errors.Is(err, ErrNotInteger) {
+		return 0, ErrNotFloat
+	}
+	return float64(n), err
+}
+
+// dataType is implemented by the values of the native data types, such as
+// lists, hashes, sets and sorted sets. Commands on plain values do not read
+// them.
+type dataType interface {
+	// memoryUsage returns the approximate size of the value in bytes.
+	memoryUsage() int64
+}
+
+// plainValue returns value, or nil when it belongs to a native data type.
+func plainValue(value interface{}) interface{} {
+	if _, native := value.(dataType); native {
+		return nil
+	}
+	return value
+}
+
+// Set stores value under key. A positive ttl sets the expiry of the key,
+// otherwise the key does not expire, even when it had an expiry before.
+// ErrOOM is returned when the cache is full and no key can be evicted.
+func (c *Cache) Set(key string, value interface{}, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
 	req := Request{
 		Command: SET,
 		Key:     key,
 		Value:   value,
+		Options: SetOptions{TTL: ttl},
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	return (<-req.Result).(setResult).err
+}
+
+// Get returns the value of key, or nil when the key is missing or expired.
+// A key holding a list, hash, set or sorted set fails with ErrWrongType.
+func (c *Cache) Get(key string) (interface{}, error) {
+	result := c.call(Request{Command: GET, Key: key})
+	return result.value, result.err
+}
+
+// Del removes keys and returns the number of keys that existed.
+func (c *Cache) Del(keys ...string) int {
+	req := Request{
+		Command: DEL,
+		Keys:    keys,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	return (<-req.Result).(int)
+}
+
+// Exists returns how many of keys exist. A key given several times is
+// counted every time.
+func (c *Cache) Exists(keys ...string) int {
+	req := Request{
+		Command: EXISTS,
+		Keys:    keys,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	return (<-req.Result).(int)
+}
+
+// Expire makes key expire after ttl and reports whether the key exists. A ttl
+// that is not positive deletes the key.
+func (c *Cache) Expire(key string, ttl time.Duration) bool {
+	req := Request{
+		Command: EXPIRE,
+		Key:     key,
 		TTL:     ttl,
 		Result:  make(chan interface{}),
 	}
 	c.requests <- req
-	<-req.Result
+	return (<-req.Result).(bool)
 }
 
-func (c *Cache) Get(key string) interface{} {
+// ExpireAt makes key expire at the given time and reports whether the key
+// exists. A time that is not in the future deletes the key.
+func (c *Cache) ExpireAt(key string, at time.Time) bool {
 	req := Request{
-		Command: GET,
+		Command: EXPIREAT,
 		Key:     key,
+		At:      at,
 		Result:  make(chan interface{}),
 	}
 	c.requests <- req
-	return <-req.Result
+	return (<-req.Result).(bool)
 }
 
-func (c *Cache) Del(key string) {
+// TTL returns the time key has left to live, NoExpiry when the key does not
+// expire and KeyMissing when there is no such key.
+func (c *Cache) TTL(key string) time.Duration {
 	req := Request{
-		Command: DEL,
+		Command: TTL,
 		Key:     key,
 		Result:  make(chan interface{}),
 	}
 	c.requests <- req
-	<-req.Result
+	return (<-req.Result).(time.Duration)
 }
 
-func (c *Cache) cleanExpiredKeys() {
-	for {
-		time.Sleep(1 * time.Second)
-		c.mu.Lock()
-		for key, expiry := range c.ttl {
-			if time.Now().After(expiry) {
-				delete(c.data, key)
-				delete(c.ttl, key)
-			}
-		}
-		c.mu.Unlock()
+// Persist removes the expiry of key and reports whether it had one.
+func (c *Cache) Persist(key string) bool {
+	req := Request{
+		Command: PERSIST,
+		Key:     key,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	return (<-req.Result).(bool)
+}
+
+// Incr adds one to the counter stored under key and returns the new value.
+func (c *Cache) Incr(key string) (int64, error) {
+	return c.IncrBy(key, 1)
+}
+
+// Decr subtracts one from the counter stored under key and returns the new
+// value.
+func (c *Cache) Decr(key string) (int64, error) {
+	return c.IncrBy(key, -1)
+}
+
+// IncrBy adds delta to the counter stored under key and returns the new
+// value. A missing key starts at zero and an existing expiry is kept. The
+// counter is stored as a decimal string, so Get returns it as a string.
+func (c *Cache) IncrBy(key string, delta int64) (int64, error) {
+	req := Request{
+		Command: INCRBY,
+		Key:     key,
+		Value:   delta,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	result := (<-req.Result).(reply)
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int64), nil
+}
+
+// DecrBy subtracts delta from the counter stored under key and returns the
+// new value.
+func (c *Cache) DecrBy(key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return c.IncrBy(key, -delta)
+}
+
+// IncrByFloat adds delta to the number stored under key and returns the new
+// value. A missing key starts at zero and an existing expiry is kept.
+func (c *Cache) IncrByFloat(key string, delta float64) (float64, error) {
+	if math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return 0, ErrNotFinite
+	}
+	req := Request{
+		Command: INCRBYFLOAT,
+		Key:     key,
+		Value:   delta,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	result := (<-req.Result).(reply)
+	if result.err != nil {
+		return 0, result.err
 	}
+	return result.value.(float64), nil
 }
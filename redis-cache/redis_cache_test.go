@@ -2,7 +2,10 @@ package rediscache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -62,7 +65,7 @@ func TestCacheRaceConditions(t *testing.T) {
 			return
 		default:
 			time.Sleep(2 * time.Second)
-			if value := cache.Get("key100"); value != nil {
+			if value, _ := cache.Get("key100"); value != nil {
 				t.Errorf("Expected key100 to be expired, but got %v", value)
 			}
 		}
@@ -75,7 +78,7 @@ func TestCacheRaceConditions(t *testing.T) {
 		case <-ctx.Done():
 			return
 		default:
-			if value := cache.Get("key101"); value != "value101" {
+			if value, _ := cache.Get("key101"); value != "value101" {
 				t.Errorf("Expected key101 to have value 'value101', but got %v", value)
 			}
 		}
@@ -83,3 +86,260 @@ func TestCacheRaceConditions(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestCacheSnapshot(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("b", "2", time.Minute)
+	cache.Set("a", "1", 0)
+	cache.Set("c", "3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	entries := cache.Snapshot()
+	if len(entries) != 2 || entries[0].Key != "a" || entries[1].Key != "b" {
+		t.Fatalf("Expected keys a and b, got %v", entries)
+	}
+	if entries[0].TTL != 0 {
+		t.Errorf("Expected no TTL for a, got %v", entries[0].TTL)
+	}
+	if entries[1].TTL <= 0 || entries[1].TTL > time.Minute {
+		t.Errorf("Expected remaining TTL for b, got %v", entries[1].TTL)
+	}
+}
+
+func TestCacheDelAndExists(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("a", "1", 0)
+	cache.Set("b", "2", 0)
+	cache.Set("gone", "3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if count := cache.Exists("a", "b", "a", "gone", "missing"); count != 3 {
+		t.Errorf("Expected 3 existing keys, got %d", count)
+	}
+	if removed := cache.Del("a", "a", "gone", "missing"); removed != 1 {
+		t.Errorf("Expected 1 deleted key, got %d", removed)
+	}
+	if count := cache.Exists("a", "b"); count != 1 {
+		t.Errorf("Expected only b to exist, got %d", count)
+	}
+}
+
+func TestCacheExpiredGetsDoNotDeadlock(t *testing.T) {
+	// With one worker, an expired GET that queued a DEL waited for itself.
+	cache := NewRedisCache(1)
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			if value, _ := cache.Get(fmt.Sprintf("key%d", i)); value != nil {
+				t.Errorf("Expected key%d to be expired, got %v", i, value)
+			}
+		}
+		cache.Del("key0", "key1")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("cache deadlocked on expired keys")
+	}
+}
+
+func TestCacheSetClearsOrKeepsTTL(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("plain", "1", 50*time.Millisecond)
+	cache.Set("plain", "2", 0)
+	cache.Set("kept", "1", 50*time.Millisecond)
+	cache.SetKeepTTL("kept", "2")
+	time.Sleep(60 * time.Millisecond)
+
+	if value, _ := cache.Get("plain"); value != "2" {
+		t.Errorf("Expected overwritten key to lose its TTL, got %v", value)
+	}
+	if value, _ := cache.Get("kept"); value != nil {
+		t.Errorf("Expected key set with KEEPTTL to expire, got %v", value)
+	}
+
+	cache.SetKeepTTL("new", "1")
+	if remaining := cache.TTL("new"); remaining != NoExpiry {
+		t.Errorf("Expected new key set with KEEPTTL to have no expiry, got %v", remaining)
+	}
+}
+
+func TestCacheExpireAndTTL(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("key", "value", 0)
+
+	if remaining := cache.TTL("key"); remaining != NoExpiry {
+		t.Errorf("Expected %v, got %v", NoExpiry, remaining)
+	}
+	if remaining := cache.TTL("missing"); remaining != KeyMissing {
+		t.Errorf("Expected %v, got %v", KeyMissing, remaining)
+	}
+	if cache.Expire("missing", time.Minute) {
+		t.Error("Expected Expire on a missing key to report false")
+	}
+
+	if !cache.Expire("key", time.Minute) {
+		t.Fatal("Expected Expire to report true")
+	}
+	if remaining := cache.TTL("key"); remaining <= 59*time.Second || remaining > time.Minute {
+		t.Errorf("Expected about a minute left, got %v", remaining)
+	}
+
+	if !cache.ExpireAt("key", time.Now().Add(time.Hour)) {
+		t.Fatal("Expected ExpireAt to report true")
+	}
+	if remaining := cache.TTL("key"); remaining <= 59*time.Minute {
+		t.Errorf("Expected about an hour left, got %v", remaining)
+	}
+
+	if !cache.Persist("key") {
+		t.Error("Expected Persist to report true")
+	}
+	if cache.Persist("key") {
+		t.Error("Expected Persist on a key without TTL to report false")
+	}
+	if remaining := cache.TTL("key"); remaining != NoExpiry {
+		t.Errorf("Expected %v after Persist, got %v", NoExpiry, remaining)
+	}
+
+	if !cache.Expire("key", 0) {
+		t.Error("Expected Expire to report true")
+	}
+	if cache.Exists("key") != 0 {
+		t.Error("Expected a non-positive TTL to delete the key")
+	}
+
+	cache.Set("past", "value", 0)
+	cache.ExpireAt("past", time.Now().Add(-time.Second))
+	if remaining := cache.TTL("past"); remaining != KeyMissing {
+		t.Errorf("Expected an expiry in the past to delete the key, got %v", remaining)
+	}
+}
+
+func TestCacheExpiredKeyCannotBeRevived(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("key", "value", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if cache.Expire("key", time.Minute) {
+		t.Error("Expected Expire on an expired key to report false")
+	}
+	if cache.Persist("key") {
+		t.Error("Expected Persist on an expired key to report false")
+	}
+	if value, _ := cache.Get("key"); value != nil {
+		t.Errorf("Expected expired key to stay gone, got %v", value)
+	}
+}
+
+func TestCacheConcurrentIncr(t *testing.T) {
+	cache := NewRedisCache(4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := cache.Incr("counter"); err != nil {
+					t.Errorf("Incr failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if value, _ := cache.Get("counter"); value != "1000" {
+		t.Errorf("Expected counter to be 1000, got %v", value)
+	}
+}
+
+func TestCacheCounters(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	if result, err := cache.DecrBy("missing", 5); err != nil || result != -5 {
+		t.Errorf("Expected a missing key to start at zero, got %d, %v", result, err)
+	}
+
+	cache.Set("counter", "10", time.Minute)
+	if result, err := cache.IncrBy("counter", 5); err != nil || result != 15 {
+		t.Errorf("Expected 15, got %d, %v", result, err)
+	}
+	if result, err := cache.Decr("counter"); err != nil || result != 14 {
+		t.Errorf("Expected 14, got %d, %v", result, err)
+	}
+	if remaining := cache.TTL("counter"); remaining <= 0 {
+		t.Errorf("Expected the counter to keep its TTL, got %v", remaining)
+	}
+
+	if result, err := cache.IncrByFloat("counter", 0.5); err != nil || result != 14.5 {
+		t.Errorf("Expected 14.5, got %v, %v", result, err)
+	}
+	if value, _ := cache.Get("counter"); value != "14.5" {
+		t.Errorf("Expected the counter to be stored as \"14.5\", got %v", value)
+	}
+	if _, err := cache.Incr("counter"); !errors.Is(err, ErrNotInteger) {
+		t.Errorf("Expected ErrNotInteger, got %v", err)
+	}
+
+	cache.Set("native", 41, 0)
+	if result, err := cache.Incr("native"); err != nil || result != 42 {
+		t.Errorf("Expected 42, got %d, %v", result, err)
+	}
+
+	cache.Set("word", "hello", 0)
+	if _, err := cache.IncrByFloat("word", 1); !errors.Is(err, ErrNotFloat) {
+		t.Errorf("Expected ErrNotFloat, got %v", err)
+	}
+
+	cache.Set("struct", struct{}{}, 0)
+	if _, err := cache.Incr("struct"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+
+	cache.Set("max", strconv.FormatInt(math.MaxInt64, 10), 0)
+	if _, err := cache.Incr("max"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	if value, _ := cache.Get("max"); value != strconv.FormatInt(math.MaxInt64, 10) {
+		t.Errorf("Expected an overflowing increment to leave the value, got %v", value)
+	}
+}
+
+func TestGetOnNativeTypes(t *testing.T) {
+	cache := NewRedisCache(2)
+	defer cache.Close()
+
+	cache.Set("plain", "value", 0)
+	cache.RPush("list", "a")
+	cache.HSet("hash", map[string]interface{}{"field": "a"})
+	cache.SAdd("set", "a")
+	cache.ZAdd("zset", ZAddOptions{}, ZMember{Member: "a", Score: 1})
+
+	for _, key := range []string{"list", "hash", "set", "zset"} {
+		if _, err := cache.Get(key); !errors.Is(err, ErrWrongType) {
+			t.Errorf("Expected ErrWrongType for %s, got %v", key, err)
+		}
+	}
+	if value, err := cache.Get("missing"); value != nil || err != nil {
+		t.Errorf("Expected nil for a missing key, got %v, %v", value, err)
+	}
+
+	// MGET returns nil for keys of another type, as in Redis.
+	values := cache.MGet("plain", "list", "hash", "set", "zset", "missing")
+	if values[0] != "value" {
+		t.Errorf("Expected the plain value, got %v", values[0])
+	}
+	for i, value := range values[1:] {
+		if value != nil {
+			t.Errorf("Expected nil at %d, got %v", i+1, value)
+		}
+	}
+}
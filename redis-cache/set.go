@@ -0,0 +1,272 @@
+package rediscache
+
+import (
+	"sort"
+	"time"
+)
+
+// setValue is the value of a key holding a set of strings. A key is deleted
+// when its set becomes empty.
+type setValue struct {
+	members map[string]struct{}
+	bytes   int64
+}
+
+func (s *setValue) memoryUsage() int64 {
+	return s.bytes
+}
+
+func (s *setValue) add(member string) bool {
+	if _, found := s.members[member]; found {
+		return false
+	}
+	s.members[member] = struct{}{}
+	s.bytes += int64(len(member)) + itemOverhead
+	return true
+}
+
+func (s *setValue) remove(member string) bool {
+	if _, found := s.members[member]; !found {
+		return false
+	}
+	delete(s.members, member)
+	s.bytes -= int64(len(member)) + itemOverhead
+	return true
+}
+
+// setOf returns the set stored under key, or nil when there is none. With
+// create a missing set is created. The caller must hold sh.mu for writing.
+func (sh *shard) setOf(key string, now time.Time, create bool) (*setValue, error) {
+	if sh.expired(key, now) {
+		sh.delete(key)
+	}
+	value, found := sh.data[key]
+	if !found {
+		if !create {
+			return nil, nil
+		}
+		s := &setValue{members: make(map[string]struct{})}
+		sh.data[key] = s
+		return s, nil
+	}
+	s, ok := value.(*setValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return s, nil
+}
+
+// sortedMembers returns members in order, so that replies are the same every
+// time.
+func sortedMembers(members map[string]struct{}) []string {
+	sorted := make([]string, 0, len(members))
+	for member := range members {
+		sorted = append(sorted, member)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// handleSetRequest runs the set commands. Adding members keeps the expiry of
+// the key.
+func (c *Cache) handleSetRequest(req Request) {
+	if req.Command == SINTER || req.Command == SUNION || req.Command == SDIFF {
+		c.handleCombineRequest(req)
+		return
+	}
+	sh := c.shardFor(req.Key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	now := time.Now()
+
+	switch req.Command {
+	case SADD:
+		s, err := sh.setOf(req.Key, now, true)
+		if err != nil {
+			req.Result <- reply{err: err}
+			return
+		}
+		added := 0
+		for _, member := range req.Fields {
+			if s.add(member) {
+				added++
+			}
+		}
+		if len(s.members) == 0 {
+			sh.delete(req.Key)
+		}
+		req.Result <- reply{value: added}
+
+	case SREM:
+		s, err := sh.setOf(req.Key, now, false)
+		removed := 0
+		if s != nil {
+			for _, member := range req.Fields {
+				if s.remove(member) {
+					removed++
+				}
+			}
+			if len(s.members) == 0 {
+				sh.delete(req.Key)
+			}
+		}
+		req.Result <- reply{value: removed, err: err}
+
+	case SMEMBERS:
+		s, err := sh.setOf(req.Key, now, false)
+		members := []string{}
+		if s != nil {
+			members = sortedMembers(s.members)
+		}
+		req.Result <- reply{value: members, err: err}
+
+	case SISMEMBER:
+		s, err := sh.setOf(req.Key, now, false)
+		found := false
+		if s != nil {
+			_, found = s.members[req.Fields[0]]
+		}
+		req.Result <- reply{value: found, err: err}
+
+	case SCARD:
+		s, err := sh.setOf(req.Key, now, false)
+		length := 0
+		if s != nil {
+			length = len(s.members)
+		}
+		req.Result <- reply{value: length, err: err}
+	}
+}
+
+// handleCombineRequest runs SINTER, SUNION and SDIFF with the shards of all
+// their keys locked, so that the sets are read at the same point in time.
+func (c *Cache) handleCombineRequest(req Request) {
+	unlock := c.lockKeys(req.Keys, true)
+	defer unlock()
+	now := time.Now()
+
+	sets := make([]*setValue, len(req.Keys))
+	for i, key := range req.Keys {
+		s, err := c.shardFor(key).setOf(key, now, false)
+		if err != nil {
+			req.Result <- reply{err: err}
+			return
+		}
+		if s == nil {
+			// A missing key is an empty set.
+			s = &setValue{}
+		}
+		sets[i] = s
+	}
+	req.Result <- reply{value: sortedMembers(combineSets(req.Command, sets))}
+}
+
+// combineSets returns the intersection, union or difference of sets. The
+// difference keeps the members of the first set that are in none of the
+// others.
+func combineSets(command Command, sets []*setValue) map[string]struct{} {
+	result := make(map[string]struct{})
+	if len(sets) == 0 {
+		return result
+	}
+	switch command {
+	case SINTER:
+		for member := range sets[0].members {
+			inAll := true
+			for _, s := range sets[1:] {
+				if _, found := s.members[member]; !found {
+					inAll = false
+					break
+				}
+			}
+			if inAll {
+				result[member] = struct{}{}
+			}
+		}
+	case SUNION:
+		for _, s := range sets {
+			for member := range s.members {
+				result[member] = struct{}{}
+			}
+		}
+	case SDIFF:
+		for member := range sets[0].members {
+			result[member] = struct{}{}
+		}
+		for _, s := range sets[1:] {
+			for member := range s.members {
+				delete(result, member)
+			}
+		}
+	}
+	return result
+}
+
+// SAdd adds members to the set under key and returns how many were new.
+func (c *Cache) SAdd(key string, members ...string) (int, error) {
+	result := c.call(Request{Command: SADD, Key: key, Fields: members})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// SRem removes members from the set under key and returns how many existed.
+func (c *Cache) SRem(key string, members ...string) (int, error) {
+	result := c.call(Request{Command: SREM, Key: key, Fields: members})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// SMembers returns the members of the set under key in order.
+func (c *Cache) SMembers(key string) ([]string, error) {
+	result := c.call(Request{Command: SMEMBERS, Key: key})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.([]string), nil
+}
+
+// SIsMember reports whether member is in the set under key.
+func (c *Cache) SIsMember(key, member string) (bool, error) {
+	result := c.call(Request{Command: SISMEMBER, Key: key, Fields: []string{member}})
+	if result.err != nil {
+		return false, result.err
+	}
+	return result.value.(bool), nil
+}
+
+// SCard returns the number of members of the set under key.
+func (c *Cache) SCard(key string) (int, error) {
+	result := c.call(Request{Command: SCARD, Key: key})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// SInter returns the members that are in every set under keys, in order.
+func (c *Cache) SInter(keys ...string) ([]string, error) {
+	return c.combine(SINTER, keys)
+}
+
+// SUnion returns the members that are in any set under keys, in order.
+func (c *Cache) SUnion(keys ...string) ([]string, error) {
+	return c.combine(SUNION, keys)
+}
+
+// SDiff returns the members of the set under the first key that are in none
+// of the sets under the other keys, in order.
+func (c *Cache) SDiff(keys ...string) ([]string, error) {
+	return c.combine(SDIFF, keys)
+}
+
+func (c *Cache) combine(command Command, keys []string) ([]string, error) {
+	result := c.call(Request{Command: command, Keys: keys})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.([]string), nil
+}
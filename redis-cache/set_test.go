@@ -0,0 +1,60 @@
+package rediscache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCacheSetCommands(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	if added, err := cache.SAdd("tags", "b", "a", "b"); err != nil || added != 2 {
+		t.Fatalf("Expected 2 new members, got %d, %v", added, err)
+	}
+	if members, _ := cache.SMembers("tags"); !reflect.DeepEqual(members, []string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v", members)
+	}
+	if found, _ := cache.SIsMember("tags", "a"); !found {
+		t.Error("Expected a to be a member")
+	}
+	if length, _ := cache.SCard("tags"); length != 2 {
+		t.Errorf("Expected 2 members, got %d", length)
+	}
+	if removed, _ := cache.SRem("tags", "a", "missing"); removed != 1 {
+		t.Errorf("Expected 1 removed member, got %d", removed)
+	}
+
+	cache.SRem("tags", "b")
+	if cache.Exists("tags") != 0 {
+		t.Error("Expected an empty set to be deleted")
+	}
+}
+
+func TestCacheSetAlgebra(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.SAdd("x", "a", "b", "c")
+	cache.SAdd("y", "b", "c", "d")
+	cache.SAdd("z", "c")
+
+	if members, _ := cache.SInter("x", "y", "z"); !reflect.DeepEqual(members, []string{"c"}) {
+		t.Errorf("Expected [c], got %v", members)
+	}
+	if members, _ := cache.SUnion("x", "y"); !reflect.DeepEqual(members, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Expected [a b c d], got %v", members)
+	}
+	if members, _ := cache.SDiff("x", "y"); !reflect.DeepEqual(members, []string{"a"}) {
+		t.Errorf("Expected [a], got %v", members)
+	}
+	if members, _ := cache.SInter("x", "missing"); len(members) != 0 {
+		t.Errorf("Expected a missing key to act as an empty set, got %v", members)
+	}
+
+	cache.Set("plain", "value", 0)
+	if _, err := cache.SUnion("x", "plain"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := cache.SAdd("plain", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
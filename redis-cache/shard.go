@@ -0,0 +1,136 @@
+package rediscache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultShards is the number of shards of the keyspace unless WithShards
+// sets another one.
+const defaultShards = 16
+
+// shard holds the keys that hash to it together with their expiry, the
+// clients blocked on them and their memory bookkeeping. Every shard has its
+// own lock, so commands on keys of different shards do not wait for each
+// other.
+type shard struct {
+	mu       sync.RWMutex
+	data     map[string]interface{}
+	ttl      map[string]*expiry
+	expiries expiryHeap
+	waiters  map[string][]*popWaiter
+	meta     map[string]*keyMeta
+
+	// used is the memory used by all the shards of the cache.
+	used *atomic.Int64
+}
+
+func newShard(used *atomic.Int64) *shard {
+	return &shard{
+		data:    make(map[string]interface{}),
+		ttl:     make(map[string]*expiry),
+		waiters: make(map[string][]*popWaiter),
+		meta:    make(map[string]*keyMeta),
+		used:    used,
+	}
+}
+
+// WithShards splits the keyspace into n shards, each with its own lock. More
+// shards let more commands run at the same time.
+func WithShards(n int) Option {
+	return func(c *Cache) {
+		if n < 1 {
+			n = 1
+		}
+		c.shards = make([]*shard, n)
+	}
+}
+
+// shardIndex returns the shard of key, using the 32-bit FNV-1a hash inline so
+// that no hasher is allocated per command.
+func (c *Cache) shardIndex(key string) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % uint32(len(c.shards)))
+}
+
+func (c *Cache) shardFor(key string) *shard {
+	return c.shards[c.shardIndex(key)]
+}
+
+// lockKeys locks the shards of keys, for writing when write is set, and
+// returns the function unlocking them. Commands on several keys hold all
+// their shards at once, so they are atomic like in Redis.
+func (c *Cache) lockKeys(keys []string, write bool) func() {
+	indexes := make([]int, len(keys))
+	for i, key := range keys {
+		indexes[i] = c.shardIndex(key)
+	}
+	return c.lockShards(indexes, write)
+}
+
+// lockAll locks every shard, for writing when write is set, and returns the
+// function unlocking them.
+func (c *Cache) lockAll(write bool) func() {
+	indexes := make([]int, len(c.shards))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return c.lockShards(indexes, write)
+}
+
+// lockShards locks the shards at indexes in increasing order, so that two
+// commands on several keys can never wait for each other.
+func (c *Cache) lockShards(indexes []int, write bool) func() {
+	sort.Ints(indexes)
+	locked := make([]*shard, 0, len(indexes))
+	for i, index := range indexes {
+		if i > 0 && index == indexes[i-1] {
+			continue
+		}
+		sh := c.shards[index]
+		if write {
+			sh.mu.Lock()
+		} else {
+			sh.mu.RLock()
+		}
+		locked = append(locked, sh)
+	}
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			if write {
+				locked[i].mu.Unlock()
+			} else {
+				locked[i].mu.RUnlock()
+			}
+		}
+	}
+}
+
+// expired reports whether key has a TTL that passed. The caller must hold
+// sh.mu.
+func (sh *shard) expired(key string, now time.Time) bool {
+	expiry, ok := sh.ttl[key]
+	return ok && !now.Before(expiry.at)
+}
+
+// live returns the value of key, or nil when the key is missing or expired.
+// The caller must hold sh.mu.
+func (sh *shard) live(key string, now time.Time) interface{} {
+	if sh.expired(key, now) {
+		return nil
+	}
+	return sh.data[key]
+}
+
+// delete removes key and its TTL. The caller must hold sh.mu for writing.
+func (sh *shard) delete(key string) {
+	delete(sh.data, key)
+	sh.clearExpiry(key)
+	sh.untrack(key)
+}
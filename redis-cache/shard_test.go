@@ -0,0 +1,211 @@
+package rediscache
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheSpreadsKeysOverShards(t *testing.T) {
+	cache := NewRedisCache(2, WithShards(8))
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), i, 0)
+	}
+
+	used := 0
+	for _, sh := range cache.shards {
+		sh.mu.RLock()
+		if len(sh.data) > 0 {
+			used++
+		}
+		sh.mu.RUnlock()
+	}
+	if used < 4 {
+		t.Errorf("Expected the keys to spread over the shards, got %d of 8 used", used)
+	}
+	if stats := cache.MemoryStats(); stats.Keys != 100 {
+		t.Errorf("Expected 100 keys, got %d", stats.Keys)
+	}
+	if entries := cache.Snapshot(); len(entries) != 100 || entries[0].Key != "key0" {
+		t.Errorf("Expected a sorted snapshot of every shard, got %d entries", len(entries))
+	}
+
+	single := NewRedisCache(1, WithShards(0))
+	single.Set("key", "value", 0)
+	if value, _ := single.Get("key"); len(single.shards) != 1 || value != "value" {
+		t.Error("Expected a cache with a single shard to work")
+	}
+}
+
+func TestCacheMultiKeyCommandsAreAtomicAcrossShards(t *testing.T) {
+	cache := NewRedisCache(4, WithShards(16))
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	var wg sync.WaitGroup
+	var torn atomic.Bool
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				values := make(map[string]interface{}, len(keys))
+				for _, key := range keys {
+					values[key] = fmt.Sprintf("%d-%d", w, n)
+				}
+				cache.MSet(values)
+			}
+		}(w)
+	}
+
+	for i := 0; i < 200; i++ {
+		values := cache.MGet(keys...)
+		for _, value := range values[1:] {
+			if value != values[0] {
+				torn.Store(true)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if torn.Load() {
+		t.Error("Expected MGET to never see a partial MSET")
+	}
+}
+
+func TestCacheMultiKeyCommandsDoNotDeadlock(t *testing.T) {
+	cache := NewRedisCache(8, WithShards(4))
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for n := 0; n < 200; n++ {
+					// Keys in opposite orders lock the same shards.
+					if i%2 == 0 {
+						cache.Del("a", "b", "c", "d")
+						cache.SUnion("a", "b", "c", "d")
+					} else {
+						cache.MSet(map[string]interface{}{"d": 1, "c": 2, "b": 3, "a": 4})
+						cache.Exists("d", "c", "b", "a")
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Multi-key commands deadlocked")
+	}
+}
+
+func TestCacheBlockingPopAcrossShards(t *testing.T) {
+	cache := NewRedisCache(4, WithShards(16))
+
+	// Both lists receive an item at the same time; the client must get
+	// exactly one and the other must stay in its list.
+	for i := 0; i < 50; i++ {
+		first, second := fmt.Sprintf("first%d", i), fmt.Sprintf("second%d", i)
+		done := make(chan string)
+		go func() {
+			key, _, _ := cache.BLPop(time.Second, first, second)
+			done <- key
+		}()
+		waitForWaiters(t, cache, second, 1)
+
+		go cache.RPush(first, "x")
+		go cache.RPush(second, "y")
+		key := <-done
+		if key != first && key != second {
+			t.Fatalf("Expected an item from %s or %s, got %q", first, second, key)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			a, _ := cache.LLen(first)
+			b, _ := cache.LLen(second)
+			if a+b == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected one item left, got %d and %d", a, b)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// benchmarkCache runs Set and Get on random keys from all the goroutines of
+// the benchmark. Compare the single shard with the sharded cache on a
+// machine with several cores:
+//
+//	go test -run '^$' -bench 'Cache(SingleShard|Sharded)$' -cpu 1,2,4,8 ./redis-cache
+//
+// With one core, GOMAXPROCS above 1 only interleaves the goroutines, so the
+// results say nothing about lock contention. So far the benchmark has only
+// run on a single-core machine, where the two caches are within noise of
+// each other.
+func benchmarkCache(b *testing.B, shards int) {
+	cache := NewRedisCache(runtime.GOMAXPROCS(0)*2, WithShards(shards))
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		cache.Set(keys[i], "value", 0)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				cache.Set(key, "value", 0)
+			} else {
+				cache.Get(key)
+			}
+			i += 7
+		}
+	})
+}
+
+func BenchmarkCacheSingleShard(b *testing.B) {
+	benchmarkCache(b, 1)
+}
+
+func BenchmarkCacheSharded(b *testing.B) {
+	benchmarkCache(b, defaultShards)
+}
+
+func BenchmarkCacheMGetSharded(b *testing.B) {
+	cache := NewRedisCache(runtime.GOMAXPROCS(0)*2, WithShards(defaultShards))
+	keys := make([]string, 8)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		cache.Set(keys[i], "value", 0)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cache.MGet(keys...)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package rediscache
+
+import "math/rand"
+
+const (
+	skipListMaxLevel = 32
+	// skipListP is the chance that a node is also linked on the next level.
+	skipListP = 0.25
+)
+
+// skipList keeps the members of a sorted set ordered by score, then by
+// member. Every link records how many nodes it spans, so nodes can be found
+// by rank as well as by score in O(log n).
+type skipList struct {
+	head   *skipListNode
+	level  int
+	length int
+}
+
+type skipListNode struct {
+	member string
+	score  float64
+	levels []skipListLevel
+}
+
+type skipListLevel struct {
+	forward *skipListNode
+	span    int
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head:  &skipListNode{levels: make([]skipListLevel, skipListMaxLevel)},
+		level: 1,
+	}
+}
+
+func randomSkipListLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// before reports whether a node with score and member comes before node.
+func (n *skipListNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// insert adds member with score. The member must not be in the list yet.
+func (l *skipList) insert(member string, score float64) {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomSkipListLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = l.head
+			update[i].levels[i].span = l.length
+		}
+		l.level = level
+	}
+
+	node := &skipListNode{member: member, score: score, levels: make([]skipListLevel, level)}
+	for i := 0; i < level; i++ {
+		node.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = node
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < l.level; i++ {
+		update[i].levels[i].span++
+	}
+	l.length++
+}
+
+// remove deletes member with score and reports whether it was in the list.
+func (l *skipList) remove(member string, score float64) bool {
+	var update [skipListMaxLevel]*skipListNode
+
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	node := x.levels[0].forward
+	if node == nil || node.score != score || node.member != member {
+		return false
+	}
+	for i := 0; i < l.level; i++ {
+		if update[i].levels[i].forward == node {
+			update[i].levels[i].span += node.levels[i].span - 1
+			update[i].levels[i].forward = node.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	for l.level > 1 && l.head.levels[l.level-1].forward == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+// rank returns the 0-based position of member with score, or -1 when it is
+// not in the list.
+func (l *skipList) rank(member string, score float64) int {
+	rank := 0
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !greater(x.levels[i].forward, score, member) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != l.head && x.member == member && x.score == score {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// greater reports whether node comes after score and member.
+func greater(node *skipListNode, score float64, member string) bool {
+	return node.score > score || (node.score == score && node.member > member)
+}
+
+// byRank returns the node at the 0-based rank, or nil when it is out of
+// range.
+func (l *skipList) byRank(rank int) *skipListNode {
+	if rank < 0 || rank >= l.length {
+		return nil
+	}
+	traversed := 0
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank+1 {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstFrom returns the first node whose score is within min, or nil when
+// there is none.
+func (l *skipList) firstFrom(min ScoreBound) *skipListNode {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !min.allowsFrom(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+	return x.levels[0].forward
+}
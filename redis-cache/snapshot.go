@@ -0,0 +1,204 @@
+package rediscache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Types of the values of Entry, named like the replies of the Redis TYPE
+// command.
+const (
+	StringType    = "string"
+	ListType      = "list"
+	HashType      = "hash"
+	SetType       = "set"
+	SortedSetType = "zset"
+)
+
+// ErrUnknownType is returned by Restore for an entry of an unknown type or
+// whose value does not match its type.
+var ErrUnknownType = errors.New("ERR unknown value type")
+
+// Entry is a key of the cache with its value and remaining time to live. A
+// zero TTL means the key does not expire. Value depends on Type: the value
+// itself for StringType, []interface{} for ListType,
+// map[string]interface{} for HashType, the members in order as []string for
+// SetType and []ZMember ordered by score for SortedSetType. Values are
+// copies, so changing them does not change the cache.
+type Entry struct {
+	Key   string
+	Type  string
+	Value interface{}
+	TTL   time.Duration
+}
+
+// Snapshot returns the keys that have not expired, ordered by key.
+func (c *Cache) Snapshot() []Entry {
+	unlock := c.lockAll(false)
+	defer unlock()
+
+	now := time.Now()
+	entries := []Entry{}
+	for _, sh := range c.shards {
+		for key := range sh.data {
+			if entry, found := sh.entry(key, now); found {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// Dump returns the entry of key, or false when the key is missing or
+// expired.
+func (c *Cache) Dump(key string) (Entry, bool) {
+	result := c.call(Request{Command: DUMP, Key: key})
+	if result.value == nil {
+		return Entry{}, false
+	}
+	return result.value.(Entry), true
+}
+
+// Restore replaces the key of entry with its value and TTL in one step, so
+// that the key never holds part of the value or a TTL left from before. An
+// entry with an empty list, hash or set deletes the key.
+func (c *Cache) Restore(entry Entry) error {
+	return c.call(Request{Command: RESTORE, Key: entry.Key, Value: entry}).err
+}
+
+func (c *Cache) handleDumpRequest(req Request) {
+	sh := c.shardFor(req.Key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	now := time.Now()
+
+	if req.Command == DUMP {
+		if entry, found := sh.entry(req.Key, now); found {
+			req.Result <- reply{value: entry}
+			return
+		}
+		req.Result <- reply{}
+		return
+	}
+
+	entry := req.Value.(Entry)
+	value, err := restoredValue(entry)
+	if err != nil {
+		req.Result <- reply{err: err}
+		return
+	}
+	sh.delete(req.Key)
+	if value != nil {
+		sh.data[req.Key] = value
+		if entry.TTL > 0 {
+			sh.setExpiry(req.Key, now.Add(entry.TTL))
+		}
+	}
+	req.Result <- reply{value: true}
+}
+
+// entry returns a copy of the value of key. The caller must hold sh.mu.
+func (sh *shard) entry(key string, now time.Time) (Entry, bool) {
+	value, found := sh.data[key]
+	if !found || sh.expired(key, now) {
+		return Entry{}, false
+	}
+
+	entry := Entry{Key: key, Type: StringType, Value: value}
+	switch v := value.(type) {
+	case *listValue:
+		entry.Type = ListType
+		entry.Value = append([]interface{}(nil), v.items...)
+	case *hashValue:
+		fields := make(map[string]interface{}, len(v.fields))
+		for field, value := range v.fields {
+			fields[field] = value
+		}
+		entry.Type, entry.Value = HashType, fields
+	case *setValue:
+		entry.Type, entry.Value = SetType, sortedMembers(v.members)
+	case *sortedSetValue:
+		members := make([]ZMember, 0, v.list.length)
+		for node := v.list.head.levels[0].forward; node != nil; node = node.levels[0].forward {
+			members = append(members, ZMember{Member: node.member, Score: node.score})
+		}
+		entry.Type, entry.Value = SortedSetType, members
+	}
+	if expiry, ok := sh.ttl[key]; ok {
+		entry.TTL = expiry.at.Sub(now)
+	}
+	return entry, true
+}
+
+// restoredValue builds the value stored for entry, or nil when the entry
+// holds an empty collection.
+func restoredValue(entry Entry) (interface{}, error) {
+	switch entry.Type {
+	case StringType:
+		if entry.Value == nil {
+			break
+		}
+		if _, native := entry.Value.(dataType); native {
+			break
+		}
+		return entry.Value, nil
+
+	case ListType:
+		items, ok := entry.Value.([]interface{})
+		if !ok {
+			break
+		}
+		if len(items) == 0 {
+			return nil, nil
+		}
+		l := &listValue{}
+		l.setItems(append([]interface{}(nil), items...))
+		return l, nil
+
+	case HashType:
+		fields, ok := entry.Value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		if len(fields) == 0 {
+			return nil, nil
+		}
+		h := &hashValue{fields: make(map[string]interface{}, len(fields))}
+		for field, value := range fields {
+			h.set(field, value)
+		}
+		return h, nil
+
+	case SetType:
+		members, ok := entry.Value.([]string)
+		if !ok {
+			break
+		}
+		if len(members) == 0 {
+			return nil, nil
+		}
+		s := &setValue{members: make(map[string]struct{}, len(members))}
+		for _, member := range members {
+			s.add(member)
+		}
+		return s, nil
+
+	case SortedSetType:
+		members, ok := entry.Value.([]ZMember)
+		if !ok {
+			break
+		}
+		if len(members) == 0 {
+			return nil, nil
+		}
+		z := &sortedSetValue{scores: make(map[string]float64, len(members)), list: newSkipList()}
+		for _, m := range members {
+			z.add(m.Member, m.Score)
+		}
+		return z, nil
+	}
+	return nil, fmt.Errorf("%w: %s holding %T", ErrUnknownType, entry.Type, entry.Value)
+}
@@ -0,0 +1,101 @@
+package rediscache
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheSnapshotIncludesNativeTypes(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("plain", "value", 0)
+	cache.RPush("list", "a", "b", "a")
+	cache.HSet("hash", map[string]interface{}{"f1": "v1", "f2": "v2"})
+	cache.SAdd("set", "y", "x")
+	cache.ZAdd("zset", ZAddOptions{}, ZMember{Member: "b", Score: 2}, ZMember{Member: "a", Score: math.Inf(1)})
+	cache.Expire("list", time.Minute)
+
+	expected := []Entry{
+		{Key: "hash", Type: HashType, Value: map[string]interface{}{"f1": "v1", "f2": "v2"}},
+		{Key: "list", Type: ListType, Value: []interface{}{"a", "b", "a"}},
+		{Key: "plain", Type: StringType, Value: "value"},
+		{Key: "set", Type: SetType, Value: []string{"x", "y"}},
+		{Key: "zset", Type: SortedSetType, Value: []ZMember{{Member: "b", Score: 2}, {Member: "a", Score: math.Inf(1)}}},
+	}
+	entries := cache.Snapshot()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %v", len(expected), entries)
+	}
+	for i, entry := range entries {
+		if entry.Key == "list" && (entry.TTL <= 0 || entry.TTL > time.Minute) {
+			t.Errorf("Expected the remaining TTL of the list, got %v", entry.TTL)
+		}
+		entry.TTL = 0
+		if !reflect.DeepEqual(entry, expected[i]) {
+			t.Errorf("Expected %+v, got %+v", expected[i], entry)
+		}
+	}
+
+	// The snapshot holds copies.
+	entries[1].Value.([]interface{})[0] = "changed"
+	if items, _ := cache.LRange("list", 0, 0); items[0] != "a" {
+		t.Errorf("Expected the list to be unchanged, got %v", items)
+	}
+}
+
+func TestCacheDumpAndRestore(t *testing.T) {
+	source := NewRedisCache(2)
+	source.RPush("list", "a", "b")
+	source.HSet("hash", map[string]interface{}{"f": "v"})
+	source.SAdd("set", "x", "y")
+	source.ZAdd("zset", ZAddOptions{}, ZMember{Member: "m", Score: 1.5})
+	source.Set("plain", "value", time.Minute)
+
+	target := NewRedisCache(2)
+	target.Set("list", "old", time.Hour)
+	for _, entry := range source.Snapshot() {
+		if err := target.Restore(entry); err != nil {
+			t.Fatalf("Restore %s: %v", entry.Key, err)
+		}
+	}
+
+	if items, err := target.LRange("list", 0, -1); err != nil || !reflect.DeepEqual(items, []interface{}{"a", "b"}) {
+		t.Errorf("Expected the restored list, got %v, %v", items, err)
+	}
+	if ttl := target.TTL("list"); ttl != NoExpiry {
+		t.Errorf("Expected the TTL of the replaced key to be gone, got %v", ttl)
+	}
+	if ttl := target.TTL("plain"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Expected the restored TTL, got %v", ttl)
+	}
+	if value, _ := target.HGet("hash", "f"); value != "v" {
+		t.Errorf("Expected the restored hash, got %v", value)
+	}
+	if member, _ := target.SIsMember("set", "y"); !member {
+		t.Error("Expected the restored set")
+	}
+	if score, found, _ := target.ZScore("zset", "m"); !found || score != 1.5 {
+		t.Errorf("Expected the restored sorted set, got %v, %v", score, found)
+	}
+	if target.MemoryStats().UsedMemory != source.MemoryStats().UsedMemory {
+		t.Errorf("Expected restored keys to be accounted like the originals, got %d and %d",
+			target.MemoryStats().UsedMemory, source.MemoryStats().UsedMemory)
+	}
+
+	entry, found := target.Dump("set")
+	if !found || entry.Type != SetType || !reflect.DeepEqual(entry.Value, []string{"x", "y"}) {
+		t.Errorf("Expected to dump the set, got %+v", entry)
+	}
+	if _, found := target.Dump("missing"); found {
+		t.Error("Expected no entry for a missing key")
+	}
+
+	if err := target.Restore(Entry{Key: "set", Type: SetType, Value: []string{}}); err != nil || target.Exists("set") != 0 {
+		t.Errorf("Expected an empty set to delete the key, got %v", err)
+	}
+	if err := target.Restore(Entry{Key: "bad", Type: ListType, Value: "x"}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected ErrUnknownType, got %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+package rediscache
+
+import (
+	"math"
+	"time"
+)
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAddOptions changes how ZAdd treats members, like the options of the Redis
+// ZADD command. NX only adds new members and XX only updates existing ones.
+// GT and LT only update a member when its new score is greater or less than
+// the current one; new members are still added.
+type ZAddOptions struct {
+	NX bool
+	XX bool
+	GT bool
+	LT bool
+}
+
+// ScoreBound is one end of a score range. Use math.Inf for an open end.
+type ScoreBound struct {
+	Score     float64
+	Exclusive bool
+}
+
+// allowsFrom reports whether score is within b used as a minimum.
+func (b ScoreBound) allowsFrom(score float64) bool {
+	return score > b.Score || (score == b.Score && !b.Exclusive)
+}
+
+// allowsTo reports whether score is within b used as a maximum.
+func (b ScoreBound) allowsTo(score float64) bool {
+	return score < b.Score || (score == b.Score && !b.Exclusive)
+}
+
+// sortedSetValue is the value of a key holding a sorted set. The map finds
+// the score of a member and the skip list keeps the members in order. A key
+// is deleted when its sorted set becomes empty.
+type sortedSetValue struct {
+	scores map[string]float64
+	list   *skipList
+	bytes  int64
+}
+
+// sortedSetMemberOverhead approximates the score and skip list node kept for
+// a member on top of itemOverhead.
+const sortedSetMemberOverhead = 40
+
+func (z *sortedSetValue) memoryUsage() int64 {
+	return z.bytes
+}
+
+// add sets the score of member, moving it in the skip list if needed.
+func (z *sortedSetValue) add(member string, score float64) {
+	if current, found := z.scores[member]; found {
+		if current == score {
+			return
+		}
+		z.list.remove(member, current)
+	} else {
+		z.bytes += 2*int64(len(member)) + itemOverhead + sortedSetMemberOverhead
+	}
+	z.scores[member] = score
+	z.list.insert(member, score)
+}
+
+func (z *sortedSetValue) remove(member string) bool {
+	score, found := z.scores[member]
+	if !found {
+		return false
+	}
+	delete(z.scores, member)
+	z.list.remove(member, score)
+	z.bytes -= 2*int64(len(member)) + itemOverhead + sortedSetMemberOverhead
+	return true
+}
+
+// sortedSet returns the sorted set stored under key, or nil when there is
+// none. With create a missing sorted set is created. The caller must hold
+// sh.mu for writing.
+func (sh *shard) sortedSet(key string, now time.Time, create bool) (*sortedSetValue, error) {
+	if sh.expired(key, now) {
+		sh.delete(key)
+	}
+	value, found := sh.data[key]
+	if !found {
+		if !create {
+			return nil, nil
+		}
+		z := &sortedSetValue{scores: make(map[string]float64), list: newSkipList()}
+		sh.data[key] = z
+		return z, nil
+	}
+	z, ok := value.(*sortedSetValue)
+	if !ok {
+		return nil, ErrWrongType
+	}
+	return z, nil
+}
+
+// handleSortedSetRequest runs the sorted set commands. Changing members keeps
+// the expiry of the key.
+func (c *Cache) handleSortedSetRequest(req Request) {
+	sh := c.shardFor(req.Key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	now := time.Now()
+
+	create := req.Command == ZADD || req.Command == ZINCRBY
+	z, err := sh.sortedSet(req.Key, now, create)
+	if err != nil {
+		req.Result <- reply{err: err}
+		return
+	}
+
+	switch req.Command {
+	case ZADD:
+		opts := req.ZOptions
+		added := 0
+		for _, m := range req.Value.([]ZMember) {
+			current, found := z.scores[m.Member]
+			switch {
+			case opts.NX && found, opts.XX && !found:
+				continue
+			case found && opts.GT && m.Score <= current, found && opts.LT && m.Score >= current:
+				continue
+			}
+			if !found {
+				added++
+			}
+			z.add(m.Member, m.Score)
+		}
+		if len(z.scores) == 0 {
+			sh.delete(req.Key)
+		}
+		req.Result <- reply{value: added}
+
+	case ZREM:
+		removed := 0
+		if z != nil {
+			for _, member := range req.Fields {
+				if z.remove(member) {
+					removed++
+				}
+			}
+			if len(z.scores) == 0 {
+				sh.delete(req.Key)
+			}
+		}
+		req.Result <- reply{value: removed}
+
+	case ZSCORE:
+		var score interface{}
+		if z != nil {
+			if s, found := z.scores[req.Fields[0]]; found {
+				score = s
+			}
+		}
+		req.Result <- reply{value: score}
+
+	case ZINCRBY:
+		member := req.Fields[0]
+		score := z.scores[member] + req.Value.(float64)
+		if math.IsNaN(score) {
+			if len(z.scores) == 0 {
+				sh.delete(req.Key)
+			}
+			req.Result <- reply{err: ErrNotFinite}
+			return
+		}
+		z.add(member, score)
+		req.Result <- reply{value: score}
+
+	case ZRANGE:
+		members := []ZMember{}
+		if z != nil {
+			if start, end, ok := indexRange(z.list.length, req.Start, req.Stop); ok {
+				node := z.list.byRank(start)
+				for i := start; i < end; i++ {
+					members = append(members, ZMember{Member: node.member, Score: node.score})
+					node = node.levels[0].forward
+				}
+			}
+		}
+		req.Result <- reply{value: members}
+
+	case ZRANGEBYSCORE:
+		members := []ZMember{}
+		if z != nil {
+			for node := z.list.firstFrom(req.Min); node != nil && req.Max.allowsTo(node.score); node = node.levels[0].forward {
+				members = append(members, ZMember{Member: node.member, Score: node.score})
+			}
+		}
+		req.Result <- reply{value: members}
+
+	case ZRANK:
+		rank := -1
+		if z != nil {
+			if score, found := z.scores[req.Fields[0]]; found {
+				rank = z.list.rank(req.Fields[0], score)
+			}
+		}
+		req.Result <- reply{value: rank}
+
+	case ZCARD:
+		length := 0
+		if z != nil {
+			length = len(z.scores)
+		}
+		req.Result <- reply{value: length}
+	}
+}
+
+// ZAdd adds members to the sorted set under key or updates their scores as
+// opts allows, and returns how many members were new.
+func (c *Cache) ZAdd(key string, opts ZAddOptions, members ...ZMember) (int, error) {
+	if opts.NX && (opts.XX || opts.GT || opts.LT) || opts.GT && opts.LT {
+		return 0, ErrSyntax
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, ErrNotFloat
+		}
+	}
+	result := c.call(Request{Command: ZADD, Key: key, Value: members, ZOptions: opts})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// ZRem removes members from the sorted set under key and returns how many
+// existed.
+func (c *Cache) ZRem(key string, members ...string) (int, error) {
+	result := c.call(Request{Command: ZREM, Key: key, Fields: members})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
+
+// ZScore returns the score of member in the sorted set under key and reports
+// whether the member exists.
+func (c *Cache) ZScore(key, member string) (float64, bool, error) {
+	result := c.call(Request{Command: ZSCORE, Key: key, Fields: []string{member}})
+	if result.err != nil || result.value == nil {
+		return 0, false, result.err
+	}
+	return result.value.(float64), true, nil
+}
+
+// ZIncrBy adds delta to the score of member in the sorted set under key and
+// returns the new score. A missing member starts at zero.
+func (c *Cache) ZIncrBy(key string, delta float64, member string) (float64, error) {
+	if math.IsNaN(delta) {
+		return 0, ErrNotFloat
+	}
+	result := c.call(Request{Command: ZINCRBY, Key: key, Fields: []string{member}, Value: delta})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(float64), nil
+}
+
+// ZRange returns the members of the sorted set under key from rank start to
+// stop inclusive, lowest score first. Negative ranks count from the end.
+func (c *Cache) ZRange(key string, start, stop int) ([]ZMember, error) {
+	result := c.call(Request{Command: ZRANGE, Key: key, Start: start, Stop: stop})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.([]ZMember), nil
+}
+
+// ZRangeByScore returns the members of the sorted set under key with a score
+// between min and max, lowest score first.
+func (c *Cache) ZRangeByScore(key string, min, max ScoreBound) ([]ZMember, error) {
+	result := c.call(Request{Command: ZRANGEBYSCORE, Key: key, Min: min, Max: max})
+	if result.err != nil {
+		return nil, result.err
+	}
+	return result.value.([]ZMember), nil
+}
+
+// ZRank returns the 0-based rank of member in the sorted set under key,
+// lowest score first, and reports whether the member exists.
+func (c *Cache) ZRank(key, member string) (int, bool, error) {
+	result := c.call(Request{Command: ZRANK, Key: key, Fields: []string{member}})
+	if result.err != nil {
+		return 0, false, result.err
+	}
+	rank := result.value.(int)
+	return rank, rank >= 0, nil
+}
+
+// ZCard returns the number of members of the sorted set under key.
+func (c *Cache) ZCard(key string) (int, error) {
+	result := c.call(Request{Command: ZCARD, Key: key})
+	if result.err != nil {
+		return 0, result.err
+	}
+	return result.value.(int), nil
+}
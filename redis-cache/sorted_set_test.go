@@ -0,0 +1,154 @@
+package rediscache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCacheSortedSetCommands(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	added, err := cache.ZAdd("latency", ZAddOptions{},
+		ZMember{Member: "provider-b", Score: 120},
+		ZMember{Member: "provider-a", Score: 80},
+		ZMember{Member: "provider-c", Score: 120},
+	)
+	if err != nil || added != 3 {
+		t.Fatalf("Expected 3 new members, got %d, %v", added, err)
+	}
+
+	members, _ := cache.ZRange("latency", 0, -1)
+	want := []ZMember{{"provider-a", 80}, {"provider-b", 120}, {"provider-c", 120}}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("Expected %v, got %v", want, members)
+	}
+	if members, _ := cache.ZRange("latency", -1, -1); !reflect.DeepEqual(members, want[2:]) {
+		t.Errorf("Expected %v, got %v", want[2:], members)
+	}
+
+	if score, found, _ := cache.ZScore("latency", "provider-b"); !found || score != 120 {
+		t.Errorf("Expected 120, got %v, %v", score, found)
+	}
+	if _, found, _ := cache.ZScore("latency", "missing"); found {
+		t.Error("Expected missing member not to be found")
+	}
+
+	if score, _ := cache.ZIncrBy("latency", -50, "provider-c"); score != 70 {
+		t.Errorf("Expected 70, got %v", score)
+	}
+	if rank, found, _ := cache.ZRank("latency", "provider-c"); !found || rank != 0 {
+		t.Errorf("Expected provider-c to rank first, got %d, %v", rank, found)
+	}
+	if _, found, _ := cache.ZRank("latency", "missing"); found {
+		t.Error("Expected missing member to have no rank")
+	}
+
+	byScore, _ := cache.ZRangeByScore("latency", ScoreBound{Score: 70, Exclusive: true}, ScoreBound{Score: math.Inf(1)})
+	if !reflect.DeepEqual(byScore, []ZMember{{"provider-a", 80}, {"provider-b", 120}}) {
+		t.Errorf("Unexpected range by score %v", byScore)
+	}
+
+	if removed, _ := cache.ZRem("latency", "provider-a", "missing"); removed != 1 {
+		t.Errorf("Expected 1 removed member, got %d", removed)
+	}
+	if length, _ := cache.ZCard("latency"); length != 2 {
+		t.Errorf("Expected 2 members, got %d", length)
+	}
+
+	cache.ZRem("latency", "provider-b", "provider-c")
+	if cache.Exists("latency") != 0 {
+		t.Error("Expected an empty sorted set to be deleted")
+	}
+}
+
+func TestCacheZAddOptions(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.ZAdd("scores", ZAddOptions{}, ZMember{Member: "a", Score: 10})
+
+	cache.ZAdd("scores", ZAddOptions{NX: true}, ZMember{Member: "a", Score: 1}, ZMember{Member: "b", Score: 1})
+	cache.ZAdd("scores", ZAddOptions{XX: true}, ZMember{Member: "c", Score: 1})
+	if score, _, _ := cache.ZScore("scores", "a"); score != 10 {
+		t.Errorf("Expected NX to keep the score of a, got %v", score)
+	}
+	if _, found, _ := cache.ZScore("scores", "c"); found {
+		t.Error("Expected XX not to add c")
+	}
+
+	cache.ZAdd("scores", ZAddOptions{GT: true}, ZMember{Member: "a", Score: 5}, ZMember{Member: "d", Score: 5})
+	if score, _, _ := cache.ZScore("scores", "a"); score != 10 {
+		t.Errorf("Expected GT to keep the greater score, got %v", score)
+	}
+	if _, found, _ := cache.ZScore("scores", "d"); !found {
+		t.Error("Expected GT to still add new members")
+	}
+	cache.ZAdd("scores", ZAddOptions{LT: true}, ZMember{Member: "a", Score: 5})
+	if score, _, _ := cache.ZScore("scores", "a"); score != 5 {
+		t.Errorf("Expected LT to lower the score, got %v", score)
+	}
+
+	if _, err := cache.ZAdd("scores", ZAddOptions{NX: true, GT: true}, ZMember{Member: "a", Score: 1}); !errors.Is(err, ErrSyntax) {
+		t.Errorf("Expected ErrSyntax, got %v", err)
+	}
+	if _, err := cache.ZAdd("scores", ZAddOptions{}, ZMember{Member: "a", Score: math.NaN()}); !errors.Is(err, ErrNotFloat) {
+		t.Errorf("Expected ErrNotFloat, got %v", err)
+	}
+
+	cache.SAdd("set", "a")
+	if _, err := cache.ZAdd("set", ZAddOptions{}, ZMember{Member: "a", Score: 1}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
+
+func TestSkipListMatchesSortedSlice(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	list := newSkipList()
+	scores := make(map[string]float64)
+
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("m%d", random.Intn(300))
+		if score, found := scores[member]; found && random.Intn(2) == 0 {
+			if !list.remove(member, score) {
+				t.Fatalf("Expected %s to be removed", member)
+			}
+			delete(scores, member)
+			continue
+		} else if found {
+			list.remove(member, score)
+		}
+		score := float64(random.Intn(50))
+		list.insert(member, score)
+		scores[member] = score
+	}
+
+	sorted := make([]ZMember, 0, len(scores))
+	for member, score := range scores {
+		sorted = append(sorted, ZMember{Member: member, Score: score})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Score != sorted[j].Score {
+			return sorted[i].Score < sorted[j].Score
+		}
+		return sorted[i].Member < sorted[j].Member
+	})
+
+	if list.length != len(sorted) {
+		t.Fatalf("Expected %d nodes, got %d", len(sorted), list.length)
+	}
+	for rank, m := range sorted {
+		node := list.byRank(rank)
+		if node == nil || node.member != m.Member || node.score != m.Score {
+			t.Fatalf("Rank %d: expected %v, got %+v", rank, m, node)
+		}
+		if got := list.rank(m.Member, m.Score); got != rank {
+			t.Fatalf("Expected %s at rank %d, got %d", m.Member, rank, got)
+		}
+	}
+	if node := list.firstFrom(ScoreBound{Score: 25, Exclusive: true}); node != nil && node.score <= 25 {
+		t.Errorf("Expected the first score above 25, got %v", node.score)
+	}
+}
@@ -0,0 +1,190 @@
+package rediscache
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors returned for options that cannot be combined, worded like the Redis
+// replies.
+var (
+	ErrSyntax        = errors.New("ERR syntax error")
+	ErrInvalidExpire = errors.New("ERR invalid expire time")
+)
+
+// SetOptions changes how SetWithOptions stores a value, like the options of
+// the Redis SET command.
+type SetOptions struct {
+	// TTL is the time to live of the key. Zero removes the expiry the key
+	// had unless KeepTTL is set.
+	TTL     time.Duration
+	KeepTTL bool
+	// NX stores the value only when the key does not exist, XX only when
+	// it does.
+	NX bool
+	XX bool
+	// Get returns the value the key had before.
+	Get bool
+}
+
+// GetExOptions changes the expiry of the key read by GetEx. Only one of the
+// fields may be set; the zero value leaves the expiry as it is.
+type GetExOptions struct {
+	TTL     time.Duration
+	At      time.Time
+	Persist bool
+}
+
+// setResult is the reply of SET.
+type setResult struct {
+	previous interface{}
+	stored   bool
+	err      error
+}
+
+// set stores value under key according to opts and returns the value the key
+// had and whether the new value was stored. With opts.Get a key holding a
+// native data type such as a list is left alone. The caller must hold sh.mu
+// for writing.
+func (sh *shard) set(key string, value interface{}, opts SetOptions, now time.Time) (interface{}, bool, error) {
+	if sh.expired(key, now) {
+		sh.delete(key)
+	}
+	previous, found := sh.data[key]
+	if _, native := previous.(dataType); native && opts.Get {
+		return nil, false, ErrWrongType
+	}
+	if (opts.NX && found) || (opts.XX && !found) {
+		return previous, false, nil
+	}
+	sh.data[key] = value
+	switch {
+	case opts.TTL > 0:
+		sh.setExpiry(key, now.Add(opts.TTL))
+	case !opts.KeepTTL:
+		sh.clearExpiry(key)
+	}
+	return previous, true, nil
+}
+
+// SetWithOptions stores value under key like the Redis SET command with
+// options. It reports whether the value was stored and, when opts.Get is
+// set, returns the value the key had before.
+func (c *Cache) SetWithOptions(key string, value interface{}, opts SetOptions) (interface{}, bool, error) {
+	if opts.NX && opts.XX || opts.KeepTTL && opts.TTL != 0 {
+		return nil, false, ErrSyntax
+	}
+	if opts.TTL < 0 {
+		return nil, false, ErrInvalidExpire
+	}
+	req := Request{
+		Command: SET,
+		Key:     key,
+		Value:   value,
+		Options: opts,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	result := (<-req.Result).(setResult)
+	if result.err != nil {
+		return nil, false, result.err
+	}
+	if !opts.Get {
+		return nil, result.stored, nil
+	}
+	return result.previous, result.stored, nil
+}
+
+// SetKeepTTL stores value under key and keeps the expiry the key already
+// has, like SET with KEEPTTL.
+func (c *Cache) SetKeepTTL(key string, value interface{}) error {
+	_, _, err := c.SetWithOptions(key, value, SetOptions{KeepTTL: true})
+	return err
+}
+
+// SetNX stores value under key only when the key does not exist and reports
+// whether it did so.
+func (c *Cache) SetNX(key string, value interface{}) (bool, error) {
+	_, stored, err := c.SetWithOptions(key, value, SetOptions{NX: true})
+	return stored, err
+}
+
+// GetSet stores value under key without expiry and returns the value the key
+// had before. A key holding a native data type such as a list is left alone
+// and ErrWrongType is returned.
+func (c *Cache) GetSet(key string, value interface{}) (interface{}, error) {
+	previous, _, err := c.SetWithOptions(key, value, SetOptions{Get: true})
+	return previous, err
+}
+
+// GetDel returns the value of key and deletes the key. A key holding a
+// native data type such as a list is left alone and ErrWrongType is
+// returned.
+func (c *Cache) GetDel(key string) (interface{}, error) {
+	req := Request{
+		Command: GETDEL,
+		Key:     key,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	result := (<-req.Result).(reply)
+	return result.value, result.err
+}
+
+// GetEx returns the value of key and changes its expiry as opts describes. A
+// time in opts.At that is not in the future deletes the key.
+func (c *Cache) GetEx(key string, opts GetExOptions) (interface{}, error) {
+	set := 0
+	for _, given := range []bool{opts.TTL != 0, !opts.At.IsZero(), opts.Persist} {
+		if given {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, ErrSyntax
+	}
+	if opts.TTL < 0 {
+		return nil, ErrInvalidExpire
+	}
+	req := Request{
+		Command: GETEX,
+		Key:     key,
+		TTL:     opts.TTL,
+		At:      opts.At,
+		Persist: opts.Persist,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	result := (<-req.Result).(reply)
+	return result.value, result.err
+}
+
+// MSet stores every value of values without expiry. Readers see either none
+// or all of the new values. Like Set, it returns ErrOOM and stores nothing
+// when the cache is full and no key can be evicted.
+func (c *Cache) MSet(values map[string]interface{}) error {
+	return c.call(Request{Command: MSET, Value: values}).err
+}
+
+// MSetNX stores every value of values only when none of the keys exists and
+// reports whether it did so.
+func (c *Cache) MSetNX(values map[string]interface{}) (bool, error) {
+	result := c.call(Request{Command: MSETNX, Value: values})
+	if result.err != nil {
+		return false, result.err
+	}
+	return result.value.(bool), nil
+}
+
+// MGet returns the values of keys in the same order, with nil for keys that
+// are missing, expired or hold a native data type such as a list. Like Redis,
+// MGET does not fail on keys of another type, unlike Get.
+func (c *Cache) MGet(keys ...string) []interface{} {
+	req := Request{
+		Command: MGET,
+		Keys:    keys,
+		Result:  make(chan interface{}),
+	}
+	c.requests <- req
+	return (<-req.Result).([]interface{})
+}
@@ -0,0 +1,142 @@
+package rediscache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheSetWithOptions(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	if _, stored, _ := cache.SetWithOptions("key", "1", SetOptions{XX: true}); stored {
+		t.Error("Expected XX not to set a missing key")
+	}
+	if _, stored, _ := cache.SetWithOptions("key", "1", SetOptions{NX: true, TTL: time.Minute}); !stored {
+		t.Error("Expected NX to set a missing key")
+	}
+	if previous, stored, _ := cache.SetWithOptions("key", "2", SetOptions{NX: true, Get: true}); stored || previous != "1" {
+		t.Errorf("Expected NX to keep the existing key and return it, got %v, %v", previous, stored)
+	}
+	if previous, stored, _ := cache.SetWithOptions("key", "3", SetOptions{XX: true, KeepTTL: true, Get: true}); !stored || previous != "1" {
+		t.Errorf("Expected XX to overwrite the key and return 1, got %v, %v", previous, stored)
+	}
+	if remaining := cache.TTL("key"); remaining <= 0 {
+		t.Errorf("Expected KEEPTTL to keep the TTL, got %v", remaining)
+	}
+
+	if _, _, err := cache.SetWithOptions("key", "4", SetOptions{NX: true, XX: true}); !errors.Is(err, ErrSyntax) {
+		t.Errorf("Expected ErrSyntax for NX and XX, got %v", err)
+	}
+	if _, _, err := cache.SetWithOptions("key", "4", SetOptions{KeepTTL: true, TTL: time.Second}); !errors.Is(err, ErrSyntax) {
+		t.Errorf("Expected ErrSyntax for KEEPTTL with a TTL, got %v", err)
+	}
+	if value, _ := cache.Get("key"); value != "3" {
+		t.Errorf("Expected rejected options to leave the key, got %v", value)
+	}
+}
+
+func TestCacheSetNXGetSetAndGetDel(t *testing.T) {
+	cache := NewRedisCache(2)
+
+	first, err := cache.SetNX("key", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.SetNX("key", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first || second {
+		t.Error("Expected only the first SetNX to store the key")
+	}
+
+	cache.Expire("key", time.Minute)
+	if previous, err := cache.GetSet("key", "3"); previous != "1" || err != nil {
+		t.Errorf("Expected GetSet to return 1, got %v, %v", previous, err)
+	}
+	if remaining := cache.TTL("key"); remaining != NoExpiry {
+		t.Errorf("Expected GetSet to remove the TTL, got %v", remaining)
+	}
+
+	if value, err := cache.GetDel("key"); value != "3" || err != nil {
+		t.Errorf("Expected GetDel to return 3, got %v, %v", value, err)
+	}
+	if value, err := cache.GetDel("key"); value != nil || err != nil {
+		t.Errorf("Expected GetDel to have deleted the key, got %v, %v", value, err)
+	}
+}
+
+func TestCacheGetEx(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("key", "value", 0)
+
+	if value, err := cache.GetEx("key", GetExOptions{TTL: time.Minute}); err != nil || value != "value" {
+		t.Fatalf("Expected value, got %v, %v", value, err)
+	}
+	if remaining := cache.TTL("key"); remaining <= 0 {
+		t.Errorf("Expected GetEx to set a TTL, got %v", remaining)
+	}
+
+	cache.GetEx("key", GetExOptions{Persist: true})
+	if remaining := cache.TTL("key"); remaining != NoExpiry {
+		t.Errorf("Expected GetEx to remove the TTL, got %v", remaining)
+	}
+
+	if _, err := cache.GetEx("key", GetExOptions{TTL: time.Minute, Persist: true}); !errors.Is(err, ErrSyntax) {
+		t.Errorf("Expected ErrSyntax, got %v", err)
+	}
+
+	if value, _ := cache.GetEx("key", GetExOptions{At: time.Now().Add(-time.Second)}); value != "value" {
+		t.Errorf("Expected value, got %v", value)
+	}
+	if cache.Exists("key") != 0 {
+		t.Error("Expected an expiry in the past to delete the key")
+	}
+}
+
+func TestCacheMSetAndMGet(t *testing.T) {
+	cache := NewRedisCache(2)
+	cache.Set("b", "old", time.Minute)
+	cache.MSet(map[string]interface{}{"a": "1", "b": "2"})
+
+	values := cache.MGet("a", "missing", "b")
+	if len(values) != 3 || values[0] != "1" || values[1] != nil || values[2] != "2" {
+		t.Errorf("Expected [1 <nil> 2], got %v", values)
+	}
+	if remaining := cache.TTL("b"); remaining != NoExpiry {
+		t.Errorf("Expected MSet to remove the TTL, got %v", remaining)
+	}
+
+	if stored, err := cache.MSetNX(map[string]interface{}{"c": "3", "a": "changed"}); stored || err != nil {
+		t.Error("Expected MSetNX to refuse when a key exists")
+	}
+	if values := cache.MGet("a", "c"); values[0] != "1" || values[1] != nil {
+		t.Errorf("Expected MSetNX to set nothing, got %v", values)
+	}
+	if stored, err := cache.MSetNX(map[string]interface{}{"c": "3", "d": "4"}); !stored || err != nil {
+		t.Error("Expected MSetNX to set new keys")
+	}
+}
+
+func TestCacheMSetIsAtomicForReaders(t *testing.T) {
+	cache := NewRedisCache(4)
+	cache.MSet(map[string]interface{}{"x": 0, "y": 0})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 200; i++ {
+			cache.MSet(map[string]interface{}{"x": i, "y": i})
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		if values := cache.MGet("x", "y"); values[0] != values[1] {
+			t.Fatalf("Expected readers to see both or neither value, got %v", values)
+		}
+	}
+	wg.Wait()
+}